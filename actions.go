@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// Disruptive actions the server can request by command.
+const (
+	ActionRestartCollector = "RestartCollector"
+	ActionRestartAgent     = "RestartAgent"
+	ActionReboot           = "Reboot"
+)
+
+// actionLimit caps how often an action may run, so a server stuck in a
+// loop cannot restart or reboot the device over and over.
+type actionLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+var actionLimits = map[string]actionLimit{
+	ActionRestartCollector: {Max: 10, Window: time.Hour},
+	ActionRestartAgent:     {Max: 5, Window: time.Hour},
+	ActionReboot:           {Max: 3, Window: 24 * time.Hour},
+}
+
+// actionDone names the event sent once an action has completed.
+var actionDone = map[string]string{
+	ActionRestartCollector: "CollectorRestarted",
+	ActionRestartAgent:     "AgentRestarted",
+	ActionReboot:           "HostRebooted",
+}
+
+// errRestartRequested is reported on Err when RestartAgent asks the agent
+// to exit so that its service manager starts it again.
+var errRestartRequested = errors.New("restart requested by the server")
+
+// exitRestartRequested is the exit code for a RestartAgent. It differs from
+// the 1 of a failure but is still non-zero, so service managers that only
+// restart failed units start the agent again.
+const exitRestartRequested = 3
+
+// actionReport is the payload of an action's acknowledgement and of its
+// completion event; both carry the command's correlation id.
+type actionReport struct {
+	Action      string     `json:"action"`
+	RequestedAt time.Time  `json:"requested_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Rebooted tells whether the host's boot id changed across a Reboot
+	Rebooted *bool `json:"rebooted,omitempty"`
+}
+
+// event is the action event named eventType, carrying the report both as
+// JSON and typed.
+func (r actionReport) event(eventType, correlationID string) *controlpb.Event {
+	payload, _ := json.Marshal(r)
+	typed := &controlpb.ActionReport{Action: r.Action, RequestedAtUnixNano: unixNano(r.RequestedAt)}
+	if r.CompletedAt != nil {
+		typed.CompletedAtUnixNano = r.CompletedAt.UnixNano()
+	}
+	if r.Rebooted != nil {
+		typed.RebootChecked, typed.Rebooted = true, *r.Rebooted
+	}
+	return &controlpb.Event{
+		Type:          eventType,
+		Payload:       string(payload),
+		CorrelationId: correlationID,
+		Body:          &controlpb.Event_Action{Action: typed},
+	}
+}
+
+// pendingAction is written before the agent exits or reboots the host, so
+// the next run can report the completion after it reconnects.
+type pendingAction struct {
+	Action        string    `json:"action"`
+	CorrelationID string    `json:"correlation_id"`
+	RequestedAt   time.Time `json:"requested_at"`
+	BootID        string    `json:"boot_id,omitempty"`
+}
+
+// actionLog remembers when each action ran. It is kept in the state dir so
+// that the limits hold across agent restarts and reboots.
+type actionLog struct {
+	path string
+
+	mu   sync.Mutex
+	runs map[string][]time.Time
+}
+
+func openActionLog(path string) *actionLog {
+	l := &actionLog{path: path, runs: map[string][]time.Time{}}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &l.runs)
+	}
+	return l
+}
+
+// allow records a run of action at now, or refuses it when the action has
+// already used up its limit.
+func (l *actionLog) allow(action string, now time.Time) error {
+	limit, ok := actionLimits[action]
+	if !ok {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var recent []time.Time
+	for _, t := range l.runs[action] {
+		if now.Sub(t) < limit.Window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit.Max {
+		return fmt.Errorf("%s rate limited: %d in the last %s, next allowed at %s",
+			action, len(recent), limit.Window, recent[0].Add(limit.Window).UTC().Format(time.RFC3339))
+	}
+	l.runs[action] = append(recent, now)
+
+	data, _ := json.Marshal(l.runs)
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	if err := writeFileAtomic(l.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to record %s: %w", action, err)
+	}
+	return nil
+}
+
+func (a *DeviceAgent) pendingActionPath() string {
+	return filepath.Join(a.opts.StateDir, "pending-action.json")
+}
+
+// handleAction acknowledges a RestartCollector, RestartAgent or Reboot
+// command and carries it out.
+func (a *DeviceAgent) handleAction(ctx context.Context, cmd *controlpb.Command) {
+	action := cmd.GetType()
+	if action == ActionReboot && !a.opts.AllowReboot {
+		a.commandFailed(ctx, cmd, errors.New("host reboot is disabled, start the agent with --allow-reboot"))
+		return
+	}
+	if action == ActionRestartCollector && !hasCapability(a.driver, CapabilityRestart) {
+		a.commandFailed(ctx, cmd, fmt.Errorf("restart %w", errNotSupported))
+		return
+	}
+	now := time.Now()
+	if err := a.actions.allow(action, now); err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+
+	a.log.Info("Action requested", "action", action, attrCorrelationID, cmd.GetCorrelationId())
+	report := actionReport{Action: action, RequestedAt: now}
+	a.sendResult(ctx, report.event(action+"Acknowledged", cmd.GetCorrelationId()))
+
+	switch action {
+	case ActionRestartCollector:
+		if err := a.driver.Restart(ctx); err != nil {
+			a.commandFailed(ctx, cmd, err)
+			return
+		}
+		completed := time.Now()
+		report.CompletedAt = &completed
+		a.sendResult(ctx, report.event(actionDone[action], cmd.GetCorrelationId()))
+
+	case ActionRestartAgent:
+		if err := a.savePendingAction(cmd, now); err != nil {
+			a.commandFailed(ctx, cmd, err)
+			return
+		}
+		a.flush(ctx)
+		a.log.Info("Exiting to restart", attrCorrelationID, cmd.GetCorrelationId())
+		select {
+		case a.errCh <- errRestartRequested:
+		default:
+		}
+
+	case ActionReboot:
+		if err := a.savePendingAction(cmd, now); err != nil {
+			a.commandFailed(ctx, cmd, err)
+			return
+		}
+		a.flush(ctx)
+		a.log.Warn("Rebooting host", "command", strings.Join(a.opts.RebootCommand, " "), attrCorrelationID, cmd.GetCorrelationId())
+		out, err := exec.CommandContext(ctx, a.opts.RebootCommand[0], a.opts.RebootCommand[1:]...).CombinedOutput()
+		if err != nil {
+			os.Remove(a.pendingActionPath())
+			a.commandFailed(ctx, cmd, fmt.Errorf("reboot command failed: %v: %s", err, strings.TrimSpace(string(out))))
+		}
+	}
+}
+
+func (a *DeviceAgent) savePendingAction(cmd *controlpb.Command, requestedAt time.Time) error {
+	pending := pendingAction{
+		Action:        cmd.GetType(),
+		CorrelationID: cmd.GetCorrelationId(),
+		RequestedAt:   requestedAt,
+		BootID:        readBootID(),
+	}
+	data, _ := json.Marshal(pending)
+	if err := os.MkdirAll(a.opts.StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	if err := writeFileAtomic(a.pendingActionPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save pending %s: %w", pending.Action, err)
+	}
+	return nil
+}
+
+// flush waits, up to FlushTimeout, until everything queued so far has been
+// written to the stream. Whatever is left stays in the outbox for the next run.
+func (a *DeviceAgent) flush(ctx context.Context) {
+	deadline := time.Now().Add(a.opts.FlushTimeout)
+	for {
+		queued := len(a.out.high) + len(a.out.normal)
+		if a.outbox != nil {
+			queued += a.outbox.Len()
+		}
+		if queued == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			a.log.Warn("Messages still undelivered, leaving them in the outbox", "count", queued)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// reportPendingAction sends the completion event of a RestartAgent or Reboot
+// that an earlier run of the agent acknowledged before going down.
+func (a *DeviceAgent) reportPendingAction(ctx context.Context) {
+	data, err := os.ReadFile(a.pendingActionPath())
+	if err != nil {
+		return
+	}
+	os.Remove(a.pendingActionPath())
+	var pending pendingAction
+	if err := json.Unmarshal(data, &pending); err != nil {
+		a.log.Warn("Ignoring unreadable pending action", "error", err)
+		return
+	}
+
+	completed := time.Now()
+	report := actionReport{Action: pending.Action, RequestedAt: pending.RequestedAt, CompletedAt: &completed}
+	if pending.Action == ActionReboot {
+		if bootID := readBootID(); bootID != "" && pending.BootID != "" {
+			rebooted := bootID != pending.BootID
+			report.Rebooted = &rebooted
+			if !rebooted {
+				cmd := &controlpb.Command{Type: pending.Action, CorrelationId: pending.CorrelationID}
+				a.commandFailed(ctx, cmd, errors.New("the agent restarted but the host did not reboot"))
+				return
+			}
+		}
+	}
+	a.log.Info("Reporting completed action", "action", pending.Action, attrCorrelationID, pending.CorrelationID)
+	a.sendResult(ctx, report.event(actionDone[pending.Action], pending.CorrelationID))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// newActionAgent returns an agent whose state lives in stateDir.
+func newActionAgent(t *testing.T, stateDir string, opts Options) *DeviceAgent {
+	t.Helper()
+	opts.StateDir = stateDir
+	opts.FlushTimeout = 10 * time.Millisecond
+	return NewDeviceAgent("unused", "device-1", "file", filepath.Join(t.TempDir(), "agent.conf"), "", opts)
+}
+
+// TestActionLogLimits tests rate limiting and that it survives a restart
+func TestActionLogLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "actions.json")
+	limit := actionLimits[ActionReboot]
+	start := time.Now()
+
+	l := openActionLog(path)
+	for i := 0; i < limit.Max; i++ {
+		if err := l.allow(ActionReboot, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("reboot %d refused: %v", i+1, err)
+		}
+	}
+
+	// A new agent process must not get a fresh allowance
+	l = openActionLog(path)
+	tests := []struct {
+		name    string
+		action  string
+		at      time.Time
+		wantErr bool
+	}{
+		{name: "over the limit", action: ActionReboot, at: start.Add(time.Hour), wantErr: true},
+		{name: "other actions unaffected", action: ActionRestartCollector, at: start.Add(time.Hour)},
+		{name: "window passed", action: ActionReboot, at: start.Add(limit.Window)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := l.allow(tt.action, tt.at)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("allow(%s) = %v, wantErr %v", tt.action, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRestartCollector tests the acknowledgement and completion of RestartCollector
+func TestRestartCollector(t *testing.T) {
+	a, fb := newFluentBitAgent(t)
+	a.handleCommand(context.Background(), &controlpb.Command{Type: ActionRestartCollector, CorrelationId: "c-1"})
+
+	ack := nextEvent(t, a, "RestartCollectorAcknowledged")
+	done := nextEvent(t, a, "CollectorRestarted")
+	if ack.CorrelationId != "c-1" || done.CorrelationId != "c-1" {
+		t.Errorf("correlation ids %q, %q, want c-1", ack.CorrelationId, done.CorrelationId)
+	}
+	var report actionReport
+	decodeEvent(t, done, &report)
+	if report.Action != ActionRestartCollector || report.CompletedAt == nil {
+		t.Errorf("report = %+v", report)
+	}
+	if fb.Reloads() != 1 {
+		t.Errorf("Fluent Bit reloaded %d times, want 1", fb.Reloads())
+	}
+}
+
+// TestRebootDisabled tests that Reboot is refused without --allow-reboot
+func TestRebootDisabled(t *testing.T) {
+	a := newActionAgent(t, t.TempDir(), Options{RebootCommand: []string{"false"}})
+	a.handleCommand(context.Background(), &controlpb.Command{Type: ActionReboot, CorrelationId: "c-1"})
+
+	var failed map[string]string
+	decodeEvent(t, nextEvent(t, a, "CommandFailed"), &failed)
+	if !strings.Contains(failed["error"], "--allow-reboot") {
+		t.Errorf("error = %q", failed["error"])
+	}
+}
+
+// TestRestartAgent tests that RestartAgent exits and the next run reports completion
+func TestRestartAgent(t *testing.T) {
+	stateDir := t.TempDir()
+	a := newActionAgent(t, stateDir, Options{})
+	a.handleCommand(context.Background(), &controlpb.Command{Type: ActionRestartAgent, CorrelationId: "c-1"})
+
+	if ev := nextEvent(t, a, "RestartAgentAcknowledged"); ev.CorrelationId != "c-1" {
+		t.Errorf("ack correlation id %q", ev.CorrelationId)
+	}
+	select {
+	case err := <-a.Err():
+		if !errors.Is(err, errRestartRequested) {
+			t.Errorf("Err() = %v", err)
+		}
+	default:
+		t.Fatal("agent did not ask to exit")
+	}
+
+	next := newActionAgent(t, stateDir, Options{})
+	next.reportPendingAction(context.Background())
+	done := nextEvent(t, next, "AgentRestarted")
+	if done.CorrelationId != "c-1" {
+		t.Errorf("completion correlation id %q", done.CorrelationId)
+	}
+	if _, err := os.Stat(next.pendingActionPath()); !os.IsNotExist(err) {
+		t.Errorf("pending action not cleared: %v", err)
+	}
+}
+
+// TestPendingActionReportedAfterRegisterAck tests that the completion event
+// waits for the registration to be answered, so it carries the typed body
+// the server negotiated
+func TestPendingActionReportedAfterRegisterAck(t *testing.T) {
+	stateDir := t.TempDir()
+	a := newActionAgent(t, stateDir, Options{})
+	a.handleCommand(context.Background(), &controlpb.Command{Type: ActionRestartAgent, CorrelationId: "c-1"})
+	<-a.Err()
+
+	mt := newMemoryTransport()
+	next := newActionAgent(t, stateDir, Options{Transport: mt, MonitorInterval: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := next.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer next.Stop()
+	stream := mt.accept(t)
+	stream.next(t) // registration
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_RegisterAck{RegisterAck: &controlpb.RegisterAck{
+		ProtocolVersion: controlProtocolVersion,
+	}}})
+
+	for {
+		ev := stream.next(t).GetEvent()
+		if ev.GetType() != "AgentRestarted" {
+			continue
+		}
+		if ev.GetCorrelationId() != "c-1" || ev.GetAction().GetAction() != ActionRestartAgent {
+			t.Errorf("completion event = %v, want the typed action report", ev)
+		}
+		return
+	}
+}
+
+// TestRebootReportedAfterRestart tests the Reboot completion event, which
+// depends on whether the host's boot id changed
+func TestRebootReportedAfterRestart(t *testing.T) {
+	if readBootID() == "" {
+		t.Skip("no boot id on this platform")
+	}
+	tests := []struct {
+		name      string
+		rebooted  bool
+		wantEvent string
+	}{
+		{name: "host rebooted", rebooted: true, wantEvent: "HostRebooted"},
+		{name: "host did not reboot", rebooted: false, wantEvent: "CommandFailed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateDir := t.TempDir()
+			a := newActionAgent(t, stateDir, Options{AllowReboot: true, RebootCommand: []string{"true"}})
+			a.handleCommand(context.Background(), &controlpb.Command{Type: ActionReboot, CorrelationId: "c-1"})
+			nextEvent(t, a, "RebootAcknowledged")
+
+			if tt.rebooted {
+				var pending pendingAction
+				data, err := os.ReadFile(a.pendingActionPath())
+				if err != nil {
+					t.Fatal(err)
+				}
+				json.Unmarshal(data, &pending)
+				pending.BootID = "previous-boot"
+				data, _ = json.Marshal(pending)
+				os.WriteFile(a.pendingActionPath(), data, 0644)
+			}
+
+			next := newActionAgent(t, stateDir, Options{})
+			next.reportPendingAction(context.Background())
+			if ev := nextEvent(t, next, tt.wantEvent); ev.CorrelationId != "c-1" {
+				t.Errorf("correlation id %q", ev.CorrelationId)
+			}
+		})
+	}
+}
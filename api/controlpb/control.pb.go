@@ -21,31 +21,2249 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type RestartTarget int32
+
+const (
+	RestartTarget_RESTART_TARGET_UNSPECIFIED RestartTarget = 0 // rejected
+	RestartTarget_RESTART_TARGET_COLLECTOR   RestartTarget = 1
+	RestartTarget_RESTART_TARGET_AGENT       RestartTarget = 2
+	RestartTarget_RESTART_TARGET_HOST        RestartTarget = 3 // only with --allow-reboot
+)
+
+// Enum value maps for RestartTarget.
+var (
+	RestartTarget_name = map[int32]string{
+		0: "RESTART_TARGET_UNSPECIFIED",
+		1: "RESTART_TARGET_COLLECTOR",
+		2: "RESTART_TARGET_AGENT",
+		3: "RESTART_TARGET_HOST",
+	}
+	RestartTarget_value = map[string]int32{
+		"RESTART_TARGET_UNSPECIFIED": 0,
+		"RESTART_TARGET_COLLECTOR":   1,
+		"RESTART_TARGET_AGENT":       2,
+		"RESTART_TARGET_HOST":        3,
+	}
+)
+
+func (x RestartTarget) Enum() *RestartTarget {
+	p := new(RestartTarget)
+	*p = x
+	return p
+}
+
+func (x RestartTarget) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RestartTarget) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_control_proto_enumTypes[0].Descriptor()
+}
+
+func (RestartTarget) Type() protoreflect.EnumType {
+	return &file_api_control_proto_enumTypes[0]
+}
+
+func (x RestartTarget) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RestartTarget.Descriptor instead.
+func (RestartTarget) EnumDescriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{0}
+}
+
 type EdgeIdentity struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	NodeId          string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Version         string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	Platform        string                 `protobuf:"bytes,3,opt,name=platform,proto3" json:"platform,omitempty"`
+	AgentType       string                 `protobuf:"bytes,4,opt,name=agent_type,json=agentType,proto3" json:"agent_type,omitempty"`       // "otelcol", "fluentbit"
+	Capabilities    []string               `protobuf:"bytes,5,rep,name=capabilities,proto3" json:"capabilities,omitempty"`                  // what the agent's driver supports, e.g. "reload"
+	InstanceUid     string                 `protobuf:"bytes,6,opt,name=instance_uid,json=instanceUid,proto3" json:"instance_uid,omitempty"` // generated on first run, kept in the state dir
+	Hostname        string                 `protobuf:"bytes,7,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	OsRelease       string                 `protobuf:"bytes,8,opt,name=os_release,json=osRelease,proto3" json:"os_release,omitempty"`                                                    // e.g. "Debian GNU/Linux 12 (bookworm)"
+	Labels          map[string]string      `protobuf:"bytes,9,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // from --label key=value
+	ProtocolVersion uint32                 `protobuf:"varint,10,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`                                // newest Control protocol the edge speaks
+	Commands        []string               `protobuf:"bytes,11,rep,name=commands,proto3" json:"commands,omitempty"`                                                                      // command types the edge handles
+	ConfigFormats   []string               `protobuf:"bytes,12,rep,name=config_formats,json=configFormats,proto3" json:"config_formats,omitempty"`                                       // e.g. "fluentbit", "yaml"
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *EdgeIdentity) Reset() {
+	*x = EdgeIdentity{}
+	mi := &file_api_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EdgeIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EdgeIdentity) ProtoMessage() {}
+
+func (x *EdgeIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EdgeIdentity.ProtoReflect.Descriptor instead.
+func (*EdgeIdentity) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *EdgeIdentity) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetAgentType() string {
+	if x != nil {
+		return x.AgentType
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *EdgeIdentity) GetInstanceUid() string {
+	if x != nil {
+		return x.InstanceUid
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetOsRelease() string {
+	if x != nil {
+		return x.OsRelease
+	}
+	return ""
+}
+
+func (x *EdgeIdentity) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *EdgeIdentity) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *EdgeIdentity) GetCommands() []string {
+	if x != nil {
+		return x.Commands
+	}
+	return nil
+}
+
+func (x *EdgeIdentity) GetConfigFormats() []string {
+	if x != nil {
+		return x.ConfigFormats
+	}
+	return nil
+}
+
+// Reply to EdgeIdentity from supervisor to device. Servers that predate it
+// send nothing, which the edge treats as protocol version 1.
+type RegisterAck struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	ProtocolVersion     uint32                 `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`               // version both sides will speak
+	HeartbeatIntervalMs int64                  `protobuf:"varint,2,opt,name=heartbeat_interval_ms,json=heartbeatIntervalMs,proto3" json:"heartbeat_interval_ms,omitempty"` // 0 = keep the edge's own interval
+	DesiredConfigHash   string                 `protobuf:"bytes,3,opt,name=desired_config_hash,json=desiredConfigHash,proto3" json:"desired_config_hash,omitempty"`        // config the edge should be running
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *RegisterAck) Reset() {
+	*x = RegisterAck{}
+	mi := &file_api_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RegisterAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RegisterAck) ProtoMessage() {}
+
+func (x *RegisterAck) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RegisterAck.ProtoReflect.Descriptor instead.
+func (*RegisterAck) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RegisterAck) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *RegisterAck) GetHeartbeatIntervalMs() int64 {
+	if x != nil {
+		return x.HeartbeatIntervalMs
+	}
+	return 0
+}
+
+func (x *RegisterAck) GetDesiredConfigHash() string {
+	if x != nil {
+		return x.DesiredConfigHash
+	}
+	return ""
+}
+
+// A command from supervisor to device. Since protocol version 4 the body
+// says what to do; older edges only understand the legacy type and payload,
+// so a supervisor talking to them keeps sending those instead.
+type Command struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
-	Platform      string                 `protobuf:"bytes,3,opt,name=platform,proto3" json:"platform,omitempty"`
-	AgentType     string                 `protobuf:"bytes,4,opt,name=agent_type,json=agentType,proto3" json:"agent_type,omitempty"` // "otelcol", "fluentbit"
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`       // legacy name, e.g. "FetchStatus"
+	Payload       string                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"` // legacy JSON parameters (the raw config for UpdateConfig)
+	CorrelationId string                 `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	// Types that are valid to be assigned to Body:
+	//
+	//	*Command_FetchStatus
+	//	*Command_UpdateConfig
+	//	*Command_Restart
+	//	*Command_FetchLogs
+	//	*Command_SetLogLevel
+	//	*Command_FetchMetrics
+	//	*Command_ListConfigHistory
+	//	*Command_DiffConfig
+	//	*Command_RollbackConfig
+	Body          isCommand_Body `protobuf_oneof:"body"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *EdgeIdentity) Reset() {
-	*x = EdgeIdentity{}
-	mi := &file_api_control_proto_msgTypes[0]
+func (x *Command) Reset() {
+	*x = Command{}
+	mi := &file_api_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Command) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Command) ProtoMessage() {}
+
+func (x *Command) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Command.ProtoReflect.Descriptor instead.
+func (*Command) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Command) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Command) GetPayload() string {
+	if x != nil {
+		return x.Payload
+	}
+	return ""
+}
+
+func (x *Command) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *Command) GetBody() isCommand_Body {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *Command) GetFetchStatus() *FetchStatusRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_FetchStatus); ok {
+			return x.FetchStatus
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetUpdateConfig() *UpdateConfigRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_UpdateConfig); ok {
+			return x.UpdateConfig
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetRestart() *RestartRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_Restart); ok {
+			return x.Restart
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetFetchLogs() *FetchLogsRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_FetchLogs); ok {
+			return x.FetchLogs
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetSetLogLevel() *SetLogLevelRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_SetLogLevel); ok {
+			return x.SetLogLevel
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetFetchMetrics() *FetchMetricsRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_FetchMetrics); ok {
+			return x.FetchMetrics
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetListConfigHistory() *ListConfigHistoryRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_ListConfigHistory); ok {
+			return x.ListConfigHistory
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetDiffConfig() *DiffConfigRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_DiffConfig); ok {
+			return x.DiffConfig
+		}
+	}
+	return nil
+}
+
+func (x *Command) GetRollbackConfig() *RollbackConfigRequest {
+	if x != nil {
+		if x, ok := x.Body.(*Command_RollbackConfig); ok {
+			return x.RollbackConfig
+		}
+	}
+	return nil
+}
+
+type isCommand_Body interface {
+	isCommand_Body()
+}
+
+type Command_FetchStatus struct {
+	FetchStatus *FetchStatusRequest `protobuf:"bytes,10,opt,name=fetch_status,json=fetchStatus,proto3,oneof"`
+}
+
+type Command_UpdateConfig struct {
+	UpdateConfig *UpdateConfigRequest `protobuf:"bytes,11,opt,name=update_config,json=updateConfig,proto3,oneof"`
+}
+
+type Command_Restart struct {
+	Restart *RestartRequest `protobuf:"bytes,12,opt,name=restart,proto3,oneof"`
+}
+
+type Command_FetchLogs struct {
+	FetchLogs *FetchLogsRequest `protobuf:"bytes,13,opt,name=fetch_logs,json=fetchLogs,proto3,oneof"`
+}
+
+type Command_SetLogLevel struct {
+	SetLogLevel *SetLogLevelRequest `protobuf:"bytes,14,opt,name=set_log_level,json=setLogLevel,proto3,oneof"`
+}
+
+type Command_FetchMetrics struct {
+	FetchMetrics *FetchMetricsRequest `protobuf:"bytes,15,opt,name=fetch_metrics,json=fetchMetrics,proto3,oneof"`
+}
+
+type Command_ListConfigHistory struct {
+	ListConfigHistory *ListConfigHistoryRequest `protobuf:"bytes,16,opt,name=list_config_history,json=listConfigHistory,proto3,oneof"`
+}
+
+type Command_DiffConfig struct {
+	DiffConfig *DiffConfigRequest `protobuf:"bytes,17,opt,name=diff_config,json=diffConfig,proto3,oneof"`
+}
+
+type Command_RollbackConfig struct {
+	RollbackConfig *RollbackConfigRequest `protobuf:"bytes,18,opt,name=rollback_config,json=rollbackConfig,proto3,oneof"`
+}
+
+func (*Command_FetchStatus) isCommand_Body() {}
+
+func (*Command_UpdateConfig) isCommand_Body() {}
+
+func (*Command_Restart) isCommand_Body() {}
+
+func (*Command_FetchLogs) isCommand_Body() {}
+
+func (*Command_SetLogLevel) isCommand_Body() {}
+
+func (*Command_FetchMetrics) isCommand_Body() {}
+
+func (*Command_ListConfigHistory) isCommand_Body() {}
+
+func (*Command_DiffConfig) isCommand_Body() {}
+
+func (*Command_RollbackConfig) isCommand_Body() {}
+
+type FetchStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchStatusRequest) Reset() {
+	*x = FetchStatusRequest{}
+	mi := &file_api_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchStatusRequest) ProtoMessage() {}
+
+func (x *FetchStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchStatusRequest.ProtoReflect.Descriptor instead.
+func (*FetchStatusRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{3}
+}
+
+type UpdateConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConfigData    []byte                 `protobuf:"bytes,1,opt,name=config_data,json=configData,proto3" json:"config_data,omitempty"`
+	ConfigHash    string                 `protobuf:"bytes,2,opt,name=config_hash,json=configHash,proto3" json:"config_hash,omitempty"` // checked against config_data when set
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateConfigRequest) Reset() {
+	*x = UpdateConfigRequest{}
+	mi := &file_api_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateConfigRequest) ProtoMessage() {}
+
+func (x *UpdateConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateConfigRequest.ProtoReflect.Descriptor instead.
+func (*UpdateConfigRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdateConfigRequest) GetConfigData() []byte {
+	if x != nil {
+		return x.ConfigData
+	}
+	return nil
+}
+
+func (x *UpdateConfigRequest) GetConfigHash() string {
+	if x != nil {
+		return x.ConfigHash
+	}
+	return ""
+}
+
+type RestartRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Target        RestartTarget          `protobuf:"varint,1,opt,name=target,proto3,enum=control.RestartTarget" json:"target,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartRequest) Reset() {
+	*x = RestartRequest{}
+	mi := &file_api_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartRequest) ProtoMessage() {}
+
+func (x *RestartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartRequest.ProtoReflect.Descriptor instead.
+func (*RestartRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RestartRequest) GetTarget() RestartTarget {
+	if x != nil {
+		return x.Target
+	}
+	return RestartTarget_RESTART_TARGET_UNSPECIFIED
+}
+
+type FetchLogsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`                                       // "agent", "collector" or empty for both
+	Lines         int32                  `protobuf:"varint,2,opt,name=lines,proto3" json:"lines,omitempty"`                                        // per source, 0 = default
+	SinceUnixNano int64                  `protobuf:"varint,3,opt,name=since_unix_nano,json=sinceUnixNano,proto3" json:"since_unix_nano,omitempty"` // 0 = no limit
+	MaxAgeMs      int64                  `protobuf:"varint,4,opt,name=max_age_ms,json=maxAgeMs,proto3" json:"max_age_ms,omitempty"`                // like since, but immune to clock skew
+	Grep          string                 `protobuf:"bytes,5,opt,name=grep,proto3" json:"grep,omitempty"`                                           // RE2 regexp
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchLogsRequest) Reset() {
+	*x = FetchLogsRequest{}
+	mi := &file_api_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchLogsRequest) ProtoMessage() {}
+
+func (x *FetchLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchLogsRequest.ProtoReflect.Descriptor instead.
+func (*FetchLogsRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FetchLogsRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *FetchLogsRequest) GetLines() int32 {
+	if x != nil {
+		return x.Lines
+	}
+	return 0
+}
+
+func (x *FetchLogsRequest) GetSinceUnixNano() int64 {
+	if x != nil {
+		return x.SinceUnixNano
+	}
+	return 0
+}
+
+func (x *FetchLogsRequest) GetMaxAgeMs() int64 {
+	if x != nil {
+		return x.MaxAgeMs
+	}
+	return 0
+}
+
+func (x *FetchLogsRequest) GetGrep() string {
+	if x != nil {
+		return x.Grep
+	}
+	return ""
+}
+
+type SetLogLevelRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Level         string                 `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`               // debug, info, warn or error
+	TtlMs         int64                  `protobuf:"varint,2,opt,name=ttl_ms,json=ttlMs,proto3" json:"ttl_ms,omitempty"` // revert after this long, 0 = keep
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetLogLevelRequest) Reset() {
+	*x = SetLogLevelRequest{}
+	mi := &file_api_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetLogLevelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetLogLevelRequest) ProtoMessage() {}
+
+func (x *SetLogLevelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetLogLevelRequest.ProtoReflect.Descriptor instead.
+func (*SetLogLevelRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SetLogLevelRequest) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *SetLogLevelRequest) GetTtlMs() int64 {
+	if x != nil {
+		return x.TtlMs
+	}
+	return 0
+}
+
+type FetchMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FetchMetricsRequest) Reset() {
+	*x = FetchMetricsRequest{}
+	mi := &file_api_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FetchMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchMetricsRequest) ProtoMessage() {}
+
+func (x *FetchMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchMetricsRequest.ProtoReflect.Descriptor instead.
+func (*FetchMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{8}
+}
+
+type ListConfigHistoryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListConfigHistoryRequest) Reset() {
+	*x = ListConfigHistoryRequest{}
+	mi := &file_api_control_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListConfigHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListConfigHistoryRequest) ProtoMessage() {}
+
+func (x *ListConfigHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListConfigHistoryRequest.ProtoReflect.Descriptor instead.
+func (*ListConfigHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{9}
+}
+
+type DiffConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int32                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            int32                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"` // 0 = the running config
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiffConfigRequest) Reset() {
+	*x = DiffConfigRequest{}
+	mi := &file_api_control_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiffConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiffConfigRequest) ProtoMessage() {}
+
+func (x *DiffConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiffConfigRequest.ProtoReflect.Descriptor instead.
+func (*DiffConfigRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DiffConfigRequest) GetFrom() int32 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *DiffConfigRequest) GetTo() int32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type RollbackConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollbackConfigRequest) Reset() {
+	*x = RollbackConfigRequest{}
+	mi := &file_api_control_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollbackConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackConfigRequest) ProtoMessage() {}
+
+func (x *RollbackConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackConfigRequest.ProtoReflect.Descriptor instead.
+func (*RollbackConfigRequest) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RollbackConfigRequest) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+// An event from device to supervisor. The payload always carries the
+// legacy JSON; command results also carry a typed body when protocol
+// version 4 was negotiated.
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Payload       string                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	CorrelationId string                 `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	TsUnixNano    int64                  `protobuf:"varint,4,opt,name=ts_unix_nano,json=tsUnixNano,proto3" json:"ts_unix_nano,omitempty"`
+	// Types that are valid to be assigned to Body:
+	//
+	//	*Event_Status
+	//	*Event_CommandFailed
+	//	*Event_CommandUnknown
+	//	*Event_Action
+	//	*Event_Logs
+	//	*Event_LogLevel
+	//	*Event_Metrics
+	//	*Event_ConfigHistory
+	//	*Event_ConfigDiff
+	//	*Event_ConfigRollback
+	Body          isEvent_Body `protobuf_oneof:"body"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_api_control_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetPayload() string {
+	if x != nil {
+		return x.Payload
+	}
+	return ""
+}
+
+func (x *Event) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *Event) GetTsUnixNano() int64 {
+	if x != nil {
+		return x.TsUnixNano
+	}
+	return 0
+}
+
+func (x *Event) GetBody() isEvent_Body {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *Event) GetStatus() *StatusReport {
+	if x != nil {
+		if x, ok := x.Body.(*Event_Status); ok {
+			return x.Status
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetCommandFailed() *CommandError {
+	if x != nil {
+		if x, ok := x.Body.(*Event_CommandFailed); ok {
+			return x.CommandFailed
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetCommandUnknown() *CommandError {
+	if x != nil {
+		if x, ok := x.Body.(*Event_CommandUnknown); ok {
+			return x.CommandUnknown
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetAction() *ActionReport {
+	if x != nil {
+		if x, ok := x.Body.(*Event_Action); ok {
+			return x.Action
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetLogs() *LogChunk {
+	if x != nil {
+		if x, ok := x.Body.(*Event_Logs); ok {
+			return x.Logs
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetLogLevel() *LogLevelChange {
+	if x != nil {
+		if x, ok := x.Body.(*Event_LogLevel); ok {
+			return x.LogLevel
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetMetrics() *MetricsReport {
+	if x != nil {
+		if x, ok := x.Body.(*Event_Metrics); ok {
+			return x.Metrics
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetConfigHistory() *ConfigHistory {
+	if x != nil {
+		if x, ok := x.Body.(*Event_ConfigHistory); ok {
+			return x.ConfigHistory
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetConfigDiff() *ConfigDiff {
+	if x != nil {
+		if x, ok := x.Body.(*Event_ConfigDiff); ok {
+			return x.ConfigDiff
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetConfigRollback() *ConfigRollback {
+	if x != nil {
+		if x, ok := x.Body.(*Event_ConfigRollback); ok {
+			return x.ConfigRollback
+		}
+	}
+	return nil
+}
+
+type isEvent_Body interface {
+	isEvent_Body()
+}
+
+type Event_Status struct {
+	Status *StatusReport `protobuf:"bytes,10,opt,name=status,proto3,oneof"`
+}
+
+type Event_CommandFailed struct {
+	CommandFailed *CommandError `protobuf:"bytes,11,opt,name=command_failed,json=commandFailed,proto3,oneof"`
+}
+
+type Event_CommandUnknown struct {
+	CommandUnknown *CommandError `protobuf:"bytes,12,opt,name=command_unknown,json=commandUnknown,proto3,oneof"`
+}
+
+type Event_Action struct {
+	Action *ActionReport `protobuf:"bytes,13,opt,name=action,proto3,oneof"`
+}
+
+type Event_Logs struct {
+	Logs *LogChunk `protobuf:"bytes,14,opt,name=logs,proto3,oneof"`
+}
+
+type Event_LogLevel struct {
+	LogLevel *LogLevelChange `protobuf:"bytes,15,opt,name=log_level,json=logLevel,proto3,oneof"`
+}
+
+type Event_Metrics struct {
+	Metrics *MetricsReport `protobuf:"bytes,16,opt,name=metrics,proto3,oneof"`
+}
+
+type Event_ConfigHistory struct {
+	ConfigHistory *ConfigHistory `protobuf:"bytes,17,opt,name=config_history,json=configHistory,proto3,oneof"`
+}
+
+type Event_ConfigDiff struct {
+	ConfigDiff *ConfigDiff `protobuf:"bytes,18,opt,name=config_diff,json=configDiff,proto3,oneof"`
+}
+
+type Event_ConfigRollback struct {
+	ConfigRollback *ConfigRollback `protobuf:"bytes,19,opt,name=config_rollback,json=configRollback,proto3,oneof"`
+}
+
+func (*Event_Status) isEvent_Body() {}
+
+func (*Event_CommandFailed) isEvent_Body() {}
+
+func (*Event_CommandUnknown) isEvent_Body() {}
+
+func (*Event_Action) isEvent_Body() {}
+
+func (*Event_Logs) isEvent_Body() {}
+
+func (*Event_LogLevel) isEvent_Body() {}
+
+func (*Event_Metrics) isEvent_Body() {}
+
+func (*Event_ConfigHistory) isEvent_Body() {}
+
+func (*Event_ConfigDiff) isEvent_Body() {}
+
+func (*Event_ConfigRollback) isEvent_Body() {}
+
+type CommandError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommandError) Reset() {
+	*x = CommandError{}
+	mi := &file_api_control_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommandError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommandError) ProtoMessage() {}
+
+func (x *CommandError) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommandError.ProtoReflect.Descriptor instead.
+func (*CommandError) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CommandError) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *CommandError) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type StatusReport struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion     uint32                 `protobuf:"varint,1,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	DeviceId          string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Status            string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	TimestampUnixNano int64                  `protobuf:"varint,4,opt,name=timestamp_unix_nano,json=timestampUnixNano,proto3" json:"timestamp_unix_nano,omitempty"`
+	Agent             *AgentStatus           `protobuf:"bytes,5,opt,name=agent,proto3" json:"agent,omitempty"`
+	Connection        *ConnectionStatus      `protobuf:"bytes,6,opt,name=connection,proto3" json:"connection,omitempty"`
+	Config            *ConfigStatus          `protobuf:"bytes,7,opt,name=config,proto3" json:"config,omitempty"`
+	Collector         *CollectorStatus       `protobuf:"bytes,8,opt,name=collector,proto3" json:"collector,omitempty"`
+	Host              *HostStatus            `protobuf:"bytes,9,opt,name=host,proto3" json:"host,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *StatusReport) Reset() {
+	*x = StatusReport{}
+	mi := &file_api_control_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusReport) ProtoMessage() {}
+
+func (x *StatusReport) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusReport.ProtoReflect.Descriptor instead.
+func (*StatusReport) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *StatusReport) GetSchemaVersion() uint32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *StatusReport) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *StatusReport) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *StatusReport) GetTimestampUnixNano() int64 {
+	if x != nil {
+		return x.TimestampUnixNano
+	}
+	return 0
+}
+
+func (x *StatusReport) GetAgent() *AgentStatus {
+	if x != nil {
+		return x.Agent
+	}
+	return nil
+}
+
+func (x *StatusReport) GetConnection() *ConnectionStatus {
+	if x != nil {
+		return x.Connection
+	}
+	return nil
+}
+
+func (x *StatusReport) GetConfig() *ConfigStatus {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *StatusReport) GetCollector() *CollectorStatus {
+	if x != nil {
+		return x.Collector
+	}
+	return nil
+}
+
+func (x *StatusReport) GetHost() *HostStatus {
+	if x != nil {
+		return x.Host
+	}
+	return nil
+}
+
+type AgentStatus struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Version           string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	GoVersion         string                 `protobuf:"bytes,2,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
+	Revision          string                 `protobuf:"bytes,3,opt,name=revision,proto3" json:"revision,omitempty"`
+	InstanceUid       string                 `protobuf:"bytes,4,opt,name=instance_uid,json=instanceUid,proto3" json:"instance_uid,omitempty"`
+	Labels            map[string]string      `protobuf:"bytes,5,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	StartedAtUnixNano int64                  `protobuf:"varint,6,opt,name=started_at_unix_nano,json=startedAtUnixNano,proto3" json:"started_at_unix_nano,omitempty"`
+	UptimeSeconds     int64                  `protobuf:"varint,7,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *AgentStatus) Reset() {
+	*x = AgentStatus{}
+	mi := &file_api_control_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentStatus) ProtoMessage() {}
+
+func (x *AgentStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentStatus.ProtoReflect.Descriptor instead.
+func (*AgentStatus) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *AgentStatus) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *AgentStatus) GetGoVersion() string {
+	if x != nil {
+		return x.GoVersion
+	}
+	return ""
+}
+
+func (x *AgentStatus) GetRevision() string {
+	if x != nil {
+		return x.Revision
+	}
+	return ""
+}
+
+func (x *AgentStatus) GetInstanceUid() string {
+	if x != nil {
+		return x.InstanceUid
+	}
+	return ""
+}
+
+func (x *AgentStatus) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *AgentStatus) GetStartedAtUnixNano() int64 {
+	if x != nil {
+		return x.StartedAtUnixNano
+	}
+	return 0
+}
+
+func (x *AgentStatus) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+type ConnectionStatus struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	Connected                bool                   `protobuf:"varint,1,opt,name=connected,proto3" json:"connected,omitempty"`
+	Connects                 int32                  `protobuf:"varint,2,opt,name=connects,proto3" json:"connects,omitempty"`
+	Disconnects              int32                  `protobuf:"varint,3,opt,name=disconnects,proto3" json:"disconnects,omitempty"`
+	FailedConnects           int32                  `protobuf:"varint,4,opt,name=failed_connects,json=failedConnects,proto3" json:"failed_connects,omitempty"`
+	LastError                string                 `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	Queued                   int32                  `protobuf:"varint,6,opt,name=queued,proto3" json:"queued,omitempty"`
+	Outbox                   int32                  `protobuf:"varint,7,opt,name=outbox,proto3" json:"outbox,omitempty"`
+	OutboxDropped            uint64                 `protobuf:"varint,8,opt,name=outbox_dropped,json=outboxDropped,proto3" json:"outbox_dropped,omitempty"`
+	ProtocolVersion          uint32                 `protobuf:"varint,9,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	HeartbeatIntervalSeconds float64                `protobuf:"fixed64,10,opt,name=heartbeat_interval_seconds,json=heartbeatIntervalSeconds,proto3" json:"heartbeat_interval_seconds,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *ConnectionStatus) Reset() {
+	*x = ConnectionStatus{}
+	mi := &file_api_control_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectionStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectionStatus) ProtoMessage() {}
+
+func (x *ConnectionStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectionStatus.ProtoReflect.Descriptor instead.
+func (*ConnectionStatus) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ConnectionStatus) GetConnected() bool {
+	if x != nil {
+		return x.Connected
+	}
+	return false
+}
+
+func (x *ConnectionStatus) GetConnects() int32 {
+	if x != nil {
+		return x.Connects
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetDisconnects() int32 {
+	if x != nil {
+		return x.Disconnects
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetFailedConnects() int32 {
+	if x != nil {
+		return x.FailedConnects
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *ConnectionStatus) GetQueued() int32 {
+	if x != nil {
+		return x.Queued
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetOutbox() int32 {
+	if x != nil {
+		return x.Outbox
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetOutboxDropped() uint64 {
+	if x != nil {
+		return x.OutboxDropped
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *ConnectionStatus) GetHeartbeatIntervalSeconds() float64 {
+	if x != nil {
+		return x.HeartbeatIntervalSeconds
+	}
+	return 0
+}
+
+type ConfigStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Hash  string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	// Outcome of the most recent push, all unset before the first
+	LastApplyHash       string `protobuf:"bytes,2,opt,name=last_apply_hash,json=lastApplyHash,proto3" json:"last_apply_hash,omitempty"`
+	LastApplyOutcome    string `protobuf:"bytes,3,opt,name=last_apply_outcome,json=lastApplyOutcome,proto3" json:"last_apply_outcome,omitempty"`
+	LastApplySuccess    bool   `protobuf:"varint,4,opt,name=last_apply_success,json=lastApplySuccess,proto3" json:"last_apply_success,omitempty"`
+	LastApplyError      string `protobuf:"bytes,5,opt,name=last_apply_error,json=lastApplyError,proto3" json:"last_apply_error,omitempty"`
+	LastApplyAtUnixNano int64  `protobuf:"varint,6,opt,name=last_apply_at_unix_nano,json=lastApplyAtUnixNano,proto3" json:"last_apply_at_unix_nano,omitempty"`
+	LastApplyDurationMs int64  `protobuf:"varint,7,opt,name=last_apply_duration_ms,json=lastApplyDurationMs,proto3" json:"last_apply_duration_ms,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ConfigStatus) Reset() {
+	*x = ConfigStatus{}
+	mi := &file_api_control_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigStatus) ProtoMessage() {}
+
+func (x *ConfigStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfigStatus.ProtoReflect.Descriptor instead.
+func (*ConfigStatus) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ConfigStatus) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *ConfigStatus) GetLastApplyHash() string {
+	if x != nil {
+		return x.LastApplyHash
+	}
+	return ""
+}
+
+func (x *ConfigStatus) GetLastApplyOutcome() string {
+	if x != nil {
+		return x.LastApplyOutcome
+	}
+	return ""
+}
+
+func (x *ConfigStatus) GetLastApplySuccess() bool {
+	if x != nil {
+		return x.LastApplySuccess
+	}
+	return false
+}
+
+func (x *ConfigStatus) GetLastApplyError() string {
+	if x != nil {
+		return x.LastApplyError
+	}
+	return ""
+}
+
+func (x *ConfigStatus) GetLastApplyAtUnixNano() int64 {
+	if x != nil {
+		return x.LastApplyAtUnixNano
+	}
+	return 0
+}
+
+func (x *ConfigStatus) GetLastApplyDurationMs() int64 {
+	if x != nil {
+		return x.LastApplyDurationMs
+	}
+	return 0
+}
+
+type CollectorStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Capabilities  []string               `protobuf:"bytes,2,rep,name=capabilities,proto3" json:"capabilities,omitempty"`
+	Healthy       bool                   `protobuf:"varint,3,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	HealthStatus  string                 `protobuf:"bytes,4,opt,name=health_status,json=healthStatus,proto3" json:"health_status,omitempty"`
+	UptimeSeconds int64                  `protobuf:"varint,5,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	Process       *ProcessStatus         `protobuf:"bytes,6,opt,name=process,proto3" json:"process,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CollectorStatus) Reset() {
+	*x = CollectorStatus{}
+	mi := &file_api_control_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CollectorStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CollectorStatus) ProtoMessage() {}
+
+func (x *CollectorStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CollectorStatus.ProtoReflect.Descriptor instead.
+func (*CollectorStatus) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *CollectorStatus) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CollectorStatus) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+func (x *CollectorStatus) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *CollectorStatus) GetHealthStatus() string {
+	if x != nil {
+		return x.HealthStatus
+	}
+	return ""
+}
+
+func (x *CollectorStatus) GetUptimeSeconds() int64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *CollectorStatus) GetProcess() *ProcessStatus {
+	if x != nil {
+		return x.Process
+	}
+	return nil
+}
+
+type ProcessStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Command       string                 `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Pid           int32                  `protobuf:"varint,2,opt,name=pid,proto3" json:"pid,omitempty"`
+	Running       bool                   `protobuf:"varint,3,opt,name=running,proto3" json:"running,omitempty"`
+	Restarts      int32                  `protobuf:"varint,4,opt,name=restarts,proto3" json:"restarts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessStatus) Reset() {
+	*x = ProcessStatus{}
+	mi := &file_api_control_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessStatus) ProtoMessage() {}
+
+func (x *ProcessStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessStatus.ProtoReflect.Descriptor instead.
+func (*ProcessStatus) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ProcessStatus) GetCommand() string {
+	if x != nil {
+		return x.Command
+	}
+	return ""
+}
+
+func (x *ProcessStatus) GetPid() int32 {
+	if x != nil {
+		return x.Pid
+	}
+	return 0
+}
+
+func (x *ProcessStatus) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *ProcessStatus) GetRestarts() int32 {
+	if x != nil {
+		return x.Restarts
+	}
+	return 0
+}
+
+type HostStatus struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Hostname             string                 `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Os                   string                 `protobuf:"bytes,2,opt,name=os,proto3" json:"os,omitempty"`
+	Arch                 string                 `protobuf:"bytes,3,opt,name=arch,proto3" json:"arch,omitempty"`
+	OsRelease            string                 `protobuf:"bytes,4,opt,name=os_release,json=osRelease,proto3" json:"os_release,omitempty"`
+	Kernel               string                 `protobuf:"bytes,5,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	UptimeSeconds        float64                `protobuf:"fixed64,6,opt,name=uptime_seconds,json=uptimeSeconds,proto3" json:"uptime_seconds,omitempty"`
+	Cpus                 int32                  `protobuf:"varint,7,opt,name=cpus,proto3" json:"cpus,omitempty"`
+	LoadAverage          []float64              `protobuf:"fixed64,8,rep,packed,name=load_average,json=loadAverage,proto3" json:"load_average,omitempty"`
+	MemoryTotalBytes     uint64                 `protobuf:"varint,9,opt,name=memory_total_bytes,json=memoryTotalBytes,proto3" json:"memory_total_bytes,omitempty"`
+	MemoryAvailableBytes uint64                 `protobuf:"varint,10,opt,name=memory_available_bytes,json=memoryAvailableBytes,proto3" json:"memory_available_bytes,omitempty"`
+	DiskTotalBytes       uint64                 `protobuf:"varint,11,opt,name=disk_total_bytes,json=diskTotalBytes,proto3" json:"disk_total_bytes,omitempty"`
+	DiskFreeBytes        uint64                 `protobuf:"varint,12,opt,name=disk_free_bytes,json=diskFreeBytes,proto3" json:"disk_free_bytes,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *HostStatus) Reset() {
+	*x = HostStatus{}
+	mi := &file_api_control_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HostStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HostStatus) ProtoMessage() {}
+
+func (x *HostStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HostStatus.ProtoReflect.Descriptor instead.
+func (*HostStatus) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *HostStatus) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *HostStatus) GetOs() string {
+	if x != nil {
+		return x.Os
+	}
+	return ""
+}
+
+func (x *HostStatus) GetArch() string {
+	if x != nil {
+		return x.Arch
+	}
+	return ""
+}
+
+func (x *HostStatus) GetOsRelease() string {
+	if x != nil {
+		return x.OsRelease
+	}
+	return ""
+}
+
+func (x *HostStatus) GetKernel() string {
+	if x != nil {
+		return x.Kernel
+	}
+	return ""
+}
+
+func (x *HostStatus) GetUptimeSeconds() float64 {
+	if x != nil {
+		return x.UptimeSeconds
+	}
+	return 0
+}
+
+func (x *HostStatus) GetCpus() int32 {
+	if x != nil {
+		return x.Cpus
+	}
+	return 0
+}
+
+func (x *HostStatus) GetLoadAverage() []float64 {
+	if x != nil {
+		return x.LoadAverage
+	}
+	return nil
+}
+
+func (x *HostStatus) GetMemoryTotalBytes() uint64 {
+	if x != nil {
+		return x.MemoryTotalBytes
+	}
+	return 0
+}
+
+func (x *HostStatus) GetMemoryAvailableBytes() uint64 {
+	if x != nil {
+		return x.MemoryAvailableBytes
+	}
+	return 0
+}
+
+func (x *HostStatus) GetDiskTotalBytes() uint64 {
+	if x != nil {
+		return x.DiskTotalBytes
+	}
+	return 0
+}
+
+func (x *HostStatus) GetDiskFreeBytes() uint64 {
+	if x != nil {
+		return x.DiskFreeBytes
+	}
+	return 0
+}
+
+type ActionReport struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Action              string                 `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"` // RestartCollector, RestartAgent or Reboot
+	RequestedAtUnixNano int64                  `protobuf:"varint,2,opt,name=requested_at_unix_nano,json=requestedAtUnixNano,proto3" json:"requested_at_unix_nano,omitempty"`
+	CompletedAtUnixNano int64                  `protobuf:"varint,3,opt,name=completed_at_unix_nano,json=completedAtUnixNano,proto3" json:"completed_at_unix_nano,omitempty"` // 0 in the acknowledgement
+	RebootChecked       bool                   `protobuf:"varint,4,opt,name=reboot_checked,json=rebootChecked,proto3" json:"reboot_checked,omitempty"`                       // whether rebooted could be told
+	Rebooted            bool                   `protobuf:"varint,5,opt,name=rebooted,proto3" json:"rebooted,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ActionReport) Reset() {
+	*x = ActionReport{}
+	mi := &file_api_control_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActionReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActionReport) ProtoMessage() {}
+
+func (x *ActionReport) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActionReport.ProtoReflect.Descriptor instead.
+func (*ActionReport) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ActionReport) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *ActionReport) GetRequestedAtUnixNano() int64 {
+	if x != nil {
+		return x.RequestedAtUnixNano
+	}
+	return 0
+}
+
+func (x *ActionReport) GetCompletedAtUnixNano() int64 {
+	if x != nil {
+		return x.CompletedAtUnixNano
+	}
+	return 0
+}
+
+func (x *ActionReport) GetRebootChecked() bool {
+	if x != nil {
+		return x.RebootChecked
+	}
+	return false
+}
+
+func (x *ActionReport) GetRebooted() bool {
+	if x != nil {
+		return x.Rebooted
+	}
+	return false
+}
+
+type LogChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Chunk         int32                  `protobuf:"varint,2,opt,name=chunk,proto3" json:"chunk,omitempty"` // 1-based
+	Chunks        int32                  `protobuf:"varint,3,opt,name=chunks,proto3" json:"chunks,omitempty"`
+	Lines         []string               `protobuf:"bytes,4,rep,name=lines,proto3" json:"lines,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogChunk) Reset() {
+	*x = LogChunk{}
+	mi := &file_api_control_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogChunk) ProtoMessage() {}
+
+func (x *LogChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogChunk.ProtoReflect.Descriptor instead.
+func (*LogChunk) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *LogChunk) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *LogChunk) GetChunk() int32 {
+	if x != nil {
+		return x.Chunk
+	}
+	return 0
+}
+
+func (x *LogChunk) GetChunks() int32 {
+	if x != nil {
+		return x.Chunks
+	}
+	return 0
+}
+
+func (x *LogChunk) GetLines() []string {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+type LogLevelChange struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Level            string                 `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	Previous         string                 `protobuf:"bytes,2,opt,name=previous,proto3" json:"previous,omitempty"`
+	RevertAtUnixNano int64                  `protobuf:"varint,3,opt,name=revert_at_unix_nano,json=revertAtUnixNano,proto3" json:"revert_at_unix_nano,omitempty"` // 0 = permanent
+	RevertTo         string                 `protobuf:"bytes,4,opt,name=revert_to,json=revertTo,proto3" json:"revert_to,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *LogLevelChange) Reset() {
+	*x = LogLevelChange{}
+	mi := &file_api_control_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogLevelChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogLevelChange) ProtoMessage() {}
+
+func (x *LogLevelChange) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogLevelChange.ProtoReflect.Descriptor instead.
+func (*LogLevelChange) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *LogLevelChange) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *LogLevelChange) GetPrevious() string {
+	if x != nil {
+		return x.Previous
+	}
+	return ""
+}
+
+func (x *LogLevelChange) GetRevertAtUnixNano() int64 {
+	if x != nil {
+		return x.RevertAtUnixNano
+	}
+	return 0
+}
+
+func (x *LogLevelChange) GetRevertTo() string {
+	if x != nil {
+		return x.RevertTo
+	}
+	return ""
+}
+
+type PluginCounters struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Records       float64                `protobuf:"fixed64,1,opt,name=records,proto3" json:"records,omitempty"`
+	Bytes         float64                `protobuf:"fixed64,2,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	Retries       float64                `protobuf:"fixed64,3,opt,name=retries,proto3" json:"retries,omitempty"`
+	Errors        float64                `protobuf:"fixed64,4,opt,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginCounters) Reset() {
+	*x = PluginCounters{}
+	mi := &file_api_control_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginCounters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginCounters) ProtoMessage() {}
+
+func (x *PluginCounters) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginCounters.ProtoReflect.Descriptor instead.
+func (*PluginCounters) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *PluginCounters) GetRecords() float64 {
+	if x != nil {
+		return x.Records
+	}
+	return 0
+}
+
+func (x *PluginCounters) GetBytes() float64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *PluginCounters) GetRetries() float64 {
+	if x != nil {
+		return x.Retries
+	}
+	return 0
+}
+
+func (x *PluginCounters) GetErrors() float64 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+type PluginMetrics struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         *PluginCounters        `protobuf:"bytes,1,opt,name=total,proto3" json:"total,omitempty"`
+	Delta         *PluginCounters        `protobuf:"bytes,2,opt,name=delta,proto3" json:"delta,omitempty"` // since the previous scrape, unset on the first
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PluginMetrics) Reset() {
+	*x = PluginMetrics{}
+	mi := &file_api_control_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PluginMetrics) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PluginMetrics) ProtoMessage() {}
+
+func (x *PluginMetrics) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PluginMetrics.ProtoReflect.Descriptor instead.
+func (*PluginMetrics) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *PluginMetrics) GetTotal() *PluginCounters {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *PluginMetrics) GetDelta() *PluginCounters {
+	if x != nil {
+		return x.Delta
+	}
+	return nil
+}
+
+type MetricsReport struct {
+	state           protoimpl.MessageState    `protogen:"open.v1"`
+	Source          string                    `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	IntervalSeconds float64                   `protobuf:"fixed64,2,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"`
+	Values          map[string]float64        `protobuf:"bytes,3,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"` // drivers without plugin detail
+	Inputs          map[string]*PluginMetrics `protobuf:"bytes,4,rep,name=inputs,proto3" json:"inputs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Outputs         map[string]*PluginMetrics `protobuf:"bytes,5,rep,name=outputs,proto3" json:"outputs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *MetricsReport) Reset() {
+	*x = MetricsReport{}
+	mi := &file_api_control_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *EdgeIdentity) String() string {
+func (x *MetricsReport) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*EdgeIdentity) ProtoMessage() {}
+func (*MetricsReport) ProtoMessage() {}
 
-func (x *EdgeIdentity) ProtoReflect() protoreflect.Message {
-	mi := &file_api_control_proto_msgTypes[0]
+func (x *MetricsReport) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -56,63 +2274,160 @@ func (x *EdgeIdentity) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use EdgeIdentity.ProtoReflect.Descriptor instead.
-func (*EdgeIdentity) Descriptor() ([]byte, []int) {
-	return file_api_control_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use MetricsReport.ProtoReflect.Descriptor instead.
+func (*MetricsReport) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{26}
 }
 
-func (x *EdgeIdentity) GetNodeId() string {
+func (x *MetricsReport) GetSource() string {
 	if x != nil {
-		return x.NodeId
+		return x.Source
 	}
 	return ""
 }
 
-func (x *EdgeIdentity) GetVersion() string {
+func (x *MetricsReport) GetIntervalSeconds() float64 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+func (x *MetricsReport) GetValues() map[string]float64 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+func (x *MetricsReport) GetInputs() map[string]*PluginMetrics {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *MetricsReport) GetOutputs() map[string]*PluginMetrics {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+type HistoryEntry struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Version           int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Hash              string                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	TimestampUnixNano int64                  `protobuf:"varint,3,opt,name=timestamp_unix_nano,json=timestampUnixNano,proto3" json:"timestamp_unix_nano,omitempty"`
+	CorrelationId     string                 `protobuf:"bytes,4,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	Outcome           string                 `protobuf:"bytes,5,opt,name=outcome,proto3" json:"outcome,omitempty"`
+	Error             string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	Size              int32                  `protobuf:"varint,7,opt,name=size,proto3" json:"size,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *HistoryEntry) Reset() {
+	*x = HistoryEntry{}
+	mi := &file_api_control_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HistoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryEntry) ProtoMessage() {}
+
+func (x *HistoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryEntry.ProtoReflect.Descriptor instead.
+func (*HistoryEntry) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *HistoryEntry) GetVersion() int32 {
 	if x != nil {
 		return x.Version
 	}
+	return 0
+}
+
+func (x *HistoryEntry) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
 	return ""
 }
 
-func (x *EdgeIdentity) GetPlatform() string {
+func (x *HistoryEntry) GetTimestampUnixNano() int64 {
 	if x != nil {
-		return x.Platform
+		return x.TimestampUnixNano
+	}
+	return 0
+}
+
+func (x *HistoryEntry) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
 	}
 	return ""
 }
 
-func (x *EdgeIdentity) GetAgentType() string {
+func (x *HistoryEntry) GetOutcome() string {
 	if x != nil {
-		return x.AgentType
+		return x.Outcome
 	}
 	return ""
 }
 
-type Command struct {
+func (x *HistoryEntry) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+type ConfigHistory struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
-	Payload       string                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
-	CorrelationId string                 `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	Versions      []*HistoryEntry        `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Command) Reset() {
-	*x = Command{}
-	mi := &file_api_control_proto_msgTypes[1]
+func (x *ConfigHistory) Reset() {
+	*x = ConfigHistory{}
+	mi := &file_api_control_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Command) String() string {
+func (x *ConfigHistory) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Command) ProtoMessage() {}
+func (*ConfigHistory) ProtoMessage() {}
 
-func (x *Command) ProtoReflect() protoreflect.Message {
-	mi := &file_api_control_proto_msgTypes[1]
+func (x *ConfigHistory) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -123,57 +2438,103 @@ func (x *Command) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Command.ProtoReflect.Descriptor instead.
-func (*Command) Descriptor() ([]byte, []int) {
-	return file_api_control_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use ConfigHistory.ProtoReflect.Descriptor instead.
+func (*ConfigHistory) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *Command) GetType() string {
+func (x *ConfigHistory) GetVersions() []*HistoryEntry {
 	if x != nil {
-		return x.Type
+		return x.Versions
 	}
-	return ""
+	return nil
 }
 
-func (x *Command) GetPayload() string {
+type ConfigDiff struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	From          int32                  `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To            int32                  `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+	Diff          string                 `protobuf:"bytes,3,opt,name=diff,proto3" json:"diff,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfigDiff) Reset() {
+	*x = ConfigDiff{}
+	mi := &file_api_control_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfigDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfigDiff) ProtoMessage() {}
+
+func (x *ConfigDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[29]
 	if x != nil {
-		return x.Payload
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *Command) GetCorrelationId() string {
+// Deprecated: Use ConfigDiff.ProtoReflect.Descriptor instead.
+func (*ConfigDiff) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ConfigDiff) GetFrom() int32 {
 	if x != nil {
-		return x.CorrelationId
+		return x.From
+	}
+	return 0
+}
+
+func (x *ConfigDiff) GetTo() int32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *ConfigDiff) GetDiff() string {
+	if x != nil {
+		return x.Diff
 	}
 	return ""
 }
 
-type Event struct {
+type ConfigRollback struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
-	Payload       string                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
-	CorrelationId string                 `protobuf:"bytes,3,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
-	TsUnixNano    int64                  `protobuf:"varint,4,opt,name=ts_unix_nano,json=tsUnixNano,proto3" json:"ts_unix_nano,omitempty"`
+	Version       int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Hash          string                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Success       bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Event) Reset() {
-	*x = Event{}
-	mi := &file_api_control_proto_msgTypes[2]
+func (x *ConfigRollback) Reset() {
+	*x = ConfigRollback{}
+	mi := &file_api_control_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Event) String() string {
+func (x *ConfigRollback) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Event) ProtoMessage() {}
+func (*ConfigRollback) ProtoMessage() {}
 
-func (x *Event) ProtoReflect() protoreflect.Message {
-	mi := &file_api_control_proto_msgTypes[2]
+func (x *ConfigRollback) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -184,37 +2545,37 @@ func (x *Event) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Event.ProtoReflect.Descriptor instead.
-func (*Event) Descriptor() ([]byte, []int) {
-	return file_api_control_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ConfigRollback.ProtoReflect.Descriptor instead.
+func (*ConfigRollback) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{30}
 }
 
-func (x *Event) GetType() string {
+func (x *ConfigRollback) GetVersion() int32 {
 	if x != nil {
-		return x.Type
+		return x.Version
 	}
-	return ""
+	return 0
 }
 
-func (x *Event) GetPayload() string {
+func (x *ConfigRollback) GetHash() string {
 	if x != nil {
-		return x.Payload
+		return x.Hash
 	}
 	return ""
 }
 
-func (x *Event) GetCorrelationId() string {
+func (x *ConfigRollback) GetSuccess() bool {
 	if x != nil {
-		return x.CorrelationId
+		return x.Success
 	}
-	return ""
+	return false
 }
 
-func (x *Event) GetTsUnixNano() int64 {
+func (x *ConfigRollback) GetError() string {
 	if x != nil {
-		return x.TsUnixNano
+		return x.Error
 	}
-	return 0
+	return ""
 }
 
 // Config push from supervisor to device
@@ -230,7 +2591,7 @@ type ConfigPush struct {
 
 func (x *ConfigPush) Reset() {
 	*x = ConfigPush{}
-	mi := &file_api_control_proto_msgTypes[3]
+	mi := &file_api_control_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -242,7 +2603,7 @@ func (x *ConfigPush) String() string {
 func (*ConfigPush) ProtoMessage() {}
 
 func (x *ConfigPush) ProtoReflect() protoreflect.Message {
-	mi := &file_api_control_proto_msgTypes[3]
+	mi := &file_api_control_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -255,7 +2616,7 @@ func (x *ConfigPush) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ConfigPush.ProtoReflect.Descriptor instead.
 func (*ConfigPush) Descriptor() ([]byte, []int) {
-	return file_api_control_proto_rawDescGZIP(), []int{3}
+	return file_api_control_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *ConfigPush) GetDeviceId() string {
@@ -294,13 +2655,16 @@ type ConfigAck struct {
 	Success         bool                   `protobuf:"varint,3,opt,name=success,proto3" json:"success,omitempty"`
 	ErrorMessage    string                 `protobuf:"bytes,4,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
 	EffectiveConfig []byte                 `protobuf:"bytes,5,opt,name=effective_config,json=effectiveConfig,proto3" json:"effective_config,omitempty"` // What's actually running
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+	// Correlation id of the UpdateConfig command, or the pushed config_hash
+	// for a ConfigPush; config_hash reports the effective config instead
+	CorrelationId string `protobuf:"bytes,6,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ConfigAck) Reset() {
 	*x = ConfigAck{}
-	mi := &file_api_control_proto_msgTypes[4]
+	mi := &file_api_control_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -312,7 +2676,7 @@ func (x *ConfigAck) String() string {
 func (*ConfigAck) ProtoMessage() {}
 
 func (x *ConfigAck) ProtoReflect() protoreflect.Message {
-	mi := &file_api_control_proto_msgTypes[4]
+	mi := &file_api_control_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -325,7 +2689,7 @@ func (x *ConfigAck) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ConfigAck.ProtoReflect.Descriptor instead.
 func (*ConfigAck) Descriptor() ([]byte, []int) {
-	return file_api_control_proto_rawDescGZIP(), []int{4}
+	return file_api_control_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *ConfigAck) GetDeviceId() string {
@@ -363,6 +2727,76 @@ func (x *ConfigAck) GetEffectiveConfig() []byte {
 	return nil
 }
 
+func (x *ConfigAck) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+// Liveness signal sent by both sides on the negotiated heartbeat interval
+// (protocol version 3). A side that hears nothing from its peer for a few
+// intervals drops the stream and reconnects.
+type Heartbeat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`                     // per stream, starting at 1
+	AckSeq        uint64                 `protobuf:"varint,2,opt,name=ack_seq,json=ackSeq,proto3" json:"ack_seq,omitempty"` // last seq received from the peer
+	TsUnixNano    int64                  `protobuf:"varint,3,opt,name=ts_unix_nano,json=tsUnixNano,proto3" json:"ts_unix_nano,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
+	mi := &file_api_control_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Heartbeat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Heartbeat) ProtoMessage() {}
+
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
+	mi := &file_api_control_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
+	return file_api_control_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *Heartbeat) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetAckSeq() uint64 {
+	if x != nil {
+		return x.AckSeq
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetTsUnixNano() int64 {
+	if x != nil {
+		return x.TsUnixNano
+	}
+	return 0
+}
+
 type Envelope struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Body:
@@ -372,6 +2806,8 @@ type Envelope struct {
 	//	*Envelope_Event
 	//	*Envelope_ConfigPush
 	//	*Envelope_ConfigAck
+	//	*Envelope_RegisterAck
+	//	*Envelope_Heartbeat
 	Body          isEnvelope_Body `protobuf_oneof:"body"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -379,7 +2815,7 @@ type Envelope struct {
 
 func (x *Envelope) Reset() {
 	*x = Envelope{}
-	mi := &file_api_control_proto_msgTypes[5]
+	mi := &file_api_control_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -391,7 +2827,7 @@ func (x *Envelope) String() string {
 func (*Envelope) ProtoMessage() {}
 
 func (x *Envelope) ProtoReflect() protoreflect.Message {
-	mi := &file_api_control_proto_msgTypes[5]
+	mi := &file_api_control_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -404,7 +2840,7 @@ func (x *Envelope) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
 func (*Envelope) Descriptor() ([]byte, []int) {
-	return file_api_control_proto_rawDescGZIP(), []int{5}
+	return file_api_control_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *Envelope) GetBody() isEnvelope_Body {
@@ -459,6 +2895,24 @@ func (x *Envelope) GetConfigAck() *ConfigAck {
 	return nil
 }
 
+func (x *Envelope) GetRegisterAck() *RegisterAck {
+	if x != nil {
+		if x, ok := x.Body.(*Envelope_RegisterAck); ok {
+			return x.RegisterAck
+		}
+	}
+	return nil
+}
+
+func (x *Envelope) GetHeartbeat() *Heartbeat {
+	if x != nil {
+		if x, ok := x.Body.(*Envelope_Heartbeat); ok {
+			return x.Heartbeat
+		}
+	}
+	return nil
+}
+
 type isEnvelope_Body interface {
 	isEnvelope_Body()
 }
@@ -483,6 +2937,14 @@ type Envelope_ConfigAck struct {
 	ConfigAck *ConfigAck `protobuf:"bytes,5,opt,name=config_ack,json=configAck,proto3,oneof"` // edge -> supervisor (config applied)
 }
 
+type Envelope_RegisterAck struct {
+	RegisterAck *RegisterAck `protobuf:"bytes,6,opt,name=register_ack,json=registerAck,proto3,oneof"` // supervisor -> edge (reply to register)
+}
+
+type Envelope_Heartbeat struct {
+	Heartbeat *Heartbeat `protobuf:"bytes,7,opt,name=heartbeat,proto3,oneof"` // both directions
+}
+
 func (*Envelope_Register) isEnvelope_Body() {}
 
 func (*Envelope_Command) isEnvelope_Body() {}
@@ -493,27 +2955,234 @@ func (*Envelope_ConfigPush) isEnvelope_Body() {}
 
 func (*Envelope_ConfigAck) isEnvelope_Body() {}
 
+func (*Envelope_RegisterAck) isEnvelope_Body() {}
+
+func (*Envelope_Heartbeat) isEnvelope_Body() {}
+
 var File_api_control_proto protoreflect.FileDescriptor
 
 const file_api_control_proto_rawDesc = "" +
 	"\n" +
-	"\x11api/control.proto\x12\acontrol\"|\n" +
+	"\x11api/control.proto\x12\acontrol\"\xe2\x03\n" +
 	"\fEdgeIdentity\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x18\n" +
 	"\aversion\x18\x02 \x01(\tR\aversion\x12\x1a\n" +
 	"\bplatform\x18\x03 \x01(\tR\bplatform\x12\x1d\n" +
 	"\n" +
-	"agent_type\x18\x04 \x01(\tR\tagentType\"^\n" +
+	"agent_type\x18\x04 \x01(\tR\tagentType\x12\"\n" +
+	"\fcapabilities\x18\x05 \x03(\tR\fcapabilities\x12!\n" +
+	"\finstance_uid\x18\x06 \x01(\tR\vinstanceUid\x12\x1a\n" +
+	"\bhostname\x18\a \x01(\tR\bhostname\x12\x1d\n" +
+	"\n" +
+	"os_release\x18\b \x01(\tR\tosRelease\x129\n" +
+	"\x06labels\x18\t \x03(\v2!.control.EdgeIdentity.LabelsEntryR\x06labels\x12)\n" +
+	"\x10protocol_version\x18\n" +
+	" \x01(\rR\x0fprotocolVersion\x12\x1a\n" +
+	"\bcommands\x18\v \x03(\tR\bcommands\x12%\n" +
+	"\x0econfig_formats\x18\f \x03(\tR\rconfigFormats\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x9c\x01\n" +
+	"\vRegisterAck\x12)\n" +
+	"\x10protocol_version\x18\x01 \x01(\rR\x0fprotocolVersion\x122\n" +
+	"\x15heartbeat_interval_ms\x18\x02 \x01(\x03R\x13heartbeatIntervalMs\x12.\n" +
+	"\x13desired_config_hash\x18\x03 \x01(\tR\x11desiredConfigHash\"\xc5\x05\n" +
 	"\aCommand\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
 	"\apayload\x18\x02 \x01(\tR\apayload\x12%\n" +
-	"\x0ecorrelation_id\x18\x03 \x01(\tR\rcorrelationId\"~\n" +
+	"\x0ecorrelation_id\x18\x03 \x01(\tR\rcorrelationId\x12@\n" +
+	"\ffetch_status\x18\n" +
+	" \x01(\v2\x1b.control.FetchStatusRequestH\x00R\vfetchStatus\x12C\n" +
+	"\rupdate_config\x18\v \x01(\v2\x1c.control.UpdateConfigRequestH\x00R\fupdateConfig\x123\n" +
+	"\arestart\x18\f \x01(\v2\x17.control.RestartRequestH\x00R\arestart\x12:\n" +
+	"\n" +
+	"fetch_logs\x18\r \x01(\v2\x19.control.FetchLogsRequestH\x00R\tfetchLogs\x12A\n" +
+	"\rset_log_level\x18\x0e \x01(\v2\x1b.control.SetLogLevelRequestH\x00R\vsetLogLevel\x12C\n" +
+	"\rfetch_metrics\x18\x0f \x01(\v2\x1c.control.FetchMetricsRequestH\x00R\ffetchMetrics\x12S\n" +
+	"\x13list_config_history\x18\x10 \x01(\v2!.control.ListConfigHistoryRequestH\x00R\x11listConfigHistory\x12=\n" +
+	"\vdiff_config\x18\x11 \x01(\v2\x1a.control.DiffConfigRequestH\x00R\n" +
+	"diffConfig\x12I\n" +
+	"\x0frollback_config\x18\x12 \x01(\v2\x1e.control.RollbackConfigRequestH\x00R\x0erollbackConfigB\x06\n" +
+	"\x04body\"\x14\n" +
+	"\x12FetchStatusRequest\"W\n" +
+	"\x13UpdateConfigRequest\x12\x1f\n" +
+	"\vconfig_data\x18\x01 \x01(\fR\n" +
+	"configData\x12\x1f\n" +
+	"\vconfig_hash\x18\x02 \x01(\tR\n" +
+	"configHash\"@\n" +
+	"\x0eRestartRequest\x12.\n" +
+	"\x06target\x18\x01 \x01(\x0e2\x16.control.RestartTargetR\x06target\"\x9a\x01\n" +
+	"\x10FetchLogsRequest\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x14\n" +
+	"\x05lines\x18\x02 \x01(\x05R\x05lines\x12&\n" +
+	"\x0fsince_unix_nano\x18\x03 \x01(\x03R\rsinceUnixNano\x12\x1c\n" +
+	"\n" +
+	"max_age_ms\x18\x04 \x01(\x03R\bmaxAgeMs\x12\x12\n" +
+	"\x04grep\x18\x05 \x01(\tR\x04grep\"A\n" +
+	"\x12SetLogLevelRequest\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\tR\x05level\x12\x15\n" +
+	"\x06ttl_ms\x18\x02 \x01(\x03R\x05ttlMs\"\x15\n" +
+	"\x13FetchMetricsRequest\"\x1a\n" +
+	"\x18ListConfigHistoryRequest\"7\n" +
+	"\x11DiffConfigRequest\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x05R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x05R\x02to\"1\n" +
+	"\x15RollbackConfigRequest\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\"\xbc\x05\n" +
 	"\x05Event\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x18\n" +
 	"\apayload\x18\x02 \x01(\tR\apayload\x12%\n" +
 	"\x0ecorrelation_id\x18\x03 \x01(\tR\rcorrelationId\x12 \n" +
 	"\fts_unix_nano\x18\x04 \x01(\x03R\n" +
-	"tsUnixNano\"\x8a\x01\n" +
+	"tsUnixNano\x12/\n" +
+	"\x06status\x18\n" +
+	" \x01(\v2\x15.control.StatusReportH\x00R\x06status\x12>\n" +
+	"\x0ecommand_failed\x18\v \x01(\v2\x15.control.CommandErrorH\x00R\rcommandFailed\x12@\n" +
+	"\x0fcommand_unknown\x18\f \x01(\v2\x15.control.CommandErrorH\x00R\x0ecommandUnknown\x12/\n" +
+	"\x06action\x18\r \x01(\v2\x15.control.ActionReportH\x00R\x06action\x12'\n" +
+	"\x04logs\x18\x0e \x01(\v2\x11.control.LogChunkH\x00R\x04logs\x126\n" +
+	"\tlog_level\x18\x0f \x01(\v2\x17.control.LogLevelChangeH\x00R\blogLevel\x122\n" +
+	"\ametrics\x18\x10 \x01(\v2\x16.control.MetricsReportH\x00R\ametrics\x12?\n" +
+	"\x0econfig_history\x18\x11 \x01(\v2\x16.control.ConfigHistoryH\x00R\rconfigHistory\x126\n" +
+	"\vconfig_diff\x18\x12 \x01(\v2\x13.control.ConfigDiffH\x00R\n" +
+	"configDiff\x12B\n" +
+	"\x0fconfig_rollback\x18\x13 \x01(\v2\x17.control.ConfigRollbackH\x00R\x0econfigRollbackB\x06\n" +
+	"\x04body\">\n" +
+	"\fCommandError\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\x91\x03\n" +
+	"\fStatusReport\x12%\n" +
+	"\x0eschema_version\x18\x01 \x01(\rR\rschemaVersion\x12\x1b\n" +
+	"\tdevice_id\x18\x02 \x01(\tR\bdeviceId\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12.\n" +
+	"\x13timestamp_unix_nano\x18\x04 \x01(\x03R\x11timestampUnixNano\x12*\n" +
+	"\x05agent\x18\x05 \x01(\v2\x14.control.AgentStatusR\x05agent\x129\n" +
+	"\n" +
+	"connection\x18\x06 \x01(\v2\x19.control.ConnectionStatusR\n" +
+	"connection\x12-\n" +
+	"\x06config\x18\a \x01(\v2\x15.control.ConfigStatusR\x06config\x126\n" +
+	"\tcollector\x18\b \x01(\v2\x18.control.CollectorStatusR\tcollector\x12'\n" +
+	"\x04host\x18\t \x01(\v2\x13.control.HostStatusR\x04host\"\xd2\x02\n" +
+	"\vAgentStatus\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\x12\x1d\n" +
+	"\n" +
+	"go_version\x18\x02 \x01(\tR\tgoVersion\x12\x1a\n" +
+	"\brevision\x18\x03 \x01(\tR\brevision\x12!\n" +
+	"\finstance_uid\x18\x04 \x01(\tR\vinstanceUid\x128\n" +
+	"\x06labels\x18\x05 \x03(\v2 .control.AgentStatus.LabelsEntryR\x06labels\x12/\n" +
+	"\x14started_at_unix_nano\x18\x06 \x01(\x03R\x11startedAtUnixNano\x12%\n" +
+	"\x0euptime_seconds\x18\a \x01(\x03R\ruptimeSeconds\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xf6\x02\n" +
+	"\x10ConnectionStatus\x12\x1c\n" +
+	"\tconnected\x18\x01 \x01(\bR\tconnected\x12\x1a\n" +
+	"\bconnects\x18\x02 \x01(\x05R\bconnects\x12 \n" +
+	"\vdisconnects\x18\x03 \x01(\x05R\vdisconnects\x12'\n" +
+	"\x0ffailed_connects\x18\x04 \x01(\x05R\x0efailedConnects\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x05 \x01(\tR\tlastError\x12\x16\n" +
+	"\x06queued\x18\x06 \x01(\x05R\x06queued\x12\x16\n" +
+	"\x06outbox\x18\a \x01(\x05R\x06outbox\x12%\n" +
+	"\x0eoutbox_dropped\x18\b \x01(\x04R\routboxDropped\x12)\n" +
+	"\x10protocol_version\x18\t \x01(\rR\x0fprotocolVersion\x12<\n" +
+	"\x1aheartbeat_interval_seconds\x18\n" +
+	" \x01(\x01R\x18heartbeatIntervalSeconds\"\xbb\x02\n" +
+	"\fConfigStatus\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12&\n" +
+	"\x0flast_apply_hash\x18\x02 \x01(\tR\rlastApplyHash\x12,\n" +
+	"\x12last_apply_outcome\x18\x03 \x01(\tR\x10lastApplyOutcome\x12,\n" +
+	"\x12last_apply_success\x18\x04 \x01(\bR\x10lastApplySuccess\x12(\n" +
+	"\x10last_apply_error\x18\x05 \x01(\tR\x0elastApplyError\x124\n" +
+	"\x17last_apply_at_unix_nano\x18\x06 \x01(\x03R\x13lastApplyAtUnixNano\x123\n" +
+	"\x16last_apply_duration_ms\x18\a \x01(\x03R\x13lastApplyDurationMs\"\xe1\x01\n" +
+	"\x0fCollectorStatus\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\"\n" +
+	"\fcapabilities\x18\x02 \x03(\tR\fcapabilities\x12\x18\n" +
+	"\ahealthy\x18\x03 \x01(\bR\ahealthy\x12#\n" +
+	"\rhealth_status\x18\x04 \x01(\tR\fhealthStatus\x12%\n" +
+	"\x0euptime_seconds\x18\x05 \x01(\x03R\ruptimeSeconds\x120\n" +
+	"\aprocess\x18\x06 \x01(\v2\x16.control.ProcessStatusR\aprocess\"q\n" +
+	"\rProcessStatus\x12\x18\n" +
+	"\acommand\x18\x01 \x01(\tR\acommand\x12\x10\n" +
+	"\x03pid\x18\x02 \x01(\x05R\x03pid\x12\x18\n" +
+	"\arunning\x18\x03 \x01(\bR\arunning\x12\x1a\n" +
+	"\brestarts\x18\x04 \x01(\x05R\brestarts\"\x97\x03\n" +
+	"\n" +
+	"HostStatus\x12\x1a\n" +
+	"\bhostname\x18\x01 \x01(\tR\bhostname\x12\x0e\n" +
+	"\x02os\x18\x02 \x01(\tR\x02os\x12\x12\n" +
+	"\x04arch\x18\x03 \x01(\tR\x04arch\x12\x1d\n" +
+	"\n" +
+	"os_release\x18\x04 \x01(\tR\tosRelease\x12\x16\n" +
+	"\x06kernel\x18\x05 \x01(\tR\x06kernel\x12%\n" +
+	"\x0euptime_seconds\x18\x06 \x01(\x01R\ruptimeSeconds\x12\x12\n" +
+	"\x04cpus\x18\a \x01(\x05R\x04cpus\x12!\n" +
+	"\fload_average\x18\b \x03(\x01R\vloadAverage\x12,\n" +
+	"\x12memory_total_bytes\x18\t \x01(\x04R\x10memoryTotalBytes\x124\n" +
+	"\x16memory_available_bytes\x18\n" +
+	" \x01(\x04R\x14memoryAvailableBytes\x12(\n" +
+	"\x10disk_total_bytes\x18\v \x01(\x04R\x0ediskTotalBytes\x12&\n" +
+	"\x0fdisk_free_bytes\x18\f \x01(\x04R\rdiskFreeBytes\"\xd3\x01\n" +
+	"\fActionReport\x12\x16\n" +
+	"\x06action\x18\x01 \x01(\tR\x06action\x123\n" +
+	"\x16requested_at_unix_nano\x18\x02 \x01(\x03R\x13requestedAtUnixNano\x123\n" +
+	"\x16completed_at_unix_nano\x18\x03 \x01(\x03R\x13completedAtUnixNano\x12%\n" +
+	"\x0ereboot_checked\x18\x04 \x01(\bR\rrebootChecked\x12\x1a\n" +
+	"\brebooted\x18\x05 \x01(\bR\brebooted\"f\n" +
+	"\bLogChunk\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x14\n" +
+	"\x05chunk\x18\x02 \x01(\x05R\x05chunk\x12\x16\n" +
+	"\x06chunks\x18\x03 \x01(\x05R\x06chunks\x12\x14\n" +
+	"\x05lines\x18\x04 \x03(\tR\x05lines\"\x8e\x01\n" +
+	"\x0eLogLevelChange\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\tR\x05level\x12\x1a\n" +
+	"\bprevious\x18\x02 \x01(\tR\bprevious\x12-\n" +
+	"\x13revert_at_unix_nano\x18\x03 \x01(\x03R\x10revertAtUnixNano\x12\x1b\n" +
+	"\trevert_to\x18\x04 \x01(\tR\brevertTo\"r\n" +
+	"\x0ePluginCounters\x12\x18\n" +
+	"\arecords\x18\x01 \x01(\x01R\arecords\x12\x14\n" +
+	"\x05bytes\x18\x02 \x01(\x01R\x05bytes\x12\x18\n" +
+	"\aretries\x18\x03 \x01(\x01R\aretries\x12\x16\n" +
+	"\x06errors\x18\x04 \x01(\x01R\x06errors\"m\n" +
+	"\rPluginMetrics\x12-\n" +
+	"\x05total\x18\x01 \x01(\v2\x17.control.PluginCountersR\x05total\x12-\n" +
+	"\x05delta\x18\x02 \x01(\v2\x17.control.PluginCountersR\x05delta\"\xeb\x03\n" +
+	"\rMetricsReport\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12)\n" +
+	"\x10interval_seconds\x18\x02 \x01(\x01R\x0fintervalSeconds\x12:\n" +
+	"\x06values\x18\x03 \x03(\v2\".control.MetricsReport.ValuesEntryR\x06values\x12:\n" +
+	"\x06inputs\x18\x04 \x03(\v2\".control.MetricsReport.InputsEntryR\x06inputs\x12=\n" +
+	"\aoutputs\x18\x05 \x03(\v2#.control.MetricsReport.OutputsEntryR\aoutputs\x1a9\n" +
+	"\vValuesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\x1aQ\n" +
+	"\vInputsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.control.PluginMetricsR\x05value:\x028\x01\x1aR\n" +
+	"\fOutputsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.control.PluginMetricsR\x05value:\x028\x01\"\xd7\x01\n" +
+	"\fHistoryEntry\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\tR\x04hash\x12.\n" +
+	"\x13timestamp_unix_nano\x18\x03 \x01(\x03R\x11timestampUnixNano\x12%\n" +
+	"\x0ecorrelation_id\x18\x04 \x01(\tR\rcorrelationId\x12\x18\n" +
+	"\aoutcome\x18\x05 \x01(\tR\aoutcome\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12\x12\n" +
+	"\x04size\x18\a \x01(\x05R\x04size\"B\n" +
+	"\rConfigHistory\x121\n" +
+	"\bversions\x18\x01 \x03(\v2\x15.control.HistoryEntryR\bversions\"D\n" +
+	"\n" +
+	"ConfigDiff\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\x05R\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\x05R\x02to\x12\x12\n" +
+	"\x04diff\x18\x03 \x01(\tR\x04diff\"n\n" +
+	"\x0eConfigRollback\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\tR\x04hash\x12\x18\n" +
+	"\asuccess\x18\x03 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"\x8a\x01\n" +
 	"\n" +
 	"ConfigPush\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x1f\n" +
@@ -522,14 +3191,20 @@ const file_api_control_proto_rawDesc = "" +
 	"\vconfig_hash\x18\x03 \x01(\tR\n" +
 	"configHash\x12\x1d\n" +
 	"\n" +
-	"agent_type\x18\x04 \x01(\tR\tagentType\"\xb3\x01\n" +
+	"agent_type\x18\x04 \x01(\tR\tagentType\"\xda\x01\n" +
 	"\tConfigAck\x12\x1b\n" +
 	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x1f\n" +
 	"\vconfig_hash\x18\x02 \x01(\tR\n" +
 	"configHash\x12\x18\n" +
 	"\asuccess\x18\x03 \x01(\bR\asuccess\x12#\n" +
 	"\rerror_message\x18\x04 \x01(\tR\ferrorMessage\x12)\n" +
-	"\x10effective_config\x18\x05 \x01(\fR\x0feffectiveConfig\"\x8a\x02\n" +
+	"\x10effective_config\x18\x05 \x01(\fR\x0feffectiveConfig\x12%\n" +
+	"\x0ecorrelation_id\x18\x06 \x01(\tR\rcorrelationId\"X\n" +
+	"\tHeartbeat\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x17\n" +
+	"\aack_seq\x18\x02 \x01(\x04R\x06ackSeq\x12 \n" +
+	"\fts_unix_nano\x18\x03 \x01(\x03R\n" +
+	"tsUnixNano\"\xf9\x02\n" +
 	"\bEnvelope\x123\n" +
 	"\bregister\x18\x01 \x01(\v2\x15.control.EdgeIdentityH\x00R\bregister\x12,\n" +
 	"\acommand\x18\x02 \x01(\v2\x10.control.CommandH\x00R\acommand\x12&\n" +
@@ -537,8 +3212,15 @@ const file_api_control_proto_rawDesc = "" +
 	"\vconfig_push\x18\x04 \x01(\v2\x13.control.ConfigPushH\x00R\n" +
 	"configPush\x123\n" +
 	"\n" +
-	"config_ack\x18\x05 \x01(\v2\x12.control.ConfigAckH\x00R\tconfigAckB\x06\n" +
-	"\x04body2E\n" +
+	"config_ack\x18\x05 \x01(\v2\x12.control.ConfigAckH\x00R\tconfigAck\x129\n" +
+	"\fregister_ack\x18\x06 \x01(\v2\x14.control.RegisterAckH\x00R\vregisterAck\x122\n" +
+	"\theartbeat\x18\a \x01(\v2\x12.control.HeartbeatH\x00R\theartbeatB\x06\n" +
+	"\x04body*\x80\x01\n" +
+	"\rRestartTarget\x12\x1e\n" +
+	"\x1aRESTART_TARGET_UNSPECIFIED\x10\x00\x12\x1c\n" +
+	"\x18RESTART_TARGET_COLLECTOR\x10\x01\x12\x18\n" +
+	"\x14RESTART_TARGET_AGENT\x10\x02\x12\x17\n" +
+	"\x13RESTART_TARGET_HOST\x10\x032E\n" +
 	"\x0eControlService\x123\n" +
 	"\aControl\x12\x11.control.Envelope\x1a\x11.control.Envelope(\x010\x01B4Z2local.dev/opamp-supervisor/api/controlpb;controlpbb\x06proto3"
 
@@ -554,28 +3236,102 @@ func file_api_control_proto_rawDescGZIP() []byte {
 	return file_api_control_proto_rawDescData
 }
 
-var file_api_control_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_api_control_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_control_proto_msgTypes = make([]protoimpl.MessageInfo, 40)
 var file_api_control_proto_goTypes = []any{
-	(*EdgeIdentity)(nil), // 0: control.EdgeIdentity
-	(*Command)(nil),      // 1: control.Command
-	(*Event)(nil),        // 2: control.Event
-	(*ConfigPush)(nil),   // 3: control.ConfigPush
-	(*ConfigAck)(nil),    // 4: control.ConfigAck
-	(*Envelope)(nil),     // 5: control.Envelope
+	(RestartTarget)(0),               // 0: control.RestartTarget
+	(*EdgeIdentity)(nil),             // 1: control.EdgeIdentity
+	(*RegisterAck)(nil),              // 2: control.RegisterAck
+	(*Command)(nil),                  // 3: control.Command
+	(*FetchStatusRequest)(nil),       // 4: control.FetchStatusRequest
+	(*UpdateConfigRequest)(nil),      // 5: control.UpdateConfigRequest
+	(*RestartRequest)(nil),           // 6: control.RestartRequest
+	(*FetchLogsRequest)(nil),         // 7: control.FetchLogsRequest
+	(*SetLogLevelRequest)(nil),       // 8: control.SetLogLevelRequest
+	(*FetchMetricsRequest)(nil),      // 9: control.FetchMetricsRequest
+	(*ListConfigHistoryRequest)(nil), // 10: control.ListConfigHistoryRequest
+	(*DiffConfigRequest)(nil),        // 11: control.DiffConfigRequest
+	(*RollbackConfigRequest)(nil),    // 12: control.RollbackConfigRequest
+	(*Event)(nil),                    // 13: control.Event
+	(*CommandError)(nil),             // 14: control.CommandError
+	(*StatusReport)(nil),             // 15: control.StatusReport
+	(*AgentStatus)(nil),              // 16: control.AgentStatus
+	(*ConnectionStatus)(nil),         // 17: control.ConnectionStatus
+	(*ConfigStatus)(nil),             // 18: control.ConfigStatus
+	(*CollectorStatus)(nil),          // 19: control.CollectorStatus
+	(*ProcessStatus)(nil),            // 20: control.ProcessStatus
+	(*HostStatus)(nil),               // 21: control.HostStatus
+	(*ActionReport)(nil),             // 22: control.ActionReport
+	(*LogChunk)(nil),                 // 23: control.LogChunk
+	(*LogLevelChange)(nil),           // 24: control.LogLevelChange
+	(*PluginCounters)(nil),           // 25: control.PluginCounters
+	(*PluginMetrics)(nil),            // 26: control.PluginMetrics
+	(*MetricsReport)(nil),            // 27: control.MetricsReport
+	(*HistoryEntry)(nil),             // 28: control.HistoryEntry
+	(*ConfigHistory)(nil),            // 29: control.ConfigHistory
+	(*ConfigDiff)(nil),               // 30: control.ConfigDiff
+	(*ConfigRollback)(nil),           // 31: control.ConfigRollback
+	(*ConfigPush)(nil),               // 32: control.ConfigPush
+	(*ConfigAck)(nil),                // 33: control.ConfigAck
+	(*Heartbeat)(nil),                // 34: control.Heartbeat
+	(*Envelope)(nil),                 // 35: control.Envelope
+	nil,                              // 36: control.EdgeIdentity.LabelsEntry
+	nil,                              // 37: control.AgentStatus.LabelsEntry
+	nil,                              // 38: control.MetricsReport.ValuesEntry
+	nil,                              // 39: control.MetricsReport.InputsEntry
+	nil,                              // 40: control.MetricsReport.OutputsEntry
 }
 var file_api_control_proto_depIdxs = []int32{
-	0, // 0: control.Envelope.register:type_name -> control.EdgeIdentity
-	1, // 1: control.Envelope.command:type_name -> control.Command
-	2, // 2: control.Envelope.event:type_name -> control.Event
-	3, // 3: control.Envelope.config_push:type_name -> control.ConfigPush
-	4, // 4: control.Envelope.config_ack:type_name -> control.ConfigAck
-	5, // 5: control.ControlService.Control:input_type -> control.Envelope
-	5, // 6: control.ControlService.Control:output_type -> control.Envelope
-	6, // [6:7] is the sub-list for method output_type
-	5, // [5:6] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	36, // 0: control.EdgeIdentity.labels:type_name -> control.EdgeIdentity.LabelsEntry
+	4,  // 1: control.Command.fetch_status:type_name -> control.FetchStatusRequest
+	5,  // 2: control.Command.update_config:type_name -> control.UpdateConfigRequest
+	6,  // 3: control.Command.restart:type_name -> control.RestartRequest
+	7,  // 4: control.Command.fetch_logs:type_name -> control.FetchLogsRequest
+	8,  // 5: control.Command.set_log_level:type_name -> control.SetLogLevelRequest
+	9,  // 6: control.Command.fetch_metrics:type_name -> control.FetchMetricsRequest
+	10, // 7: control.Command.list_config_history:type_name -> control.ListConfigHistoryRequest
+	11, // 8: control.Command.diff_config:type_name -> control.DiffConfigRequest
+	12, // 9: control.Command.rollback_config:type_name -> control.RollbackConfigRequest
+	0,  // 10: control.RestartRequest.target:type_name -> control.RestartTarget
+	15, // 11: control.Event.status:type_name -> control.StatusReport
+	14, // 12: control.Event.command_failed:type_name -> control.CommandError
+	14, // 13: control.Event.command_unknown:type_name -> control.CommandError
+	22, // 14: control.Event.action:type_name -> control.ActionReport
+	23, // 15: control.Event.logs:type_name -> control.LogChunk
+	24, // 16: control.Event.log_level:type_name -> control.LogLevelChange
+	27, // 17: control.Event.metrics:type_name -> control.MetricsReport
+	29, // 18: control.Event.config_history:type_name -> control.ConfigHistory
+	30, // 19: control.Event.config_diff:type_name -> control.ConfigDiff
+	31, // 20: control.Event.config_rollback:type_name -> control.ConfigRollback
+	16, // 21: control.StatusReport.agent:type_name -> control.AgentStatus
+	17, // 22: control.StatusReport.connection:type_name -> control.ConnectionStatus
+	18, // 23: control.StatusReport.config:type_name -> control.ConfigStatus
+	19, // 24: control.StatusReport.collector:type_name -> control.CollectorStatus
+	21, // 25: control.StatusReport.host:type_name -> control.HostStatus
+	37, // 26: control.AgentStatus.labels:type_name -> control.AgentStatus.LabelsEntry
+	20, // 27: control.CollectorStatus.process:type_name -> control.ProcessStatus
+	25, // 28: control.PluginMetrics.total:type_name -> control.PluginCounters
+	25, // 29: control.PluginMetrics.delta:type_name -> control.PluginCounters
+	38, // 30: control.MetricsReport.values:type_name -> control.MetricsReport.ValuesEntry
+	39, // 31: control.MetricsReport.inputs:type_name -> control.MetricsReport.InputsEntry
+	40, // 32: control.MetricsReport.outputs:type_name -> control.MetricsReport.OutputsEntry
+	28, // 33: control.ConfigHistory.versions:type_name -> control.HistoryEntry
+	1,  // 34: control.Envelope.register:type_name -> control.EdgeIdentity
+	3,  // 35: control.Envelope.command:type_name -> control.Command
+	13, // 36: control.Envelope.event:type_name -> control.Event
+	32, // 37: control.Envelope.config_push:type_name -> control.ConfigPush
+	33, // 38: control.Envelope.config_ack:type_name -> control.ConfigAck
+	2,  // 39: control.Envelope.register_ack:type_name -> control.RegisterAck
+	34, // 40: control.Envelope.heartbeat:type_name -> control.Heartbeat
+	26, // 41: control.MetricsReport.InputsEntry.value:type_name -> control.PluginMetrics
+	26, // 42: control.MetricsReport.OutputsEntry.value:type_name -> control.PluginMetrics
+	35, // 43: control.ControlService.Control:input_type -> control.Envelope
+	35, // 44: control.ControlService.Control:output_type -> control.Envelope
+	44, // [44:45] is the sub-list for method output_type
+	43, // [43:44] is the sub-list for method input_type
+	43, // [43:43] is the sub-list for extension type_name
+	43, // [43:43] is the sub-list for extension extendee
+	0,  // [0:43] is the sub-list for field type_name
 }
 
 func init() { file_api_control_proto_init() }
@@ -583,25 +3339,51 @@ func file_api_control_proto_init() {
 	if File_api_control_proto != nil {
 		return
 	}
-	file_api_control_proto_msgTypes[5].OneofWrappers = []any{
+	file_api_control_proto_msgTypes[2].OneofWrappers = []any{
+		(*Command_FetchStatus)(nil),
+		(*Command_UpdateConfig)(nil),
+		(*Command_Restart)(nil),
+		(*Command_FetchLogs)(nil),
+		(*Command_SetLogLevel)(nil),
+		(*Command_FetchMetrics)(nil),
+		(*Command_ListConfigHistory)(nil),
+		(*Command_DiffConfig)(nil),
+		(*Command_RollbackConfig)(nil),
+	}
+	file_api_control_proto_msgTypes[12].OneofWrappers = []any{
+		(*Event_Status)(nil),
+		(*Event_CommandFailed)(nil),
+		(*Event_CommandUnknown)(nil),
+		(*Event_Action)(nil),
+		(*Event_Logs)(nil),
+		(*Event_LogLevel)(nil),
+		(*Event_Metrics)(nil),
+		(*Event_ConfigHistory)(nil),
+		(*Event_ConfigDiff)(nil),
+		(*Event_ConfigRollback)(nil),
+	}
+	file_api_control_proto_msgTypes[34].OneofWrappers = []any{
 		(*Envelope_Register)(nil),
 		(*Envelope_Command)(nil),
 		(*Envelope_Event)(nil),
 		(*Envelope_ConfigPush)(nil),
 		(*Envelope_ConfigAck)(nil),
+		(*Envelope_RegisterAck)(nil),
+		(*Envelope_Heartbeat)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_control_proto_rawDesc), len(file_api_control_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   6,
+			NumEnums:      1,
+			NumMessages:   40,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_api_control_proto_goTypes,
 		DependencyIndexes: file_api_control_proto_depIdxs,
+		EnumInfos:         file_api_control_proto_enumTypes,
 		MessageInfos:      file_api_control_proto_msgTypes,
 	}.Build()
 	File_api_control_proto = out.File
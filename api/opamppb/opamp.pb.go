@@ -0,0 +1,1757 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v3.21.12
+// source: api/opamp.proto
+
+// Subset of the OpAMP protocol (github.com/open-telemetry/opamp-spec,
+// proto/opamp.proto and proto/anyvalue.proto) used by the agent's OpAMP
+// client mode. Field numbers and enum values match the spec, so messages are
+// wire compatible with any OpAMP server; fields the agent does not use are
+// left out and skipped as unknown fields.
+
+package opamppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AgentToServerFlags int32
+
+const (
+	AgentToServerFlags_AgentToServerFlags_Unspecified        AgentToServerFlags = 0
+	AgentToServerFlags_AgentToServerFlags_RequestInstanceUid AgentToServerFlags = 1
+)
+
+// Enum value maps for AgentToServerFlags.
+var (
+	AgentToServerFlags_name = map[int32]string{
+		0: "AgentToServerFlags_Unspecified",
+		1: "AgentToServerFlags_RequestInstanceUid",
+	}
+	AgentToServerFlags_value = map[string]int32{
+		"AgentToServerFlags_Unspecified":        0,
+		"AgentToServerFlags_RequestInstanceUid": 1,
+	}
+)
+
+func (x AgentToServerFlags) Enum() *AgentToServerFlags {
+	p := new(AgentToServerFlags)
+	*p = x
+	return p
+}
+
+func (x AgentToServerFlags) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AgentToServerFlags) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[0].Descriptor()
+}
+
+func (AgentToServerFlags) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[0]
+}
+
+func (x AgentToServerFlags) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AgentToServerFlags.Descriptor instead.
+func (AgentToServerFlags) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{0}
+}
+
+type ServerToAgentFlags int32
+
+const (
+	ServerToAgentFlags_ServerToAgentFlags_Unspecified     ServerToAgentFlags = 0
+	ServerToAgentFlags_ServerToAgentFlags_ReportFullState ServerToAgentFlags = 1
+)
+
+// Enum value maps for ServerToAgentFlags.
+var (
+	ServerToAgentFlags_name = map[int32]string{
+		0: "ServerToAgentFlags_Unspecified",
+		1: "ServerToAgentFlags_ReportFullState",
+	}
+	ServerToAgentFlags_value = map[string]int32{
+		"ServerToAgentFlags_Unspecified":     0,
+		"ServerToAgentFlags_ReportFullState": 1,
+	}
+)
+
+func (x ServerToAgentFlags) Enum() *ServerToAgentFlags {
+	p := new(ServerToAgentFlags)
+	*p = x
+	return p
+}
+
+func (x ServerToAgentFlags) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerToAgentFlags) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[1].Descriptor()
+}
+
+func (ServerToAgentFlags) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[1]
+}
+
+func (x ServerToAgentFlags) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerToAgentFlags.Descriptor instead.
+func (ServerToAgentFlags) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{1}
+}
+
+type ServerCapabilities int32
+
+const (
+	ServerCapabilities_ServerCapabilities_Unspecified            ServerCapabilities = 0
+	ServerCapabilities_ServerCapabilities_AcceptsStatus          ServerCapabilities = 1
+	ServerCapabilities_ServerCapabilities_OffersRemoteConfig     ServerCapabilities = 2
+	ServerCapabilities_ServerCapabilities_AcceptsEffectiveConfig ServerCapabilities = 4
+)
+
+// Enum value maps for ServerCapabilities.
+var (
+	ServerCapabilities_name = map[int32]string{
+		0: "ServerCapabilities_Unspecified",
+		1: "ServerCapabilities_AcceptsStatus",
+		2: "ServerCapabilities_OffersRemoteConfig",
+		4: "ServerCapabilities_AcceptsEffectiveConfig",
+	}
+	ServerCapabilities_value = map[string]int32{
+		"ServerCapabilities_Unspecified":            0,
+		"ServerCapabilities_AcceptsStatus":          1,
+		"ServerCapabilities_OffersRemoteConfig":     2,
+		"ServerCapabilities_AcceptsEffectiveConfig": 4,
+	}
+)
+
+func (x ServerCapabilities) Enum() *ServerCapabilities {
+	p := new(ServerCapabilities)
+	*p = x
+	return p
+}
+
+func (x ServerCapabilities) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerCapabilities) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[2].Descriptor()
+}
+
+func (ServerCapabilities) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[2]
+}
+
+func (x ServerCapabilities) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerCapabilities.Descriptor instead.
+func (ServerCapabilities) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{2}
+}
+
+type ServerErrorResponseType int32
+
+const (
+	ServerErrorResponseType_ServerErrorResponseType_Unknown     ServerErrorResponseType = 0
+	ServerErrorResponseType_ServerErrorResponseType_BadRequest  ServerErrorResponseType = 1
+	ServerErrorResponseType_ServerErrorResponseType_Unavailable ServerErrorResponseType = 2
+)
+
+// Enum value maps for ServerErrorResponseType.
+var (
+	ServerErrorResponseType_name = map[int32]string{
+		0: "ServerErrorResponseType_Unknown",
+		1: "ServerErrorResponseType_BadRequest",
+		2: "ServerErrorResponseType_Unavailable",
+	}
+	ServerErrorResponseType_value = map[string]int32{
+		"ServerErrorResponseType_Unknown":     0,
+		"ServerErrorResponseType_BadRequest":  1,
+		"ServerErrorResponseType_Unavailable": 2,
+	}
+)
+
+func (x ServerErrorResponseType) Enum() *ServerErrorResponseType {
+	p := new(ServerErrorResponseType)
+	*p = x
+	return p
+}
+
+func (x ServerErrorResponseType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ServerErrorResponseType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[3].Descriptor()
+}
+
+func (ServerErrorResponseType) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[3]
+}
+
+func (x ServerErrorResponseType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ServerErrorResponseType.Descriptor instead.
+func (ServerErrorResponseType) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{3}
+}
+
+type CommandType int32
+
+const (
+	CommandType_CommandType_Restart CommandType = 0
+)
+
+// Enum value maps for CommandType.
+var (
+	CommandType_name = map[int32]string{
+		0: "CommandType_Restart",
+	}
+	CommandType_value = map[string]int32{
+		"CommandType_Restart": 0,
+	}
+)
+
+func (x CommandType) Enum() *CommandType {
+	p := new(CommandType)
+	*p = x
+	return p
+}
+
+func (x CommandType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CommandType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[4].Descriptor()
+}
+
+func (CommandType) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[4]
+}
+
+func (x CommandType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CommandType.Descriptor instead.
+func (CommandType) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{4}
+}
+
+type AgentCapabilities int32
+
+const (
+	AgentCapabilities_AgentCapabilities_Unspecified            AgentCapabilities = 0
+	AgentCapabilities_AgentCapabilities_ReportsStatus          AgentCapabilities = 1
+	AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig    AgentCapabilities = 2
+	AgentCapabilities_AgentCapabilities_ReportsEffectiveConfig AgentCapabilities = 4
+	AgentCapabilities_AgentCapabilities_AcceptsRestartCommand  AgentCapabilities = 1024
+	AgentCapabilities_AgentCapabilities_ReportsHealth          AgentCapabilities = 2048
+	AgentCapabilities_AgentCapabilities_ReportsRemoteConfig    AgentCapabilities = 4096
+)
+
+// Enum value maps for AgentCapabilities.
+var (
+	AgentCapabilities_name = map[int32]string{
+		0:    "AgentCapabilities_Unspecified",
+		1:    "AgentCapabilities_ReportsStatus",
+		2:    "AgentCapabilities_AcceptsRemoteConfig",
+		4:    "AgentCapabilities_ReportsEffectiveConfig",
+		1024: "AgentCapabilities_AcceptsRestartCommand",
+		2048: "AgentCapabilities_ReportsHealth",
+		4096: "AgentCapabilities_ReportsRemoteConfig",
+	}
+	AgentCapabilities_value = map[string]int32{
+		"AgentCapabilities_Unspecified":            0,
+		"AgentCapabilities_ReportsStatus":          1,
+		"AgentCapabilities_AcceptsRemoteConfig":    2,
+		"AgentCapabilities_ReportsEffectiveConfig": 4,
+		"AgentCapabilities_AcceptsRestartCommand":  1024,
+		"AgentCapabilities_ReportsHealth":          2048,
+		"AgentCapabilities_ReportsRemoteConfig":    4096,
+	}
+)
+
+func (x AgentCapabilities) Enum() *AgentCapabilities {
+	p := new(AgentCapabilities)
+	*p = x
+	return p
+}
+
+func (x AgentCapabilities) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AgentCapabilities) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[5].Descriptor()
+}
+
+func (AgentCapabilities) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[5]
+}
+
+func (x AgentCapabilities) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AgentCapabilities.Descriptor instead.
+func (AgentCapabilities) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{5}
+}
+
+type RemoteConfigStatuses int32
+
+const (
+	RemoteConfigStatuses_RemoteConfigStatuses_UNSET    RemoteConfigStatuses = 0
+	RemoteConfigStatuses_RemoteConfigStatuses_APPLIED  RemoteConfigStatuses = 1
+	RemoteConfigStatuses_RemoteConfigStatuses_APPLYING RemoteConfigStatuses = 2
+	RemoteConfigStatuses_RemoteConfigStatuses_FAILED   RemoteConfigStatuses = 3
+)
+
+// Enum value maps for RemoteConfigStatuses.
+var (
+	RemoteConfigStatuses_name = map[int32]string{
+		0: "RemoteConfigStatuses_UNSET",
+		1: "RemoteConfigStatuses_APPLIED",
+		2: "RemoteConfigStatuses_APPLYING",
+		3: "RemoteConfigStatuses_FAILED",
+	}
+	RemoteConfigStatuses_value = map[string]int32{
+		"RemoteConfigStatuses_UNSET":    0,
+		"RemoteConfigStatuses_APPLIED":  1,
+		"RemoteConfigStatuses_APPLYING": 2,
+		"RemoteConfigStatuses_FAILED":   3,
+	}
+)
+
+func (x RemoteConfigStatuses) Enum() *RemoteConfigStatuses {
+	p := new(RemoteConfigStatuses)
+	*p = x
+	return p
+}
+
+func (x RemoteConfigStatuses) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RemoteConfigStatuses) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_opamp_proto_enumTypes[6].Descriptor()
+}
+
+func (RemoteConfigStatuses) Type() protoreflect.EnumType {
+	return &file_api_opamp_proto_enumTypes[6]
+}
+
+func (x RemoteConfigStatuses) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RemoteConfigStatuses.Descriptor instead.
+func (RemoteConfigStatuses) EnumDescriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{6}
+}
+
+type AgentToServer struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	InstanceUid        []byte                 `protobuf:"bytes,1,opt,name=instance_uid,json=instanceUid,proto3" json:"instance_uid,omitempty"`
+	SequenceNum        uint64                 `protobuf:"varint,2,opt,name=sequence_num,json=sequenceNum,proto3" json:"sequence_num,omitempty"`
+	AgentDescription   *AgentDescription      `protobuf:"bytes,3,opt,name=agent_description,json=agentDescription,proto3" json:"agent_description,omitempty"`
+	Capabilities       uint64                 `protobuf:"varint,4,opt,name=capabilities,proto3" json:"capabilities,omitempty"` // bitmask of AgentCapabilities
+	Health             *ComponentHealth       `protobuf:"bytes,5,opt,name=health,proto3" json:"health,omitempty"`
+	EffectiveConfig    *EffectiveConfig       `protobuf:"bytes,6,opt,name=effective_config,json=effectiveConfig,proto3" json:"effective_config,omitempty"`
+	RemoteConfigStatus *RemoteConfigStatus    `protobuf:"bytes,7,opt,name=remote_config_status,json=remoteConfigStatus,proto3" json:"remote_config_status,omitempty"`
+	AgentDisconnect    *AgentDisconnect       `protobuf:"bytes,9,opt,name=agent_disconnect,json=agentDisconnect,proto3" json:"agent_disconnect,omitempty"`
+	Flags              uint64                 `protobuf:"varint,10,opt,name=flags,proto3" json:"flags,omitempty"` // bitmask of AgentToServerFlags
+	CustomCapabilities *CustomCapabilities    `protobuf:"bytes,12,opt,name=custom_capabilities,json=customCapabilities,proto3" json:"custom_capabilities,omitempty"`
+	CustomMessage      *CustomMessage         `protobuf:"bytes,13,opt,name=custom_message,json=customMessage,proto3" json:"custom_message,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *AgentToServer) Reset() {
+	*x = AgentToServer{}
+	mi := &file_api_opamp_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentToServer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentToServer) ProtoMessage() {}
+
+func (x *AgentToServer) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentToServer.ProtoReflect.Descriptor instead.
+func (*AgentToServer) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AgentToServer) GetInstanceUid() []byte {
+	if x != nil {
+		return x.InstanceUid
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetSequenceNum() uint64 {
+	if x != nil {
+		return x.SequenceNum
+	}
+	return 0
+}
+
+func (x *AgentToServer) GetAgentDescription() *AgentDescription {
+	if x != nil {
+		return x.AgentDescription
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetCapabilities() uint64 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return 0
+}
+
+func (x *AgentToServer) GetHealth() *ComponentHealth {
+	if x != nil {
+		return x.Health
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetEffectiveConfig() *EffectiveConfig {
+	if x != nil {
+		return x.EffectiveConfig
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetRemoteConfigStatus() *RemoteConfigStatus {
+	if x != nil {
+		return x.RemoteConfigStatus
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetAgentDisconnect() *AgentDisconnect {
+	if x != nil {
+		return x.AgentDisconnect
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetFlags() uint64 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *AgentToServer) GetCustomCapabilities() *CustomCapabilities {
+	if x != nil {
+		return x.CustomCapabilities
+	}
+	return nil
+}
+
+func (x *AgentToServer) GetCustomMessage() *CustomMessage {
+	if x != nil {
+		return x.CustomMessage
+	}
+	return nil
+}
+
+type AgentDisconnect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentDisconnect) Reset() {
+	*x = AgentDisconnect{}
+	mi := &file_api_opamp_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentDisconnect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentDisconnect) ProtoMessage() {}
+
+func (x *AgentDisconnect) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentDisconnect.ProtoReflect.Descriptor instead.
+func (*AgentDisconnect) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{1}
+}
+
+type ServerToAgent struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	InstanceUid         []byte                 `protobuf:"bytes,1,opt,name=instance_uid,json=instanceUid,proto3" json:"instance_uid,omitempty"`
+	ErrorResponse       *ServerErrorResponse   `protobuf:"bytes,2,opt,name=error_response,json=errorResponse,proto3" json:"error_response,omitempty"`
+	RemoteConfig        *AgentRemoteConfig     `protobuf:"bytes,3,opt,name=remote_config,json=remoteConfig,proto3" json:"remote_config,omitempty"`
+	Flags               uint64                 `protobuf:"varint,6,opt,name=flags,proto3" json:"flags,omitempty"`               // bitmask of ServerToAgentFlags
+	Capabilities        uint64                 `protobuf:"varint,7,opt,name=capabilities,proto3" json:"capabilities,omitempty"` // bitmask of ServerCapabilities
+	AgentIdentification *AgentIdentification   `protobuf:"bytes,8,opt,name=agent_identification,json=agentIdentification,proto3" json:"agent_identification,omitempty"`
+	Command             *ServerToAgentCommand  `protobuf:"bytes,9,opt,name=command,proto3" json:"command,omitempty"`
+	CustomCapabilities  *CustomCapabilities    `protobuf:"bytes,10,opt,name=custom_capabilities,json=customCapabilities,proto3" json:"custom_capabilities,omitempty"`
+	CustomMessage       *CustomMessage         `protobuf:"bytes,11,opt,name=custom_message,json=customMessage,proto3" json:"custom_message,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ServerToAgent) Reset() {
+	*x = ServerToAgent{}
+	mi := &file_api_opamp_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerToAgent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerToAgent) ProtoMessage() {}
+
+func (x *ServerToAgent) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerToAgent.ProtoReflect.Descriptor instead.
+func (*ServerToAgent) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ServerToAgent) GetInstanceUid() []byte {
+	if x != nil {
+		return x.InstanceUid
+	}
+	return nil
+}
+
+func (x *ServerToAgent) GetErrorResponse() *ServerErrorResponse {
+	if x != nil {
+		return x.ErrorResponse
+	}
+	return nil
+}
+
+func (x *ServerToAgent) GetRemoteConfig() *AgentRemoteConfig {
+	if x != nil {
+		return x.RemoteConfig
+	}
+	return nil
+}
+
+func (x *ServerToAgent) GetFlags() uint64 {
+	if x != nil {
+		return x.Flags
+	}
+	return 0
+}
+
+func (x *ServerToAgent) GetCapabilities() uint64 {
+	if x != nil {
+		return x.Capabilities
+	}
+	return 0
+}
+
+func (x *ServerToAgent) GetAgentIdentification() *AgentIdentification {
+	if x != nil {
+		return x.AgentIdentification
+	}
+	return nil
+}
+
+func (x *ServerToAgent) GetCommand() *ServerToAgentCommand {
+	if x != nil {
+		return x.Command
+	}
+	return nil
+}
+
+func (x *ServerToAgent) GetCustomCapabilities() *CustomCapabilities {
+	if x != nil {
+		return x.CustomCapabilities
+	}
+	return nil
+}
+
+func (x *ServerToAgent) GetCustomMessage() *CustomMessage {
+	if x != nil {
+		return x.CustomMessage
+	}
+	return nil
+}
+
+type ServerErrorResponse struct {
+	state        protoimpl.MessageState  `protogen:"open.v1"`
+	Type         ServerErrorResponseType `protobuf:"varint,1,opt,name=type,proto3,enum=opamp.ServerErrorResponseType" json:"type,omitempty"`
+	ErrorMessage string                  `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	// Types that are valid to be assigned to Details:
+	//
+	//	*ServerErrorResponse_RetryInfo
+	Details       isServerErrorResponse_Details `protobuf_oneof:"Details"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerErrorResponse) Reset() {
+	*x = ServerErrorResponse{}
+	mi := &file_api_opamp_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerErrorResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerErrorResponse) ProtoMessage() {}
+
+func (x *ServerErrorResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerErrorResponse.ProtoReflect.Descriptor instead.
+func (*ServerErrorResponse) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ServerErrorResponse) GetType() ServerErrorResponseType {
+	if x != nil {
+		return x.Type
+	}
+	return ServerErrorResponseType_ServerErrorResponseType_Unknown
+}
+
+func (x *ServerErrorResponse) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *ServerErrorResponse) GetDetails() isServerErrorResponse_Details {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+func (x *ServerErrorResponse) GetRetryInfo() *RetryInfo {
+	if x != nil {
+		if x, ok := x.Details.(*ServerErrorResponse_RetryInfo); ok {
+			return x.RetryInfo
+		}
+	}
+	return nil
+}
+
+type isServerErrorResponse_Details interface {
+	isServerErrorResponse_Details()
+}
+
+type ServerErrorResponse_RetryInfo struct {
+	RetryInfo *RetryInfo `protobuf:"bytes,3,opt,name=retry_info,json=retryInfo,proto3,oneof"`
+}
+
+func (*ServerErrorResponse_RetryInfo) isServerErrorResponse_Details() {}
+
+type RetryInfo struct {
+	state                 protoimpl.MessageState `protogen:"open.v1"`
+	RetryAfterNanoseconds uint64                 `protobuf:"varint,1,opt,name=retry_after_nanoseconds,json=retryAfterNanoseconds,proto3" json:"retry_after_nanoseconds,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *RetryInfo) Reset() {
+	*x = RetryInfo{}
+	mi := &file_api_opamp_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RetryInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RetryInfo) ProtoMessage() {}
+
+func (x *RetryInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RetryInfo.ProtoReflect.Descriptor instead.
+func (*RetryInfo) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RetryInfo) GetRetryAfterNanoseconds() uint64 {
+	if x != nil {
+		return x.RetryAfterNanoseconds
+	}
+	return 0
+}
+
+type ServerToAgentCommand struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          CommandType            `protobuf:"varint,1,opt,name=type,proto3,enum=opamp.CommandType" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerToAgentCommand) Reset() {
+	*x = ServerToAgentCommand{}
+	mi := &file_api_opamp_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerToAgentCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerToAgentCommand) ProtoMessage() {}
+
+func (x *ServerToAgentCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerToAgentCommand.ProtoReflect.Descriptor instead.
+func (*ServerToAgentCommand) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ServerToAgentCommand) GetType() CommandType {
+	if x != nil {
+		return x.Type
+	}
+	return CommandType_CommandType_Restart
+}
+
+type AgentDescription struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	IdentifyingAttributes    []*KeyValue            `protobuf:"bytes,1,rep,name=identifying_attributes,json=identifyingAttributes,proto3" json:"identifying_attributes,omitempty"`
+	NonIdentifyingAttributes []*KeyValue            `protobuf:"bytes,2,rep,name=non_identifying_attributes,json=nonIdentifyingAttributes,proto3" json:"non_identifying_attributes,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
+}
+
+func (x *AgentDescription) Reset() {
+	*x = AgentDescription{}
+	mi := &file_api_opamp_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentDescription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentDescription) ProtoMessage() {}
+
+func (x *AgentDescription) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentDescription.ProtoReflect.Descriptor instead.
+func (*AgentDescription) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AgentDescription) GetIdentifyingAttributes() []*KeyValue {
+	if x != nil {
+		return x.IdentifyingAttributes
+	}
+	return nil
+}
+
+func (x *AgentDescription) GetNonIdentifyingAttributes() []*KeyValue {
+	if x != nil {
+		return x.NonIdentifyingAttributes
+	}
+	return nil
+}
+
+type ComponentHealth struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Healthy            bool                   `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	StartTimeUnixNano  uint64                 `protobuf:"fixed64,2,opt,name=start_time_unix_nano,json=startTimeUnixNano,proto3" json:"start_time_unix_nano,omitempty"`
+	LastError          string                 `protobuf:"bytes,3,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	Status             string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	StatusTimeUnixNano uint64                 `protobuf:"fixed64,5,opt,name=status_time_unix_nano,json=statusTimeUnixNano,proto3" json:"status_time_unix_nano,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ComponentHealth) Reset() {
+	*x = ComponentHealth{}
+	mi := &file_api_opamp_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ComponentHealth) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComponentHealth) ProtoMessage() {}
+
+func (x *ComponentHealth) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComponentHealth.ProtoReflect.Descriptor instead.
+func (*ComponentHealth) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ComponentHealth) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *ComponentHealth) GetStartTimeUnixNano() uint64 {
+	if x != nil {
+		return x.StartTimeUnixNano
+	}
+	return 0
+}
+
+func (x *ComponentHealth) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *ComponentHealth) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ComponentHealth) GetStatusTimeUnixNano() uint64 {
+	if x != nil {
+		return x.StatusTimeUnixNano
+	}
+	return 0
+}
+
+type EffectiveConfig struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConfigMap     *AgentConfigMap        `protobuf:"bytes,1,opt,name=config_map,json=configMap,proto3" json:"config_map,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EffectiveConfig) Reset() {
+	*x = EffectiveConfig{}
+	mi := &file_api_opamp_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EffectiveConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EffectiveConfig) ProtoMessage() {}
+
+func (x *EffectiveConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EffectiveConfig.ProtoReflect.Descriptor instead.
+func (*EffectiveConfig) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *EffectiveConfig) GetConfigMap() *AgentConfigMap {
+	if x != nil {
+		return x.ConfigMap
+	}
+	return nil
+}
+
+type RemoteConfigStatus struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	LastRemoteConfigHash []byte                 `protobuf:"bytes,1,opt,name=last_remote_config_hash,json=lastRemoteConfigHash,proto3" json:"last_remote_config_hash,omitempty"`
+	Status               RemoteConfigStatuses   `protobuf:"varint,2,opt,name=status,proto3,enum=opamp.RemoteConfigStatuses" json:"status,omitempty"`
+	ErrorMessage         string                 `protobuf:"bytes,3,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *RemoteConfigStatus) Reset() {
+	*x = RemoteConfigStatus{}
+	mi := &file_api_opamp_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoteConfigStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoteConfigStatus) ProtoMessage() {}
+
+func (x *RemoteConfigStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoteConfigStatus.ProtoReflect.Descriptor instead.
+func (*RemoteConfigStatus) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RemoteConfigStatus) GetLastRemoteConfigHash() []byte {
+	if x != nil {
+		return x.LastRemoteConfigHash
+	}
+	return nil
+}
+
+func (x *RemoteConfigStatus) GetStatus() RemoteConfigStatuses {
+	if x != nil {
+		return x.Status
+	}
+	return RemoteConfigStatuses_RemoteConfigStatuses_UNSET
+}
+
+func (x *RemoteConfigStatus) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+type AgentIdentification struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	NewInstanceUid []byte                 `protobuf:"bytes,1,opt,name=new_instance_uid,json=newInstanceUid,proto3" json:"new_instance_uid,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *AgentIdentification) Reset() {
+	*x = AgentIdentification{}
+	mi := &file_api_opamp_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentIdentification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentIdentification) ProtoMessage() {}
+
+func (x *AgentIdentification) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentIdentification.ProtoReflect.Descriptor instead.
+func (*AgentIdentification) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *AgentIdentification) GetNewInstanceUid() []byte {
+	if x != nil {
+		return x.NewInstanceUid
+	}
+	return nil
+}
+
+type AgentRemoteConfig struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Config        *AgentConfigMap        `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	ConfigHash    []byte                 `protobuf:"bytes,2,opt,name=config_hash,json=configHash,proto3" json:"config_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentRemoteConfig) Reset() {
+	*x = AgentRemoteConfig{}
+	mi := &file_api_opamp_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentRemoteConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentRemoteConfig) ProtoMessage() {}
+
+func (x *AgentRemoteConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentRemoteConfig.ProtoReflect.Descriptor instead.
+func (*AgentRemoteConfig) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AgentRemoteConfig) GetConfig() *AgentConfigMap {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetConfigHash() []byte {
+	if x != nil {
+		return x.ConfigHash
+	}
+	return nil
+}
+
+type AgentConfigMap struct {
+	state         protoimpl.MessageState      `protogen:"open.v1"`
+	ConfigMap     map[string]*AgentConfigFile `protobuf:"bytes,1,rep,name=config_map,json=configMap,proto3" json:"config_map,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentConfigMap) Reset() {
+	*x = AgentConfigMap{}
+	mi := &file_api_opamp_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentConfigMap) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentConfigMap) ProtoMessage() {}
+
+func (x *AgentConfigMap) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentConfigMap.ProtoReflect.Descriptor instead.
+func (*AgentConfigMap) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AgentConfigMap) GetConfigMap() map[string]*AgentConfigFile {
+	if x != nil {
+		return x.ConfigMap
+	}
+	return nil
+}
+
+type AgentConfigFile struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Body          []byte                 `protobuf:"bytes,1,opt,name=body,proto3" json:"body,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AgentConfigFile) Reset() {
+	*x = AgentConfigFile{}
+	mi := &file_api_opamp_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentConfigFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentConfigFile) ProtoMessage() {}
+
+func (x *AgentConfigFile) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentConfigFile.ProtoReflect.Descriptor instead.
+func (*AgentConfigFile) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *AgentConfigFile) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *AgentConfigFile) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+type CustomCapabilities struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Capabilities  []string               `protobuf:"bytes,1,rep,name=capabilities,proto3" json:"capabilities,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CustomCapabilities) Reset() {
+	*x = CustomCapabilities{}
+	mi := &file_api_opamp_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CustomCapabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CustomCapabilities) ProtoMessage() {}
+
+func (x *CustomCapabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CustomCapabilities.ProtoReflect.Descriptor instead.
+func (*CustomCapabilities) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CustomCapabilities) GetCapabilities() []string {
+	if x != nil {
+		return x.Capabilities
+	}
+	return nil
+}
+
+type CustomMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Capability    string                 `protobuf:"bytes,1,opt,name=capability,proto3" json:"capability,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Data          []byte                 `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CustomMessage) Reset() {
+	*x = CustomMessage{}
+	mi := &file_api_opamp_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CustomMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CustomMessage) ProtoMessage() {}
+
+func (x *CustomMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CustomMessage.ProtoReflect.Descriptor instead.
+func (*CustomMessage) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CustomMessage) GetCapability() string {
+	if x != nil {
+		return x.Capability
+	}
+	return ""
+}
+
+func (x *CustomMessage) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *CustomMessage) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type AnyValue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Value:
+	//
+	//	*AnyValue_StringValue
+	//	*AnyValue_BoolValue
+	//	*AnyValue_IntValue
+	//	*AnyValue_DoubleValue
+	//	*AnyValue_BytesValue
+	Value         isAnyValue_Value `protobuf_oneof:"value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnyValue) Reset() {
+	*x = AnyValue{}
+	mi := &file_api_opamp_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnyValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnyValue) ProtoMessage() {}
+
+func (x *AnyValue) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnyValue.ProtoReflect.Descriptor instead.
+func (*AnyValue) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *AnyValue) GetValue() isAnyValue_Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *AnyValue) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Value.(*AnyValue_StringValue); ok {
+			return x.StringValue
+		}
+	}
+	return ""
+}
+
+func (x *AnyValue) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Value.(*AnyValue_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *AnyValue) GetIntValue() int64 {
+	if x != nil {
+		if x, ok := x.Value.(*AnyValue_IntValue); ok {
+			return x.IntValue
+		}
+	}
+	return 0
+}
+
+func (x *AnyValue) GetDoubleValue() float64 {
+	if x != nil {
+		if x, ok := x.Value.(*AnyValue_DoubleValue); ok {
+			return x.DoubleValue
+		}
+	}
+	return 0
+}
+
+func (x *AnyValue) GetBytesValue() []byte {
+	if x != nil {
+		if x, ok := x.Value.(*AnyValue_BytesValue); ok {
+			return x.BytesValue
+		}
+	}
+	return nil
+}
+
+type isAnyValue_Value interface {
+	isAnyValue_Value()
+}
+
+type AnyValue_StringValue struct {
+	StringValue string `protobuf:"bytes,1,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type AnyValue_BoolValue struct {
+	BoolValue bool `protobuf:"varint,2,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type AnyValue_IntValue struct {
+	IntValue int64 `protobuf:"varint,3,opt,name=int_value,json=intValue,proto3,oneof"`
+}
+
+type AnyValue_DoubleValue struct {
+	DoubleValue float64 `protobuf:"fixed64,4,opt,name=double_value,json=doubleValue,proto3,oneof"`
+}
+
+type AnyValue_BytesValue struct {
+	BytesValue []byte `protobuf:"bytes,7,opt,name=bytes_value,json=bytesValue,proto3,oneof"`
+}
+
+func (*AnyValue_StringValue) isAnyValue_Value() {}
+
+func (*AnyValue_BoolValue) isAnyValue_Value() {}
+
+func (*AnyValue_IntValue) isAnyValue_Value() {}
+
+func (*AnyValue_DoubleValue) isAnyValue_Value() {}
+
+func (*AnyValue_BytesValue) isAnyValue_Value() {}
+
+type KeyValue struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         *AnyValue              `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeyValue) Reset() {
+	*x = KeyValue{}
+	mi := &file_api_opamp_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeyValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyValue) ProtoMessage() {}
+
+func (x *KeyValue) ProtoReflect() protoreflect.Message {
+	mi := &file_api_opamp_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyValue.ProtoReflect.Descriptor instead.
+func (*KeyValue) Descriptor() ([]byte, []int) {
+	return file_api_opamp_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *KeyValue) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *KeyValue) GetValue() *AnyValue {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+var File_api_opamp_proto protoreflect.FileDescriptor
+
+const file_api_opamp_proto_rawDesc = "" +
+	"\n" +
+	"\x0fapi/opamp.proto\x12\x05opamp\"\xe1\x04\n" +
+	"\rAgentToServer\x12!\n" +
+	"\finstance_uid\x18\x01 \x01(\fR\vinstanceUid\x12!\n" +
+	"\fsequence_num\x18\x02 \x01(\x04R\vsequenceNum\x12D\n" +
+	"\x11agent_description\x18\x03 \x01(\v2\x17.opamp.AgentDescriptionR\x10agentDescription\x12\"\n" +
+	"\fcapabilities\x18\x04 \x01(\x04R\fcapabilities\x12.\n" +
+	"\x06health\x18\x05 \x01(\v2\x16.opamp.ComponentHealthR\x06health\x12A\n" +
+	"\x10effective_config\x18\x06 \x01(\v2\x16.opamp.EffectiveConfigR\x0feffectiveConfig\x12K\n" +
+	"\x14remote_config_status\x18\a \x01(\v2\x19.opamp.RemoteConfigStatusR\x12remoteConfigStatus\x12A\n" +
+	"\x10agent_disconnect\x18\t \x01(\v2\x16.opamp.AgentDisconnectR\x0fagentDisconnect\x12\x14\n" +
+	"\x05flags\x18\n" +
+	" \x01(\x04R\x05flags\x12J\n" +
+	"\x13custom_capabilities\x18\f \x01(\v2\x19.opamp.CustomCapabilitiesR\x12customCapabilities\x12;\n" +
+	"\x0ecustom_message\x18\r \x01(\v2\x14.opamp.CustomMessageR\rcustomMessage\"\x11\n" +
+	"\x0fAgentDisconnect\"\xfd\x03\n" +
+	"\rServerToAgent\x12!\n" +
+	"\finstance_uid\x18\x01 \x01(\fR\vinstanceUid\x12A\n" +
+	"\x0eerror_response\x18\x02 \x01(\v2\x1a.opamp.ServerErrorResponseR\rerrorResponse\x12=\n" +
+	"\rremote_config\x18\x03 \x01(\v2\x18.opamp.AgentRemoteConfigR\fremoteConfig\x12\x14\n" +
+	"\x05flags\x18\x06 \x01(\x04R\x05flags\x12\"\n" +
+	"\fcapabilities\x18\a \x01(\x04R\fcapabilities\x12M\n" +
+	"\x14agent_identification\x18\b \x01(\v2\x1a.opamp.AgentIdentificationR\x13agentIdentification\x125\n" +
+	"\acommand\x18\t \x01(\v2\x1b.opamp.ServerToAgentCommandR\acommand\x12J\n" +
+	"\x13custom_capabilities\x18\n" +
+	" \x01(\v2\x19.opamp.CustomCapabilitiesR\x12customCapabilities\x12;\n" +
+	"\x0ecustom_message\x18\v \x01(\v2\x14.opamp.CustomMessageR\rcustomMessage\"\xac\x01\n" +
+	"\x13ServerErrorResponse\x122\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1e.opamp.ServerErrorResponseTypeR\x04type\x12#\n" +
+	"\rerror_message\x18\x02 \x01(\tR\ferrorMessage\x121\n" +
+	"\n" +
+	"retry_info\x18\x03 \x01(\v2\x10.opamp.RetryInfoH\x00R\tretryInfoB\t\n" +
+	"\aDetails\"C\n" +
+	"\tRetryInfo\x126\n" +
+	"\x17retry_after_nanoseconds\x18\x01 \x01(\x04R\x15retryAfterNanoseconds\">\n" +
+	"\x14ServerToAgentCommand\x12&\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x12.opamp.CommandTypeR\x04type\"\xa9\x01\n" +
+	"\x10AgentDescription\x12F\n" +
+	"\x16identifying_attributes\x18\x01 \x03(\v2\x0f.opamp.KeyValueR\x15identifyingAttributes\x12M\n" +
+	"\x1anon_identifying_attributes\x18\x02 \x03(\v2\x0f.opamp.KeyValueR\x18nonIdentifyingAttributes\"\xc6\x01\n" +
+	"\x0fComponentHealth\x12\x18\n" +
+	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12/\n" +
+	"\x14start_time_unix_nano\x18\x02 \x01(\x06R\x11startTimeUnixNano\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x03 \x01(\tR\tlastError\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x121\n" +
+	"\x15status_time_unix_nano\x18\x05 \x01(\x06R\x12statusTimeUnixNano\"G\n" +
+	"\x0fEffectiveConfig\x124\n" +
+	"\n" +
+	"config_map\x18\x01 \x01(\v2\x15.opamp.AgentConfigMapR\tconfigMap\"\xa5\x01\n" +
+	"\x12RemoteConfigStatus\x125\n" +
+	"\x17last_remote_config_hash\x18\x01 \x01(\fR\x14lastRemoteConfigHash\x123\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x1b.opamp.RemoteConfigStatusesR\x06status\x12#\n" +
+	"\rerror_message\x18\x03 \x01(\tR\ferrorMessage\"?\n" +
+	"\x13AgentIdentification\x12(\n" +
+	"\x10new_instance_uid\x18\x01 \x01(\fR\x0enewInstanceUid\"c\n" +
+	"\x11AgentRemoteConfig\x12-\n" +
+	"\x06config\x18\x01 \x01(\v2\x15.opamp.AgentConfigMapR\x06config\x12\x1f\n" +
+	"\vconfig_hash\x18\x02 \x01(\fR\n" +
+	"configHash\"\xab\x01\n" +
+	"\x0eAgentConfigMap\x12C\n" +
+	"\n" +
+	"config_map\x18\x01 \x03(\v2$.opamp.AgentConfigMap.ConfigMapEntryR\tconfigMap\x1aT\n" +
+	"\x0eConfigMapEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.opamp.AgentConfigFileR\x05value:\x028\x01\"H\n" +
+	"\x0fAgentConfigFile\x12\x12\n" +
+	"\x04body\x18\x01 \x01(\fR\x04body\x12!\n" +
+	"\fcontent_type\x18\x02 \x01(\tR\vcontentType\"8\n" +
+	"\x12CustomCapabilities\x12\"\n" +
+	"\fcapabilities\x18\x01 \x03(\tR\fcapabilities\"W\n" +
+	"\rCustomMessage\x12\x1e\n" +
+	"\n" +
+	"capability\x18\x01 \x01(\tR\n" +
+	"capability\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\"\xc0\x01\n" +
+	"\bAnyValue\x12#\n" +
+	"\fstring_value\x18\x01 \x01(\tH\x00R\vstringValue\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x02 \x01(\bH\x00R\tboolValue\x12\x1d\n" +
+	"\tint_value\x18\x03 \x01(\x03H\x00R\bintValue\x12#\n" +
+	"\fdouble_value\x18\x04 \x01(\x01H\x00R\vdoubleValue\x12!\n" +
+	"\vbytes_value\x18\a \x01(\fH\x00R\n" +
+	"bytesValueB\a\n" +
+	"\x05value\"C\n" +
+	"\bKeyValue\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12%\n" +
+	"\x05value\x18\x02 \x01(\v2\x0f.opamp.AnyValueR\x05value*c\n" +
+	"\x12AgentToServerFlags\x12\"\n" +
+	"\x1eAgentToServerFlags_Unspecified\x10\x00\x12)\n" +
+	"%AgentToServerFlags_RequestInstanceUid\x10\x01*`\n" +
+	"\x12ServerToAgentFlags\x12\"\n" +
+	"\x1eServerToAgentFlags_Unspecified\x10\x00\x12&\n" +
+	"\"ServerToAgentFlags_ReportFullState\x10\x01*\xb8\x01\n" +
+	"\x12ServerCapabilities\x12\"\n" +
+	"\x1eServerCapabilities_Unspecified\x10\x00\x12$\n" +
+	" ServerCapabilities_AcceptsStatus\x10\x01\x12)\n" +
+	"%ServerCapabilities_OffersRemoteConfig\x10\x02\x12-\n" +
+	")ServerCapabilities_AcceptsEffectiveConfig\x10\x04*\x8f\x01\n" +
+	"\x17ServerErrorResponseType\x12#\n" +
+	"\x1fServerErrorResponseType_Unknown\x10\x00\x12&\n" +
+	"\"ServerErrorResponseType_BadRequest\x10\x01\x12'\n" +
+	"#ServerErrorResponseType_Unavailable\x10\x02*&\n" +
+	"\vCommandType\x12\x17\n" +
+	"\x13CommandType_Restart\x10\x00*\xb4\x02\n" +
+	"\x11AgentCapabilities\x12!\n" +
+	"\x1dAgentCapabilities_Unspecified\x10\x00\x12#\n" +
+	"\x1fAgentCapabilities_ReportsStatus\x10\x01\x12)\n" +
+	"%AgentCapabilities_AcceptsRemoteConfig\x10\x02\x12,\n" +
+	"(AgentCapabilities_ReportsEffectiveConfig\x10\x04\x12,\n" +
+	"'AgentCapabilities_AcceptsRestartCommand\x10\x80\b\x12$\n" +
+	"\x1fAgentCapabilities_ReportsHealth\x10\x80\x10\x12*\n" +
+	"%AgentCapabilities_ReportsRemoteConfig\x10\x80 *\x9c\x01\n" +
+	"\x14RemoteConfigStatuses\x12\x1e\n" +
+	"\x1aRemoteConfigStatuses_UNSET\x10\x00\x12 \n" +
+	"\x1cRemoteConfigStatuses_APPLIED\x10\x01\x12!\n" +
+	"\x1dRemoteConfigStatuses_APPLYING\x10\x02\x12\x1f\n" +
+	"\x1bRemoteConfigStatuses_FAILED\x10\x03B2Z0local.dev/opamp-device-agent/api/opamppb;opamppbb\x06proto3"
+
+var (
+	file_api_opamp_proto_rawDescOnce sync.Once
+	file_api_opamp_proto_rawDescData []byte
+)
+
+func file_api_opamp_proto_rawDescGZIP() []byte {
+	file_api_opamp_proto_rawDescOnce.Do(func() {
+		file_api_opamp_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_opamp_proto_rawDesc), len(file_api_opamp_proto_rawDesc)))
+	})
+	return file_api_opamp_proto_rawDescData
+}
+
+var file_api_opamp_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_api_opamp_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_api_opamp_proto_goTypes = []any{
+	(AgentToServerFlags)(0),      // 0: opamp.AgentToServerFlags
+	(ServerToAgentFlags)(0),      // 1: opamp.ServerToAgentFlags
+	(ServerCapabilities)(0),      // 2: opamp.ServerCapabilities
+	(ServerErrorResponseType)(0), // 3: opamp.ServerErrorResponseType
+	(CommandType)(0),             // 4: opamp.CommandType
+	(AgentCapabilities)(0),       // 5: opamp.AgentCapabilities
+	(RemoteConfigStatuses)(0),    // 6: opamp.RemoteConfigStatuses
+	(*AgentToServer)(nil),        // 7: opamp.AgentToServer
+	(*AgentDisconnect)(nil),      // 8: opamp.AgentDisconnect
+	(*ServerToAgent)(nil),        // 9: opamp.ServerToAgent
+	(*ServerErrorResponse)(nil),  // 10: opamp.ServerErrorResponse
+	(*RetryInfo)(nil),            // 11: opamp.RetryInfo
+	(*ServerToAgentCommand)(nil), // 12: opamp.ServerToAgentCommand
+	(*AgentDescription)(nil),     // 13: opamp.AgentDescription
+	(*ComponentHealth)(nil),      // 14: opamp.ComponentHealth
+	(*EffectiveConfig)(nil),      // 15: opamp.EffectiveConfig
+	(*RemoteConfigStatus)(nil),   // 16: opamp.RemoteConfigStatus
+	(*AgentIdentification)(nil),  // 17: opamp.AgentIdentification
+	(*AgentRemoteConfig)(nil),    // 18: opamp.AgentRemoteConfig
+	(*AgentConfigMap)(nil),       // 19: opamp.AgentConfigMap
+	(*AgentConfigFile)(nil),      // 20: opamp.AgentConfigFile
+	(*CustomCapabilities)(nil),   // 21: opamp.CustomCapabilities
+	(*CustomMessage)(nil),        // 22: opamp.CustomMessage
+	(*AnyValue)(nil),             // 23: opamp.AnyValue
+	(*KeyValue)(nil),             // 24: opamp.KeyValue
+	nil,                          // 25: opamp.AgentConfigMap.ConfigMapEntry
+}
+var file_api_opamp_proto_depIdxs = []int32{
+	13, // 0: opamp.AgentToServer.agent_description:type_name -> opamp.AgentDescription
+	14, // 1: opamp.AgentToServer.health:type_name -> opamp.ComponentHealth
+	15, // 2: opamp.AgentToServer.effective_config:type_name -> opamp.EffectiveConfig
+	16, // 3: opamp.AgentToServer.remote_config_status:type_name -> opamp.RemoteConfigStatus
+	8,  // 4: opamp.AgentToServer.agent_disconnect:type_name -> opamp.AgentDisconnect
+	21, // 5: opamp.AgentToServer.custom_capabilities:type_name -> opamp.CustomCapabilities
+	22, // 6: opamp.AgentToServer.custom_message:type_name -> opamp.CustomMessage
+	10, // 7: opamp.ServerToAgent.error_response:type_name -> opamp.ServerErrorResponse
+	18, // 8: opamp.ServerToAgent.remote_config:type_name -> opamp.AgentRemoteConfig
+	17, // 9: opamp.ServerToAgent.agent_identification:type_name -> opamp.AgentIdentification
+	12, // 10: opamp.ServerToAgent.command:type_name -> opamp.ServerToAgentCommand
+	21, // 11: opamp.ServerToAgent.custom_capabilities:type_name -> opamp.CustomCapabilities
+	22, // 12: opamp.ServerToAgent.custom_message:type_name -> opamp.CustomMessage
+	3,  // 13: opamp.ServerErrorResponse.type:type_name -> opamp.ServerErrorResponseType
+	11, // 14: opamp.ServerErrorResponse.retry_info:type_name -> opamp.RetryInfo
+	4,  // 15: opamp.ServerToAgentCommand.type:type_name -> opamp.CommandType
+	24, // 16: opamp.AgentDescription.identifying_attributes:type_name -> opamp.KeyValue
+	24, // 17: opamp.AgentDescription.non_identifying_attributes:type_name -> opamp.KeyValue
+	19, // 18: opamp.EffectiveConfig.config_map:type_name -> opamp.AgentConfigMap
+	6,  // 19: opamp.RemoteConfigStatus.status:type_name -> opamp.RemoteConfigStatuses
+	19, // 20: opamp.AgentRemoteConfig.config:type_name -> opamp.AgentConfigMap
+	25, // 21: opamp.AgentConfigMap.config_map:type_name -> opamp.AgentConfigMap.ConfigMapEntry
+	23, // 22: opamp.KeyValue.value:type_name -> opamp.AnyValue
+	20, // 23: opamp.AgentConfigMap.ConfigMapEntry.value:type_name -> opamp.AgentConfigFile
+	24, // [24:24] is the sub-list for method output_type
+	24, // [24:24] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
+}
+
+func init() { file_api_opamp_proto_init() }
+func file_api_opamp_proto_init() {
+	if File_api_opamp_proto != nil {
+		return
+	}
+	file_api_opamp_proto_msgTypes[3].OneofWrappers = []any{
+		(*ServerErrorResponse_RetryInfo)(nil),
+	}
+	file_api_opamp_proto_msgTypes[16].OneofWrappers = []any{
+		(*AnyValue_StringValue)(nil),
+		(*AnyValue_BoolValue)(nil),
+		(*AnyValue_IntValue)(nil),
+		(*AnyValue_DoubleValue)(nil),
+		(*AnyValue_BytesValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_opamp_proto_rawDesc), len(file_api_opamp_proto_rawDesc)),
+			NumEnums:      7,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_api_opamp_proto_goTypes,
+		DependencyIndexes: file_api_opamp_proto_depIdxs,
+		EnumInfos:         file_api_opamp_proto_enumTypes,
+		MessageInfos:      file_api_opamp_proto_msgTypes,
+	}.Build()
+	File_api_opamp_proto = out.File
+	file_api_opamp_proto_goTypes = nil
+	file_api_opamp_proto_depIdxs = nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BackoffPolicy controls how the agent paces reconnect attempts to the
+// supervisor. Jitter spreads a fleet of devices out after a supervisor
+// restart instead of having them reconnect in lockstep.
+type BackoffPolicy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter is the fraction (0-1) of the delay that is randomized
+	Jitter float64
+	// RedialAfter is the number of consecutive stream failures after which
+	// the gRPC connection itself is torn down and redialed
+	RedialAfter int
+	// MaxRetries gives up reconnecting after this many attempts (0 = forever)
+	MaxRetries int
+}
+
+// DefaultBackoffPolicy returns the policy used when no flags override it.
+func DefaultBackoffPolicy() BackoffPolicy {
+	return BackoffPolicy{
+		Initial:     time.Second,
+		Max:         2 * time.Minute,
+		Multiplier:  2,
+		Jitter:      0.2,
+		RedialAfter: 3,
+	}
+}
+
+// Delay returns the wait before the given (zero-based) reconnect attempt.
+// rnd must return values in [0, 1); nil uses math/rand.
+func (p BackoffPolicy) Delay(attempt int, rnd func() float64) time.Duration {
+	if rnd == nil {
+		rnd = rand.Float64
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+
+	d := float64(p.Initial) * math.Pow(mult, float64(attempt))
+	if p.Max > 0 && d > float64(p.Max) {
+		d = float64(p.Max)
+	}
+
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+	// Spread uniformly over [d*(1-jitter), d*(1+jitter)), never past Max
+	d = d * (1 - jitter + 2*jitter*rnd())
+	if p.Max > 0 && d > float64(p.Max) {
+		d = float64(p.Max)
+	}
+	return time.Duration(d)
+}
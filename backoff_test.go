@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// TestBackoffDelay tests exponential growth and the max cap without jitter
+func TestBackoffDelay(t *testing.T) {
+	p := BackoffPolicy{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+	mid := func() float64 { return 0.5 }
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := p.Delay(tt.attempt, mid); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// TestBackoffJitterBounds tests that jitter stays within the configured fraction
+func TestBackoffJitterBounds(t *testing.T) {
+	p := BackoffPolicy{Initial: 10 * time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+	if got := p.Delay(0, func() float64 { return 0 }); got != 8*time.Second {
+		t.Errorf("low jitter bound = %s, want 8s", got)
+	}
+	if got := p.Delay(0, func() float64 { return 0.999999 }); got < 11*time.Second || got > 12*time.Second {
+		t.Errorf("high jitter bound = %s, want just under 12s", got)
+	}
+	for i := 0; i < 100; i++ {
+		if got := p.Delay(0, nil); got < 8*time.Second || got >= 12*time.Second {
+			t.Fatalf("jittered delay %s outside [8s, 12s)", got)
+		}
+	}
+
+	// Jitter never pushes a capped delay past Max
+	if got := p.Delay(10, func() float64 { return 0.999999 }); got != time.Minute {
+		t.Errorf("capped delay with high jitter = %s, want 1m", got)
+	}
+	if got := p.Delay(10, func() float64 { return 0 }); got != 48*time.Second {
+		t.Errorf("capped delay with low jitter = %s, want 48s", got)
+	}
+}
+
+// newReconnectAgent returns a started agent on mt that retries without delay
+func newReconnectAgent(t *testing.T, mt *memoryTransport, maxRetries int) *DeviceAgent {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "fluent-bit.conf")
+	os.WriteFile(configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+	a := NewDeviceAgent("unused", "device-1", "file", configPath, "", Options{
+		Transport:       mt,
+		Backoff:         BackoffPolicy{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1, MaxRetries: maxRetries},
+		MonitorInterval: time.Hour,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { a.Stop() })
+	return a
+}
+
+// TestReconnectAfterRefusals tests that failed connects are retried until one succeeds
+func TestReconnectAfterRefusals(t *testing.T) {
+	mt := newMemoryTransport()
+	a := newReconnectAgent(t, mt, 5)
+	stream := mt.accept(t)
+	stream.next(t) // registration
+
+	mt.refuseNext(3)
+	stream.fail(errors.New("connection reset"))
+	stream = mt.accept(t)
+	if reg := stream.next(t).GetRegister(); reg.GetNodeId() != "device-1" {
+		t.Fatalf("first message after reconnect is not a registration: %v", reg)
+	}
+	if got := mt.connectAttempts(); got != 5 {
+		t.Errorf("%d connects, want 1 + 3 refused + 1", got)
+	}
+	select {
+	case err := <-a.Err():
+		t.Errorf("agent gave up: %v", err)
+	default:
+	}
+}
+
+// TestReconnectGivesUp tests that exhausting MaxRetries is reported on Err
+func TestReconnectGivesUp(t *testing.T) {
+	mt := newMemoryTransport()
+	a := newReconnectAgent(t, mt, 3)
+	stream := mt.accept(t)
+	stream.next(t) // registration
+
+	mt.refuseNext(100)
+	stream.fail(errors.New("connection reset"))
+	select {
+	case err := <-a.Err():
+		if !strings.Contains(err.Error(), "after 3 attempts") {
+			t.Errorf("Err() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent kept retrying past MaxRetries")
+	}
+	if got := mt.connectAttempts(); got != 4 {
+		t.Errorf("%d connects, want 1 + 3 retries", got)
+	}
+	if conn := a.stats.connection(); !strings.Contains(conn.LastError, errRefused.Error()) {
+		t.Errorf("last error = %q", conn.LastError)
+	}
+}
+
+// flakyServer fails every stream after the registration, like a supervisor
+// behind a stale connection, unless answer is set.
+type flakyServer struct {
+	controlpb.UnimplementedControlServiceServer
+	answer atomic.Bool
+}
+
+func (s *flakyServer) Control(stream controlpb.ControlService_ControlServer) error {
+	if _, err := stream.Recv(); err != nil {
+		return err
+	}
+	if !s.answer.Load() {
+		return status.Error(codes.Unavailable, "backend gone")
+	}
+	stream.Send(&controlpb.Envelope{Body: &controlpb.Envelope_Heartbeat{Heartbeat: &controlpb.Heartbeat{Seq: 1}}})
+	<-stream.Context().Done()
+	return nil
+}
+
+// TestRedialAfterStreamFailures tests that the gRPC connection is replaced
+// after RedialAfter streams in a row fail once opened, and that a stream the
+// server answers on resets the count
+func TestRedialAfterStreamFailures(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	flaky := &flakyServer{}
+	controlpb.RegisterControlServiceServer(srv, flaky)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	tr := newGRPCTransport(lis.Addr().String(), "device-1", TLSConfig{Insecure: true}, KeepaliveConfig{}, 2)
+	defer tr.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn := func() *grpc.ClientConn {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		return tr.conn
+	}
+	open := func() Stream {
+		t.Helper()
+		stream, err := tr.Connect(ctx)
+		if err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		stream.Send(&controlpb.Envelope{Body: &controlpb.Envelope_Register{Register: &controlpb.EdgeIdentity{NodeId: "device-1"}}})
+		return stream
+	}
+	openAndFail := func() {
+		t.Helper()
+		stream := open()
+		select {
+		case <-stream.Done():
+		case <-ctx.Done():
+			t.Fatal("stream did not fail")
+		}
+	}
+
+	openAndFail()
+	first := conn()
+	openAndFail()
+	if conn() != first {
+		t.Fatal("redialed after a single failure")
+	}
+	openAndFail()
+	if conn() == first {
+		t.Fatal("connection not redialed after RedialAfter failures")
+	}
+
+	// A stream the server answers on proves the connection healthy
+	flaky.answer.Store(true)
+	stream := open()
+	select {
+	case <-stream.Recv():
+	case <-ctx.Done():
+		t.Fatal("no answer from the server")
+	}
+	tr.mu.Lock()
+	failures := tr.failures
+	tr.mu.Unlock()
+	if failures != 0 {
+		t.Errorf("failures = %d after an answered stream, want 0", failures)
+	}
+	stream.Close()
+}
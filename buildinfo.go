@@ -0,0 +1,44 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// version is set at build time, e.g. go build -ldflags "-X main.version=v1.4.0".
+// Without it the module version recorded by the go tool is used.
+var version = ""
+
+// buildInfo describes the running agent binary.
+type buildInfo struct {
+	Version      string `json:"version"`
+	GoVersion    string `json:"go_version"`
+	Revision     string `json:"revision,omitempty"`
+	RevisionTime string `json:"revision_time,omitempty"`
+	Modified     bool   `json:"modified,omitempty"`
+}
+
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.Version == "" {
+			info.Version = "unknown"
+		}
+		return info
+	}
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.RevisionTime = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// typedProtocolVersion is the first protocol version with typed command
+// and event bodies.
+const typedProtocolVersion = 4
+
+// restartActions maps a typed restart onto the action it carries out.
+var restartActions = map[controlpb.RestartTarget]string{
+	controlpb.RestartTarget_RESTART_TARGET_COLLECTOR: ActionRestartCollector,
+	controlpb.RestartTarget_RESTART_TARGET_AGENT:     ActionRestartAgent,
+	controlpb.RestartTarget_RESTART_TARGET_HOST:      ActionReboot,
+}
+
+// commandName is the legacy type of a typed command body.
+func commandName(cmd *controlpb.Command) string {
+	switch body := cmd.Body.(type) {
+	case *controlpb.Command_FetchStatus:
+		return "FetchStatus"
+	case *controlpb.Command_UpdateConfig:
+		return "UpdateConfig"
+	case *controlpb.Command_Restart:
+		if action, ok := restartActions[body.Restart.GetTarget()]; ok {
+			return action
+		}
+		return body.Restart.GetTarget().String()
+	case *controlpb.Command_FetchLogs:
+		return "FetchLogs"
+	case *controlpb.Command_SetLogLevel:
+		return "SetLogLevel"
+	case *controlpb.Command_FetchMetrics:
+		return "FetchMetrics"
+	case *controlpb.Command_ListConfigHistory:
+		return "ListConfigHistory"
+	case *controlpb.Command_DiffConfig:
+		return "DiffConfig"
+	case *controlpb.Command_RollbackConfig:
+		return "RollbackConfig"
+	}
+	return cmd.GetType()
+}
+
+// upgradeCommand gives a legacy command (type plus string payload) the
+// typed body it stands for, so that handleCommand only deals with bodies.
+// A typed command gets its legacy type, which events and logs report.
+// Unknown legacy types are left without a body.
+func upgradeCommand(cmd *controlpb.Command) error {
+	if cmd.Body != nil {
+		cmd.Type = commandName(cmd)
+		return nil
+	}
+	payload := []byte(cmd.GetPayload())
+	switch cmd.GetType() {
+	case "FetchStatus":
+		cmd.Body = &controlpb.Command_FetchStatus{FetchStatus: &controlpb.FetchStatusRequest{}}
+
+	case "UpdateConfig":
+		// The payload is the config itself
+		cmd.Body = &controlpb.Command_UpdateConfig{UpdateConfig: &controlpb.UpdateConfigRequest{ConfigData: payload}}
+
+	case ActionRestartCollector, ActionRestartAgent, ActionReboot:
+		for target, action := range restartActions {
+			if action == cmd.GetType() {
+				cmd.Body = &controlpb.Command_Restart{Restart: &controlpb.RestartRequest{Target: target}}
+			}
+		}
+
+	case "FetchLogs":
+		// {"source":"collector","lines":500,"since":"15m","grep":"error|warn"}
+		var req struct {
+			Source string `json:"source"`
+			Lines  int32  `json:"lines"`
+			Since  string `json:"since"`
+			Grep   string `json:"grep"`
+		}
+		if err := unmarshalPayload(payload, &req); err != nil {
+			return fmt.Errorf("invalid FetchLogs payload: %w", err)
+		}
+		typed := &controlpb.FetchLogsRequest{Source: req.Source, Lines: req.Lines, Grep: req.Grep}
+		if req.Since != "" {
+			if t, err := time.Parse(time.RFC3339, req.Since); err == nil {
+				typed.SinceUnixNano = t.UnixNano()
+			} else if d, err := time.ParseDuration(req.Since); err == nil && d > 0 {
+				typed.MaxAgeMs = d.Milliseconds()
+			} else {
+				return fmt.Errorf("invalid since %q: want an RFC 3339 time or a duration", req.Since)
+			}
+		}
+		cmd.Body = &controlpb.Command_FetchLogs{FetchLogs: typed}
+
+	case "SetLogLevel":
+		// {"level":"debug","ttl":"15m"}
+		var req struct {
+			Level string `json:"level"`
+			TTL   string `json:"ttl"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return fmt.Errorf("invalid SetLogLevel payload: %w", err)
+		}
+		typed := &controlpb.SetLogLevelRequest{Level: req.Level}
+		if req.TTL != "" {
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("invalid SetLogLevel ttl %q", req.TTL)
+			}
+			typed.TtlMs = ttl.Milliseconds()
+		}
+		cmd.Body = &controlpb.Command_SetLogLevel{SetLogLevel: typed}
+
+	case "FetchMetrics":
+		cmd.Body = &controlpb.Command_FetchMetrics{FetchMetrics: &controlpb.FetchMetricsRequest{}}
+
+	case "ListConfigHistory":
+		cmd.Body = &controlpb.Command_ListConfigHistory{ListConfigHistory: &controlpb.ListConfigHistoryRequest{}}
+
+	case "DiffConfig":
+		// {"from":N,"to":M}
+		var req struct {
+			From int32 `json:"from"`
+			To   int32 `json:"to"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return fmt.Errorf("invalid DiffConfig payload: %w", err)
+		}
+		cmd.Body = &controlpb.Command_DiffConfig{DiffConfig: &controlpb.DiffConfigRequest{From: req.From, To: req.To}}
+
+	case "RollbackConfig":
+		// {"version":N}
+		var req struct {
+			Version int32 `json:"version"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return fmt.Errorf("invalid RollbackConfig payload: %w", err)
+		}
+		cmd.Body = &controlpb.Command_RollbackConfig{RollbackConfig: &controlpb.RollbackConfigRequest{Version: req.Version}}
+	}
+	return nil
+}
+
+// unmarshalPayload is json.Unmarshal for commands whose parameters are all optional.
+func unmarshalPayload(payload []byte, v interface{}) error {
+	if strings.TrimSpace(string(payload)) == "" {
+		return nil
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// eventEnvelope wraps event for sending. The sender drops its typed body
+// for servers that did not negotiate typed events.
+func (a *DeviceAgent) eventEnvelope(event *controlpb.Event) *controlpb.Envelope {
+	if event.TsUnixNano == 0 {
+		event.TsUnixNano = time.Now().UnixNano()
+	}
+	return &controlpb.Envelope{Body: &controlpb.Envelope_Event{Event: event}}
+}
+
+// unixNano converts an optional time for the typed bodies, where 0 means unset.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
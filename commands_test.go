@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// TestUpgradeCommand tests turning legacy type and payload commands into typed bodies
+func TestUpgradeCommand(t *testing.T) {
+	since := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+	tests := []struct {
+		typ     string
+		payload string
+		want    *controlpb.Command
+		wantErr string
+	}{
+		{typ: "FetchStatus", want: &controlpb.Command{Body: &controlpb.Command_FetchStatus{FetchStatus: &controlpb.FetchStatusRequest{}}}},
+		{typ: "UpdateConfig", payload: "[OUTPUT]\n", want: &controlpb.Command{Body: &controlpb.Command_UpdateConfig{
+			UpdateConfig: &controlpb.UpdateConfigRequest{ConfigData: []byte("[OUTPUT]\n")}}}},
+		{typ: "Reboot", want: &controlpb.Command{Body: &controlpb.Command_Restart{
+			Restart: &controlpb.RestartRequest{Target: controlpb.RestartTarget_RESTART_TARGET_HOST}}}},
+		{typ: "FetchLogs", payload: `{"lines":10,"since":"15m","grep":"err"}`, want: &controlpb.Command{Body: &controlpb.Command_FetchLogs{
+			FetchLogs: &controlpb.FetchLogsRequest{Lines: 10, MaxAgeMs: 15 * 60 * 1000, Grep: "err"}}}},
+		{typ: "FetchLogs", payload: `{"since":"2024-03-01T11:00:00Z"}`, want: &controlpb.Command{Body: &controlpb.Command_FetchLogs{
+			FetchLogs: &controlpb.FetchLogsRequest{SinceUnixNano: since.UnixNano()}}}},
+		{typ: "SetLogLevel", payload: `{"level":"debug","ttl":"1m"}`, want: &controlpb.Command{Body: &controlpb.Command_SetLogLevel{
+			SetLogLevel: &controlpb.SetLogLevelRequest{Level: "debug", TtlMs: 60000}}}},
+		{typ: "DiffConfig", payload: `{"from":2}`, want: &controlpb.Command{Body: &controlpb.Command_DiffConfig{
+			DiffConfig: &controlpb.DiffConfigRequest{From: 2}}}},
+		{typ: "RollbackConfig", payload: `{"version":3}`, want: &controlpb.Command{Body: &controlpb.Command_RollbackConfig{
+			RollbackConfig: &controlpb.RollbackConfigRequest{Version: 3}}}},
+		{typ: "Frobnicate", want: &controlpb.Command{}},
+		{typ: "SetLogLevel", payload: `{"level":"debug","ttl":"soon"}`, wantErr: "invalid SetLogLevel ttl"},
+		{typ: "RollbackConfig", payload: `three`, wantErr: "invalid RollbackConfig payload"},
+		{typ: "FetchLogs", payload: `{"since":"yesterday"}`, wantErr: "invalid since"},
+	}
+	for _, tt := range tests {
+		cmd := &controlpb.Command{Type: tt.typ, Payload: tt.payload}
+		err := upgradeCommand(cmd)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s %s: err = %v, want %q", tt.typ, tt.payload, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: %v", tt.typ, tt.payload, err)
+			continue
+		}
+		tt.want.Type, tt.want.Payload = tt.typ, tt.payload
+		if !proto.Equal(cmd, tt.want) {
+			t.Errorf("%s %s: got %v, want %v", tt.typ, tt.payload, cmd, tt.want)
+		}
+	}
+
+	// A typed command gets the legacy name its events report
+	cmd := &controlpb.Command{Body: &controlpb.Command_Restart{Restart: &controlpb.RestartRequest{Target: controlpb.RestartTarget_RESTART_TARGET_AGENT}}}
+	if err := upgradeCommand(cmd); err != nil || cmd.Type != ActionRestartAgent {
+		t.Errorf("typed restart: type %q, err %v", cmd.Type, err)
+	}
+}
+
+// TestTypedCommands tests typed commands and that typed results only go to servers that negotiated them
+func TestTypedCommands(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	fetch := func() *controlpb.Command {
+		return &controlpb.Command{CorrelationId: "c-1", Body: &controlpb.Command_FetchStatus{FetchStatus: &controlpb.FetchStatusRequest{}}}
+	}
+
+	// The body is kept while queued and dropped when written to an older server
+	a.resetNegotiation()
+	a.handleCommand(context.Background(), fetch())
+	ev := nextEvent(t, a, "StatusReport")
+	stream := newFakeStream()
+	if err := a.out.send(stream, &controlpb.Envelope{Body: &controlpb.Envelope_Event{Event: ev}}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if sent := stream.Sent()[0].GetEvent(); sent.Body != nil || !strings.Contains(sent.Payload, `"device_id":"device-1"`) {
+		t.Errorf("protocol 1: body %v, payload %.60s", sent.Body, sent.Payload)
+	}
+	if ev.Body == nil {
+		t.Error("protocol 1: queued event lost its body")
+	}
+
+	a.negotiated.protocolVersion = typedProtocolVersion
+	a.handleCommand(context.Background(), fetch())
+	ev = nextEvent(t, a, "StatusReport")
+	a.out.send(stream, &controlpb.Envelope{Body: &controlpb.Envelope_Event{Event: ev}})
+	status := stream.Sent()[1].GetEvent().GetStatus()
+	if status.GetDeviceId() != "device-1" || status.GetSchemaVersion() != statusSchemaVersion || ev.Payload == "" {
+		t.Errorf("protocol %d: status %v", typedProtocolVersion, status)
+	}
+	if status.GetAgent().GetInstanceUid() != a.instanceUID || status.GetCollector().GetType() != "fluentbit" {
+		t.Errorf("agent %v, collector %v", status.GetAgent(), status.GetCollector())
+	}
+
+	// Failures name the command by its legacy type
+	a.handleCommand(context.Background(), &controlpb.Command{CorrelationId: "c-2", Body: &controlpb.Command_Restart{
+		Restart: &controlpb.RestartRequest{Target: controlpb.RestartTarget_RESTART_TARGET_HOST},
+	}})
+	failed := nextEvent(t, a, "CommandFailed").GetCommandFailed()
+	if failed.GetCommand() != ActionReboot || !strings.Contains(failed.GetError(), "--allow-reboot") {
+		t.Errorf("failed = %v", failed)
+	}
+
+	// An unset or unknown restart target restarts nothing
+	for _, target := range []controlpb.RestartTarget{controlpb.RestartTarget_RESTART_TARGET_UNSPECIFIED, 42} {
+		a.handleCommand(context.Background(), &controlpb.Command{CorrelationId: "c-4", Body: &controlpb.Command_Restart{
+			Restart: &controlpb.RestartRequest{Target: target},
+		}})
+		if failed := nextEvent(t, a, "CommandFailed").GetCommandFailed(); !strings.Contains(failed.GetError(), "unsupported restart target") {
+			t.Errorf("restart target %d: failed = %v", target, failed)
+		}
+	}
+
+	a.handleCommand(context.Background(), &controlpb.Command{Type: "Frobnicate", CorrelationId: "c-3"})
+	if unknown := nextEvent(t, a, "CommandUnknown").GetCommandUnknown(); unknown.GetCommand() != "Frobnicate" {
+		t.Errorf("unknown = %v", unknown)
+	}
+}
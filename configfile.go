@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeFileAtomic replaces path with data so that readers (and a crash at any
+// point) see either the old or the new content, never a partial file. The
+// data is written to a temp file in the same directory, fsynced, renamed over
+// the target, and the directory is fsynced to persist the rename.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to chmod temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to fsync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// configHash is the content hash reported in every ConfigAck: the hex
+// SHA-256 of the config bytes.
+func configHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyConfigHash checks pushed config data against the hash the supervisor
+// sent. An optional "sha256:" prefix is accepted; an empty hash is not
+// verified so older supervisors keep working.
+func verifyConfigHash(data []byte, want string) error {
+	if want == "" {
+		return nil
+	}
+	normalized := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(want), "sha256:"))
+	if got := configHash(data); got != normalized {
+		return fmt.Errorf("config hash mismatch: pushed %s, content hashes to %s", want, got)
+	}
+	return nil
+}
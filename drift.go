@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// driftDebounce coalesces the burst of events a single save produces.
+const driftDebounce = 200 * time.Millisecond
+
+// configDrift is the payload of a ConfigDrift event.
+type configDrift struct {
+	Path        string `json:"path"`
+	ManagedHash string `json:"managed_hash"`
+	CurrentHash string `json:"current_hash"`
+	Diff        string `json:"diff"`
+	Remediated  bool   `json:"remediated"`
+	Error       string `json:"error,omitempty"`
+}
+
+// driftWatchLoop checks for drift as soon as the config file changes on disk.
+// Without a watcher the runtime monitor's periodic check still applies.
+func (a *DeviceAgent) driftWatchLoop(ctx context.Context) {
+	changes, err := watchFile(ctx, a.configPath)
+	if err != nil {
+		a.log.Warn("Config watcher unavailable, checking drift periodically", "interval", a.opts.MonitorInterval, "error", err)
+		return
+	}
+	a.log.Info("Watching config for out-of-band changes", "path", a.configPath)
+
+	debounce := time.NewTimer(driftDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-changes:
+			if !ok {
+				a.log.Warn("Config watcher stopped, checking drift periodically", "interval", a.opts.MonitorInterval)
+				return
+			}
+			debounce.Reset(driftDebounce)
+		case <-debounce.C:
+			if a.checkDrift(ctx) {
+				a.sendConfigHeartbeat(ctx)
+			}
+		}
+	}
+}
+
+// checkDrift compares the config on disk with the one the agent last
+// applied, reports a ConfigDrift event when they differ and, if enabled,
+// restores the managed config. It reports whether drift was found.
+func (a *DeviceAgent) checkDrift(ctx context.Context) bool {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	if a.managedConfig == nil {
+		managed, err := os.ReadFile(a.lastGoodConfigPath())
+		if err != nil {
+			// Nothing has been applied yet, so there is nothing to drift from
+			return false
+		}
+		a.managedConfig = managed
+	}
+
+	current, err := os.ReadFile(a.configPath)
+	if err != nil && !os.IsNotExist(err) {
+		a.log.Warn("Drift check failed to read config", "error", err)
+		return false
+	}
+	currentHash := configHash(current)
+	if currentHash == configHash(a.managedConfig) {
+		a.driftHash = ""
+		return false
+	}
+	if currentHash == a.driftHash {
+		// Already reported this edit
+		return true
+	}
+	a.driftHash = currentHash
+
+	drift := configDrift{
+		Path:        a.configPath,
+		ManagedHash: configHash(a.managedConfig),
+		CurrentHash: currentHash,
+		Diff:        unifiedDiff("managed", "on-disk", a.managedConfig, current),
+	}
+	a.log.Warn("Config drift detected", "path", a.configPath, attrConfigHash, drift.ManagedHash, "current_hash", currentHash)
+
+	if a.opts.DriftRemediate {
+		if err := a.restoreManagedConfig(ctx); err != nil {
+			a.log.Error("Failed to restore managed config", "error", err)
+			drift.Error = err.Error()
+		} else {
+			a.log.Info("Restored managed config", attrConfigHash, drift.ManagedHash)
+			drift.Remediated = true
+			a.driftHash = ""
+		}
+	}
+
+	payload, _ := json.Marshal(drift)
+	a.sendEvent(ctx, "ConfigDrift", string(payload), currentHash)
+	return true
+}
+
+// restoreManagedConfig writes the managed config back over an edited file
+// and reloads the collector. configMu must be held.
+func (a *DeviceAgent) restoreManagedConfig(ctx context.Context) error {
+	if err := writeFileAtomic(a.configPath, a.managedConfig, 0644); err != nil {
+		return fmt.Errorf("failed to write managed config: %w", err)
+	}
+	if err := a.driver.Reload(ctx); err != nil {
+		return fmt.Errorf("managed config restored, but reload failed: %w", err)
+	}
+	return nil
+}
+
+// sendConfigHeartbeat reports the effective config hash. The content is only
+// included when it differs from what was last reported, so a quiet device
+// costs a few bytes per interval instead of its whole config.
+func (a *DeviceAgent) sendConfigHeartbeat(ctx context.Context) {
+	effectiveConfig, err := a.driver.EffectiveConfig(ctx)
+	if err != nil {
+		a.log.Warn("Runtime monitor failed to get config", "error", err)
+		return
+	}
+
+	hash := configHash(effectiveConfig)
+	changed := hash != a.reportedConfigHash()
+	ack := &controlpb.ConfigAck{
+		DeviceId:   a.nodeID,
+		ConfigHash: hash,
+		Success:    true,
+	}
+	if changed {
+		ack.EffectiveConfig = effectiveConfig
+	}
+
+	envelope := &controlpb.Envelope{
+		Body: &controlpb.Envelope_ConfigAck{
+			ConfigAck: ack,
+		},
+	}
+
+	if err := a.out.enqueue(envelope, PriorityNormal, false); err != nil {
+		a.log.Warn("Failed to queue config heartbeat", "error", err)
+		return
+	}
+	if changed {
+		a.setReportedConfigHash(hash)
+		a.log.Info("Sent changed runtime config", attrConfigHash, hash, "bytes", len(effectiveConfig))
+	}
+}
+
+func (a *DeviceAgent) reportedConfigHash() string {
+	a.reportedMu.Lock()
+	defer a.reportedMu.Unlock()
+	return a.reportedHash
+}
+
+func (a *DeviceAgent) setReportedConfigHash(hash string) {
+	a.reportedMu.Lock()
+	a.reportedHash = hash
+	a.reportedMu.Unlock()
+}
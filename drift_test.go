@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCheckDrift tests drift reporting with and without remediation
+func TestCheckDrift(t *testing.T) {
+	for _, remediate := range []bool{false, true} {
+		t.Run(map[bool]string{false: "report only", true: "remediate"}[remediate], func(t *testing.T) {
+			a, fb := newFluentBitAgent(t)
+			a.opts.DriftRemediate = remediate
+			ctx := context.Background()
+
+			if a.checkDrift(ctx) {
+				t.Error("drift reported before any config was applied")
+			}
+
+			managed := []byte("[OUTPUT]\n    Name stdout\n")
+			if _, err := a.applyConfig(ctx, managed); err != nil {
+				t.Fatalf("apply: %v", err)
+			}
+			if a.checkDrift(ctx) {
+				t.Error("drift reported for the agent's own write")
+			}
+
+			edited := []byte("[OUTPUT]\n    Name null\n")
+			os.WriteFile(a.configPath, edited, 0644)
+			if !a.checkDrift(ctx) {
+				t.Fatal("out-of-band edit not detected")
+			}
+
+			var drift configDrift
+			ev := nextEvent(t, a, "ConfigDrift")
+			decodeEvent(t, ev, &drift)
+			if drift.ManagedHash != configHash(managed) || drift.CurrentHash != configHash(edited) {
+				t.Errorf("got hashes %s -> %s", drift.ManagedHash, drift.CurrentHash)
+			}
+			if !strings.Contains(drift.Diff, "-    Name stdout\n+    Name null\n") {
+				t.Errorf("unexpected diff:\n%s", drift.Diff)
+			}
+			if drift.Remediated != remediate {
+				t.Errorf("remediated = %v, want %v", drift.Remediated, remediate)
+			}
+
+			got, _ := os.ReadFile(a.configPath)
+			if remediate {
+				if string(got) != string(managed) || fb.Reloads() != 2 {
+					t.Errorf("live config = %q after %d reloads, want managed config restored and reloaded", got, fb.Reloads())
+				}
+				return
+			}
+			if string(got) != string(edited) {
+				t.Errorf("live config = %q, want the edit left in place", got)
+			}
+			// The same edit is reported once
+			a.checkDrift(ctx)
+			select {
+			case msg := <-a.out.normal:
+				t.Errorf("drift reported twice: %v", msg.env)
+			default:
+			}
+		})
+	}
+}
+
+// TestSendConfigHeartbeat tests that unchanged content is sent as a hash only
+func TestSendConfigHeartbeat(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	os.WriteFile(a.configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+
+	next := func() (hash string, content []byte) {
+		t.Helper()
+		a.sendConfigHeartbeat(context.Background())
+		select {
+		case msg := <-a.out.normal:
+			ack := msg.env.GetConfigAck()
+			return ack.ConfigHash, ack.EffectiveConfig
+		case <-time.After(5 * time.Second):
+			t.Fatal("no heartbeat queued")
+			return "", nil
+		}
+	}
+
+	if _, content := next(); content == nil {
+		t.Error("first heartbeat has no content")
+	}
+	hash, content := next()
+	if content != nil || hash == "" {
+		t.Errorf("unchanged heartbeat: hash=%q, %d bytes of content, want hash only", hash, len(content))
+	}
+	os.WriteFile(a.configPath, []byte("[OUTPUT]\n    Name null\n"), 0644)
+	if _, content := next(); content == nil {
+		t.Error("heartbeat after a change has no content")
+	}
+}
+
+// TestWatchFile tests that atomic replaces and in-place writes are both seen
+func TestWatchFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("file watching is Linux only")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fluent-bit.conf")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := watchFile(ctx, path)
+	if err != nil {
+		t.Fatalf("watchFile: %v", err)
+	}
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no notification for %s", what)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "other.conf"), []byte("x"), 0644)
+	select {
+	case <-changes:
+		t.Fatal("notified for another file")
+	case <-time.After(300 * time.Millisecond):
+	}
+	os.WriteFile(path, []byte("a"), 0644)
+	expect("write")
+	writeFileAtomic(path, []byte("b"), 0644)
+	expect("atomic replace")
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		for ok {
+			_, ok = <-changes
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not stop")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Driver capabilities advertised at registration.
+const (
+	CapabilityApplyConfig     = "apply_config"
+	CapabilityEffectiveConfig = "effective_config"
+	CapabilityHealth          = "health"
+	CapabilityReload          = "reload"
+	CapabilityMetrics         = "metrics"
+	// CapabilityRestart means the driver can restart the collector on command
+	CapabilityRestart = "restart"
+	// CapabilityRollback means a config that fails to load is replaced by the last good one
+	CapabilityRollback = "rollback"
+	// CapabilityValidate means the collector checks configs before they are written
+	CapabilityValidate = "validate_config"
+	// CapabilityDriftDetection means the config lives in a local file the agent can watch
+	CapabilityDriftDetection = "drift_detection"
+)
+
+// errNotSupported is returned by driver methods outside the driver's capabilities.
+var errNotSupported = errors.New("not supported by this driver")
+
+// Driver manages the collector the agent is responsible for. The agent only
+// talks to the collector through its driver, selected by --agent-type.
+type Driver interface {
+	// Apply makes config the running config. The result describes what
+	// happened even when err is set.
+	Apply(ctx context.Context, config []byte) (ApplyResult, error)
+	// EffectiveConfig returns the config that is running now.
+	EffectiveConfig(ctx context.Context) ([]byte, error)
+	Health(ctx context.Context) DriverHealth
+	// Reload makes the collector load its current config again.
+	Reload(ctx context.Context) error
+	// Restart restarts the collector and returns once it is running again.
+	Restart(ctx context.Context) error
+	Metrics(ctx context.Context) (map[string]float64, error)
+	Capabilities() []string
+}
+
+// metricsReporter is implemented by drivers that can describe the
+// collector's pipeline throughput in more detail than Metrics.
+type metricsReporter interface {
+	// MetricsReport scrapes the collector and returns the event to report.
+	MetricsReport(ctx context.Context) (eventType string, report interface{}, err error)
+}
+
+// ApplyResult describes one Apply.
+type ApplyResult struct {
+	// RolledBack is set when the previous config had to be restored
+	RolledBack bool
+	// EventType and Event, when set, are reported as an event correlated with the push
+	EventType string
+	Event     interface{}
+}
+
+// DriverHealth is a point-in-time view of the collector.
+type DriverHealth struct {
+	Healthy bool   `json:"healthy"`
+	Status  string `json:"status"`
+	// UptimeSeconds is how long the collector has been running, when known
+	UptimeSeconds int64 `json:"uptime_seconds,omitempty"`
+}
+
+// DriverConfig carries the settings a driver is built from.
+type DriverConfig struct {
+	NodeID     string
+	ConfigPath string
+	// LastGoodPath is where the last config that loaded cleanly is kept
+	LastGoodPath       string
+	ReloadEndpoint     string
+	ReloadTimeout      time.Duration
+	ReloadPollInterval time.Duration
+	LocalSupervisorURL string
+	// ReloadSignal and PIDFile configure the file driver's signal reload;
+	// the otelcol-direct driver sends SIGHUP to the process in PIDFile and
+	// the local supervisor driver stops it to have it restarted
+	ReloadSignal syscall.Signal
+	PIDFile      string
+	// OtelcolBinary runs "validate" for the otelcol-direct driver
+	OtelcolBinary   string
+	ValidateTimeout time.Duration
+	// HealthEndpoint is the collector's health_check extension URL
+	HealthEndpoint string
+	// Child is the collector process when the agent runs it (--exec)
+	Child *processSupervisor
+}
+
+// drivers maps --agent-type values to driver constructors.
+var drivers = map[string]func(DriverConfig) Driver{
+	"fluentbit":        newFluentBitDriver,
+	"otelcol":          newLocalSupervisorDriver,
+	"otelcol-direct":   newOtelcolDriver,
+	"local-supervisor": newLocalSupervisorDriver,
+	"":                 newLocalSupervisorDriver,
+	"file":             newFileDriver,
+}
+
+// newDriver builds the driver registered for agentType.
+func newDriver(agentType string, cfg DriverConfig) (Driver, error) {
+	build, ok := drivers[agentType]
+	if !ok {
+		return nil, fmt.Errorf("unknown agent type %q (known: %s)", agentType, strings.Join(driverNames(), ", "))
+	}
+	return build(cfg), nil
+}
+
+func driverNames() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasCapability(d Driver, capability string) bool {
+	for _, c := range d.Capabilities() {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSignal accepts a signal name with or without the SIG prefix.
+func parseSignal(name string) (syscall.Signal, error) {
+	if name == "" {
+		return 0, nil
+	}
+	sig, ok := reloadSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unsupported signal %q", name)
+	}
+	return sig, nil
+}
+
+// managedFile writes configs to a local file and puts the last good one
+// back when the collector does not load a new one.
+type managedFile struct {
+	nodeID       string
+	path         string
+	lastGoodPath string
+}
+
+// apply writes config and calls reload, which is called again for the
+// restored config if the first reload fails. It reports whether the
+// previous config was restored.
+func (f *managedFile) apply(config []byte, reload func() error) (bool, error) {
+	nodeLogger(f.nodeID).Info("Writing config", "path", f.path)
+
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return false, fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	// Keep what is live now in case the new config has to be rolled back
+	previous, prevErr := os.ReadFile(f.path)
+
+	if err := writeFileAtomic(f.path, config, 0644); err != nil {
+		return false, fmt.Errorf("failed to write config: %w", err)
+	}
+	nodeLogger(f.nodeID).Debug("Config written", "path", f.path)
+
+	reloadErr := reload()
+	if reloadErr == nil {
+		os.MkdirAll(filepath.Dir(f.lastGoodPath), 0755)
+		if err := writeFileAtomic(f.lastGoodPath, config, 0644); err != nil {
+			nodeLogger(f.nodeID).Warn("Failed to save last-known-good config", "error", err)
+		}
+		return false, nil
+	}
+
+	nodeLogger(f.nodeID).Warn("New config failed, rolling back", "error", reloadErr)
+
+	// Prefer the last config that was verified running; the previous file may
+	// itself have been broken or edited out of band
+	restore, err := os.ReadFile(f.lastGoodPath)
+	if err != nil {
+		if prevErr != nil {
+			return false, fmt.Errorf("%v (no previous config to roll back to)", reloadErr)
+		}
+		restore = previous
+	}
+
+	if err := writeFileAtomic(f.path, restore, 0644); err != nil {
+		return false, fmt.Errorf("%v (rollback write failed: %v)", reloadErr, err)
+	}
+	if err := reload(); err != nil {
+		return true, fmt.Errorf("%v (rolled back, but previous config also failed: %v)", reloadErr, err)
+	}
+
+	nodeLogger(f.nodeID).Info("Rolled back to last-known-good config")
+	return true, fmt.Errorf("%v (rolled back to previous config)", reloadErr)
+}
+
+func (f *managedFile) read() ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNewDriver tests driver selection by agent type
+func TestNewDriver(t *testing.T) {
+	tests := []struct {
+		agentType string
+		want      string
+	}{
+		{"fluentbit", "*main.fluentBitDriver"},
+		{"otelcol", "*main.localSupervisorDriver"},
+		{"otelcol-direct", "*main.otelcolDriver"},
+		{"local-supervisor", "*main.localSupervisorDriver"},
+		{"", "*main.localSupervisorDriver"},
+		{"file", "*main.fileDriver"},
+	}
+	for _, tt := range tests {
+		d, err := newDriver(tt.agentType, DriverConfig{NodeID: "device-1"})
+		if err != nil {
+			t.Errorf("newDriver(%q): %v", tt.agentType, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", d); got != tt.want {
+			t.Errorf("newDriver(%q) = %s, want %s", tt.agentType, got, tt.want)
+		}
+	}
+	if _, err := newDriver("vector", DriverConfig{}); err == nil || !strings.Contains(err.Error(), "fluentbit") {
+		t.Errorf("unknown agent type: got %v, want an error listing the known types", err)
+	}
+}
+
+// TestRegisterAdvertisesCapabilities tests that the registration carries the driver's capabilities
+func TestRegisterAdvertisesCapabilities(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	reg := a.registerEnvelope().GetRegister()
+	if !strings.Contains(strings.Join(reg.Capabilities, ","), CapabilityDriftDetection) {
+		t.Errorf("fluentbit registration capabilities = %v", reg.Capabilities)
+	}
+
+	a = NewDeviceAgent("unused", "device-1", "local-supervisor", filepath.Join(t.TempDir(), "config.yaml"), "", Options{})
+	for _, c := range a.registerEnvelope().GetRegister().Capabilities {
+		if c == CapabilityReload || c == CapabilityDriftDetection {
+			t.Errorf("local supervisor advertises %s", c)
+		}
+	}
+}
+
+// TestLocalSupervisorDriver tests forwarding configs to the local supervisor
+func TestLocalSupervisorDriver(t *testing.T) {
+	var mu sync.Mutex
+	running := []byte("receivers: {}\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodGet {
+			w.Write(running)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "BROKEN") {
+			http.Error(w, "invalid config", http.StatusBadRequest)
+			return
+		}
+		running = body
+	}))
+	defer srv.Close()
+
+	d := newLocalSupervisorDriver(DriverConfig{NodeID: "device-1", LocalSupervisorURL: srv.URL})
+	ctx := context.Background()
+
+	if _, err := d.Apply(ctx, []byte("exporters: {}\n")); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got, err := d.EffectiveConfig(ctx); err != nil || string(got) != "exporters: {}\n" {
+		t.Errorf("effective config = %q, %v", got, err)
+	}
+	if _, err := d.Apply(ctx, []byte("BROKEN")); err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Errorf("rejected config: got %v, want HTTP 400", err)
+	}
+	if h := d.Health(ctx); !h.Healthy {
+		t.Errorf("health = %+v, want healthy", h)
+	}
+	if err := d.Reload(ctx); err != errNotSupported {
+		t.Errorf("Reload = %v, want errNotSupported", err)
+	}
+	if err := d.Restart(ctx); err != errNotSupported || hasCapability(d, CapabilityRestart) {
+		t.Errorf("Restart = %v, want errNotSupported and no restart capability", err)
+	}
+}
+
+// TestLocalSupervisorDriverRestart tests restarting the collector in the pid
+// file, with the test standing in for the local supervisor that starts it again
+func TestLocalSupervisorDriverRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported on Windows")
+	}
+	pidFile := filepath.Join(t.TempDir(), "otelcol.pid")
+	start := func() *exec.Cmd {
+		cmd := exec.Command("sleep", "60")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("start collector: %v", err)
+		}
+		t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+		os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0644)
+		return cmd
+	}
+	first := start()
+	restarted := make(chan *exec.Cmd, 1)
+	go func() {
+		first.Wait()
+		restarted <- start()
+	}()
+
+	d := newLocalSupervisorDriver(DriverConfig{
+		NodeID:             "device-1",
+		PIDFile:            pidFile,
+		ReloadTimeout:      5 * time.Second,
+		ReloadPollInterval: 10 * time.Millisecond,
+	})
+	if !hasCapability(d, CapabilityRestart) {
+		t.Fatalf("capabilities = %v, want restart with a pid file", d.Capabilities())
+	}
+	if err := d.Restart(context.Background()); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if next := <-restarted; next.Process.Pid == first.Process.Pid {
+		t.Errorf("collector pid unchanged after restart")
+	}
+}
+
+// TestFileDriverHTTPReload tests apply, reload failure and rollback over HTTP
+func TestFileDriverHTTPReload(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "agent.conf")
+	var mu sync.Mutex
+	reloads := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		reloads++
+		if data, _ := os.ReadFile(configPath); strings.Contains(string(data), "BROKEN") {
+			http.Error(w, "parse error", http.StatusUnprocessableEntity)
+		}
+	}))
+	defer srv.Close()
+
+	d := newFileDriver(DriverConfig{
+		NodeID:         "device-1",
+		ConfigPath:     configPath,
+		LastGoodPath:   filepath.Join(dir, "state", "agent.conf.last-good"),
+		ReloadEndpoint: srv.URL,
+		ReloadTimeout:  time.Second,
+	})
+	ctx := context.Background()
+
+	if _, err := d.Apply(ctx, []byte("good")); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	result, err := d.Apply(ctx, []byte("BROKEN"))
+	if err == nil || !result.RolledBack || !strings.Contains(err.Error(), "HTTP 422") {
+		t.Errorf("broken config: rolled back %v, err %v", result.RolledBack, err)
+	}
+	if got, _ := d.EffectiveConfig(ctx); string(got) != "good" {
+		t.Errorf("effective config after rollback = %q, want %q", got, "good")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if reloads != 3 {
+		t.Errorf("reloads = %d, want 3", reloads)
+	}
+}
+
+// TestFileDriverSignalReload tests reloading by signalling the process in the pid file
+func TestFileDriverSignalReload(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are not supported on Windows")
+	}
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "collector.pid")
+	os.WriteFile(pidFile, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+
+	sig, err := parseSignal("sigusr1")
+	if err != nil {
+		t.Fatalf("parseSignal: %v", err)
+	}
+	got := make(chan os.Signal, 1)
+	signal.Notify(got, sig)
+	defer signal.Stop(got)
+
+	d := newFileDriver(DriverConfig{
+		NodeID:       "device-1",
+		ConfigPath:   filepath.Join(dir, "agent.conf"),
+		LastGoodPath: filepath.Join(dir, "agent.conf.last-good"),
+		ReloadSignal: sig,
+		PIDFile:      pidFile,
+	})
+	if _, err := d.Apply(context.Background(), []byte("config")); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	select {
+	case s := <-got:
+		if s != sig {
+			t.Errorf("got signal %v, want %v", s, sig)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload signal received")
+	}
+	if h := d.Health(context.Background()); !h.Healthy {
+		t.Errorf("health = %+v, want healthy", h)
+	}
+
+	if _, err := parseSignal("KILL"); err == nil {
+		t.Error("parseSignal accepted KILL")
+	}
+}
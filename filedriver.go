@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// fileDriver manages any collector that reads its config from a file: it
+// writes the file and asks the collector to reload it, either by sending
+// ReloadSignal to the process in PIDFile or by POSTing to ReloadEndpoint.
+// With neither set, a collector run by the agent (--exec) is restarted;
+// otherwise the collector is expected to watch the file itself.
+type fileDriver struct {
+	nodeID         string
+	signal         syscall.Signal
+	pidFile        string
+	reloadEndpoint string
+	client         *http.Client
+	file           managedFile
+	child          *processSupervisor
+}
+
+func newFileDriver(cfg DriverConfig) Driver {
+	return &fileDriver{
+		nodeID:         cfg.NodeID,
+		signal:         cfg.ReloadSignal,
+		pidFile:        cfg.PIDFile,
+		reloadEndpoint: cfg.ReloadEndpoint,
+		client:         &http.Client{Timeout: cfg.ReloadTimeout},
+		file:           managedFile{nodeID: cfg.NodeID, path: cfg.ConfigPath, lastGoodPath: cfg.LastGoodPath},
+		child:          cfg.Child,
+	}
+}
+
+func (d *fileDriver) Capabilities() []string {
+	caps := []string{
+		CapabilityApplyConfig,
+		CapabilityEffectiveConfig,
+		CapabilityReload,
+		CapabilityRollback,
+		CapabilityDriftDetection,
+	}
+	if d.pidFile != "" || d.child != nil {
+		caps = append(caps, CapabilityHealth)
+	}
+	if d.child != nil {
+		caps = append(caps, CapabilityRestart)
+	}
+	return caps
+}
+
+// Apply writes config and reloads it, restoring the last-known-good config
+// if the reload fails. A reload that is accepted is trusted; the collector
+// gives no generic way to confirm it loaded the file.
+func (d *fileDriver) Apply(ctx context.Context, config []byte) (ApplyResult, error) {
+	rolledBack, err := d.file.apply(config, func() error { return d.Reload(ctx) })
+	return ApplyResult{RolledBack: rolledBack}, err
+}
+
+func (d *fileDriver) EffectiveConfig(ctx context.Context) ([]byte, error) {
+	return d.file.read()
+}
+
+func (d *fileDriver) Health(ctx context.Context) DriverHealth {
+	if d.child != nil {
+		if st := d.child.status(); st.Running {
+			return DriverHealth{Healthy: true, Status: fmt.Sprintf("process %d running", st.PID)}
+		}
+		return DriverHealth{Status: "collector process not running"}
+	}
+	if d.pidFile == "" {
+		return DriverHealth{Status: "unknown: no --pid-file configured"}
+	}
+	proc, err := d.process()
+	if err != nil {
+		return DriverHealth{Status: err.Error()}
+	}
+	// Signal 0 checks that the process exists without disturbing it
+	if err := proc.Signal(syscall.Signal(0)); err != nil {
+		return DriverHealth{Status: fmt.Sprintf("process %d not running: %v", proc.Pid, err)}
+	}
+	return DriverHealth{Healthy: true, Status: fmt.Sprintf("process %d running", proc.Pid)}
+}
+
+func (d *fileDriver) Reload(ctx context.Context) error {
+	switch {
+	case d.signal != 0 && d.child != nil:
+		nodeLogger(d.nodeID).Info("Signalling collector", "signal", d.signal)
+		return d.child.Signal(d.signal)
+
+	case d.signal != 0:
+		proc, err := d.process()
+		if err != nil {
+			return err
+		}
+		nodeLogger(d.nodeID).Info("Signalling collector", "signal", d.signal, "pid", proc.Pid)
+		if err := proc.Signal(d.signal); err != nil {
+			return fmt.Errorf("failed to signal process %d: %w", proc.Pid, err)
+		}
+		return nil
+
+	case d.reloadEndpoint != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.reloadEndpoint, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build reload request: %w", err)
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("reload request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("reload rejected: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		nodeLogger(d.nodeID).Info("Reload accepted", "status", resp.StatusCode)
+		return nil
+
+	case d.child != nil:
+		// No way to reload in place, so start the collector over on the new file
+		return d.child.Restart(ctx)
+	}
+	return nil
+}
+
+// Restart restarts the collector when the agent runs it (--exec).
+func (d *fileDriver) Restart(ctx context.Context) error {
+	if d.child == nil {
+		return errNotSupported
+	}
+	return d.child.Restart(ctx)
+}
+
+func (d *fileDriver) Metrics(ctx context.Context) (map[string]float64, error) {
+	return nil, errNotSupported
+}
+
+// process finds the collector from its pid file.
+func (d *fileDriver) process() (*os.Process, error) {
+	if d.pidFile == "" {
+		return nil, fmt.Errorf("no pid file configured")
+	}
+	return findProcess(d.pidFile)
+}
+
+// findProcess returns the process whose pid is in pidFile.
+func findProcess(pidFile string) (*os.Process, error) {
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pid file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return nil, fmt.Errorf("invalid pid in %s: %q", pidFile, strings.TrimSpace(string(data)))
+	}
+	return os.FindProcess(pid)
+}
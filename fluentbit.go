@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReloadOutcome classifies how a Fluent Bit hot reload ended.
+type ReloadOutcome string
+
+const (
+	ReloadApplied  ReloadOutcome = "reload applied"
+	ReloadTimedOut ReloadOutcome = "reload timed out"
+	ReloadRejected ReloadOutcome = "reload rejected"
+)
+
+// reloadResult is reported in ConfigAck.ErrorMessage and the FluentBitReload event.
+type reloadResult struct {
+	Outcome     ReloadOutcome `json:"outcome"`
+	CountBefore int64         `json:"hot_reload_count_before"`
+	CountAfter  int64         `json:"hot_reload_count_after"`
+	Detail      string        `json:"detail,omitempty"`
+	// RolledBack is set when the previous config had to be restored
+	RolledBack bool `json:"rolled_back"`
+}
+
+func (r reloadResult) String() string {
+	if r.Detail == "" {
+		return string(r.Outcome)
+	}
+	return fmt.Sprintf("%s: %s", r.Outcome, r.Detail)
+}
+
+// fluentBitState is the part of Fluent Bit's runtime state that changes on reload.
+type fluentBitState struct {
+	HotReloadCount int64
+	UptimeSec      int64
+}
+
+// fluentBitDriver manages Fluent Bit directly: it writes the config file and
+// hot reloads it through Fluent Bit's HTTP API.
+type fluentBitDriver struct {
+	nodeID             string
+	reloadEndpoint     string
+	reloadTimeout      time.Duration
+	reloadPollInterval time.Duration
+	file               managedFile
+	child              *processSupervisor
+
+	// metricsMu guards the previous scrape that metric deltas are taken against
+	metricsMu   sync.Mutex
+	lastMetrics *fluentBitSample
+	lastScrape  time.Time
+}
+
+func newFluentBitDriver(cfg DriverConfig) Driver {
+	return &fluentBitDriver{
+		nodeID:             cfg.NodeID,
+		reloadEndpoint:     cfg.ReloadEndpoint,
+		reloadTimeout:      cfg.ReloadTimeout,
+		reloadPollInterval: cfg.ReloadPollInterval,
+		file:               managedFile{nodeID: cfg.NodeID, path: cfg.ConfigPath, lastGoodPath: cfg.LastGoodPath},
+		child:              cfg.Child,
+	}
+}
+
+func (d *fluentBitDriver) Capabilities() []string {
+	return []string{
+		CapabilityApplyConfig,
+		CapabilityEffectiveConfig,
+		CapabilityHealth,
+		CapabilityReload,
+		CapabilityRestart,
+		CapabilityMetrics,
+		CapabilityRollback,
+		CapabilityDriftDetection,
+	}
+}
+
+// Apply writes and reloads a new config, restoring the last-known-good one
+// if Fluent Bit does not apply it. The result's event describes the reload
+// of the pushed config, even when err is set.
+func (d *fluentBitDriver) Apply(ctx context.Context, config []byte) (ApplyResult, error) {
+	var result reloadResult
+	reloads := 0
+	rolledBack, err := d.file.apply(config, func() error {
+		r := d.reload(ctx)
+		if reloads++; reloads == 1 {
+			result = r
+		}
+		if r.Outcome != ReloadApplied {
+			return errors.New(r.String())
+		}
+		nodeLogger(d.nodeID).Info("Fluent Bit reloaded", "outcome", r.String(), "hot_reload_count_before", r.CountBefore, "hot_reload_count_after", r.CountAfter)
+		return nil
+	})
+	result.RolledBack = rolledBack
+	applied := ApplyResult{RolledBack: rolledBack}
+	if result.Outcome != "" {
+		applied.EventType, applied.Event = "FluentBitReload", result
+	}
+	return applied, err
+}
+
+// EffectiveConfig checks that Fluent Bit is running before reporting the
+// config file; when its API is unreachable the file is all there is.
+func (d *fluentBitDriver) EffectiveConfig(ctx context.Context) ([]byte, error) {
+	// In production, we would parse Fluent Bit's actual output plugin configuration
+	if _, err := d.uptime(); err != nil {
+		nodeLogger(d.nodeID).Warn("Fluent Bit API not available, reading config from file", "error", err)
+	} else {
+		nodeLogger(d.nodeID).Debug("Fluent Bit is running, reporting config from file")
+	}
+	return d.file.read()
+}
+
+// Health combines the uptime endpoint with /api/v1/health, which Fluent Bit
+// only serves when Health_Check is enabled in its [SERVICE] section.
+func (d *fluentBitDriver) Health(ctx context.Context) DriverHealth {
+	uptime, err := d.uptime()
+	if err != nil {
+		return DriverHealth{Status: fmt.Sprintf("Fluent Bit API unreachable: %v", err)}
+	}
+	health := DriverHealth{Healthy: true, Status: fmt.Sprintf("running for %ds", uptime), UptimeSeconds: uptime}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(d.api("/api/v1/health"))
+	if err != nil {
+		return health
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// Health_Check is off; uptime is all there is
+	default:
+		health.Healthy = false
+		health.Status = fmt.Sprintf("health check failing (HTTP %d %s), running for %ds",
+			resp.StatusCode, strings.TrimSpace(string(body)), uptime)
+	}
+	return health
+}
+
+func (d *fluentBitDriver) Reload(ctx context.Context) error {
+	if result := d.reload(ctx); result.Outcome != ReloadApplied {
+		return errors.New(result.String())
+	}
+	return nil
+}
+
+// Restart restarts the child when the agent runs Fluent Bit itself.
+// Otherwise a hot reload, which tears down and rebuilds every pipeline, is
+// as close to a restart as the HTTP API allows.
+func (d *fluentBitDriver) Restart(ctx context.Context) error {
+	if d.child == nil {
+		return d.Reload(ctx)
+	}
+	if result := d.restartChild(ctx, reloadResult{}); result.Outcome != ReloadApplied {
+		return errors.New(result.String())
+	}
+	return nil
+}
+
+func (d *fluentBitDriver) Metrics(ctx context.Context) (map[string]float64, error) {
+	st, err := d.state()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]float64{
+		"hot_reload_count": float64(st.HotReloadCount),
+		"uptime_seconds":   float64(st.UptimeSec),
+	}, nil
+}
+
+// api derives a Fluent Bit HTTP API URL from the reload endpoint
+// (e.g. http://fluentbit-device-1.opamp-edge.svc.cluster.local:2020/api/v2/reload).
+func (d *fluentBitDriver) api(path string) string {
+	return strings.Replace(d.reloadEndpoint, "/api/v2/reload", path, 1)
+}
+
+// state reads the hot reload counter (GET /api/v2/reload) and uptime.
+func (d *fluentBitDriver) state() (fluentBitState, error) {
+	client := &http.Client{Timeout: 2 * time.Second}
+	var st fluentBitState
+
+	var reload struct {
+		HotReloadCount *int64 `json:"hot_reload_count"`
+	}
+	if err := getJSON(client, d.reloadEndpoint, &reload); err != nil {
+		return st, fmt.Errorf("failed to read hot reload count: %w", err)
+	}
+	if reload.HotReloadCount == nil {
+		return st, fmt.Errorf("hot_reload_count missing from %s", d.reloadEndpoint)
+	}
+	st.HotReloadCount = *reload.HotReloadCount
+
+	uptime, err := d.uptime()
+	if err != nil {
+		return st, fmt.Errorf("failed to read uptime: %w", err)
+	}
+	st.UptimeSec = uptime
+	return st, nil
+}
+
+func getJSON(client *http.Client, url string, v interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, v)
+}
+
+// reload triggers a hot reload and waits until Fluent Bit's
+// hot_reload_count increments (or its uptime resets after a full restart).
+// Fluent Bit versions without the counter fall back to waiting for the
+// uptime endpoint to answer again.
+func (d *fluentBitDriver) reload(ctx context.Context) reloadResult {
+	before, beforeErr := d.state()
+	if beforeErr != nil {
+		nodeLogger(d.nodeID).Warn("Cannot read reload counter, falling back to liveness check", "error", beforeErr)
+	}
+	result := reloadResult{CountBefore: before.HotReloadCount, CountAfter: before.HotReloadCount}
+	if beforeErr != nil && d.child != nil {
+		// Without hot reload, restarting the child is how it picks up the new config
+		return d.restartChild(ctx, result)
+	}
+
+	nodeLogger(d.nodeID).Info("Calling Fluent Bit reload API", "endpoint", d.reloadEndpoint, "hot_reload_count", before.HotReloadCount)
+
+	client := &http.Client{Timeout: d.reloadTimeout}
+	req, err := http.NewRequestWithContext(ctx, "POST", d.reloadEndpoint, bytes.NewReader([]byte{}))
+	if err != nil {
+		result.Outcome = ReloadRejected
+		result.Detail = fmt.Sprintf("failed to build reload request: %v", err)
+		return result
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// FluentBit hot reload can hang during certain config transitions,
+		// but the reload may still succeed - let the counter decide
+		nodeLogger(d.nodeID).Warn("Reload API call failed, the reload may still succeed", "error", err)
+	} else {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		nodeLogger(d.nodeID).Debug("Fluent Bit reload response", "status", resp.StatusCode, "body", string(body))
+
+		var status struct {
+			Status *int `json:"status"`
+		}
+		json.Unmarshal(body, &status)
+		if resp.StatusCode >= 300 || (status.Status != nil && *status.Status < 0) {
+			result.Outcome = ReloadRejected
+			result.Detail = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+			return result
+		}
+	}
+
+	deadline := time.Now().Add(d.reloadTimeout)
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			result.Outcome = ReloadTimedOut
+			result.Detail = fmt.Sprintf("stopped waiting for the reload: %v", ctx.Err())
+			return result
+		case <-time.After(d.reloadPollInterval):
+		}
+
+		after, err := d.state()
+		switch {
+		case beforeErr != nil:
+			// No counter to compare against; a live uptime endpoint is the best we have
+			_, upErr := d.uptime()
+			if upErr == nil {
+				result.Outcome = ReloadApplied
+				result.Detail = "hot_reload_count unavailable, verified liveness only"
+				return result
+			}
+			lastErr = upErr
+		case err != nil:
+			// Expected while the HTTP server restarts during the reload
+			lastErr = err
+		default:
+			result.CountAfter = after.HotReloadCount
+			if after.HotReloadCount > before.HotReloadCount {
+				result.Outcome = ReloadApplied
+				return result
+			}
+			if after.UptimeSec < before.UptimeSec {
+				result.Outcome = ReloadApplied
+				result.Detail = "Fluent Bit restarted (uptime reset)"
+				return result
+			}
+			lastErr = fmt.Errorf("hot_reload_count still %d", after.HotReloadCount)
+		}
+
+		if time.Now().After(deadline) {
+			result.Outcome = ReloadTimedOut
+			result.Detail = fmt.Sprintf("no reload observed within %s: %v", d.reloadTimeout, lastErr)
+			return result
+		}
+	}
+}
+
+// restartChild restarts the Fluent Bit the agent runs and waits for its API
+// to answer again.
+func (d *fluentBitDriver) restartChild(ctx context.Context, result reloadResult) reloadResult {
+	restartCtx, cancel := context.WithTimeout(ctx, d.reloadTimeout+childStopGrace)
+	defer cancel()
+	if err := d.child.Restart(restartCtx); err != nil {
+		result.Outcome = ReloadRejected
+		result.Detail = fmt.Sprintf("restart failed: %v", err)
+		return result
+	}
+
+	deadline := time.Now().Add(d.reloadTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			result.Outcome = ReloadTimedOut
+			result.Detail = fmt.Sprintf("stopped waiting for Fluent Bit to come back: %v", ctx.Err())
+			return result
+		case <-time.After(d.reloadPollInterval):
+		}
+		_, err := d.uptime()
+		if err == nil {
+			result.Outcome = ReloadApplied
+			result.Detail = "hot reload unavailable, restarted Fluent Bit"
+			return result
+		}
+		if time.Now().After(deadline) {
+			result.Outcome = ReloadTimedOut
+			result.Detail = fmt.Sprintf("Fluent Bit did not come back within %s of a restart: %v", d.reloadTimeout, err)
+			return result
+		}
+	}
+}
+
+func (d *fluentBitDriver) uptime() (int64, error) {
+	var uptime struct {
+		UptimeSec int64 `json:"uptime_sec"`
+	}
+	client := &http.Client{Timeout: 2 * time.Second}
+	if err := getJSON(client, d.api("/api/v1/uptime"), &uptime); err != nil {
+		return 0, err
+	}
+	return uptime.UptimeSec, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// pluginCounters are the Fluent Bit counters reported for one plugin
+// instance. Inputs only count records and bytes.
+type pluginCounters struct {
+	Records float64 `json:"records"`
+	Bytes   float64 `json:"bytes"`
+	Retries float64 `json:"retries"`
+	Errors  float64 `json:"errors"`
+}
+
+// pluginMetrics is one plugin instance in a FluentBitMetrics event.
+type pluginMetrics struct {
+	Total pluginCounters `json:"total"`
+	// Delta is the change since the previous scrape, absent on the first one
+	Delta *pluginCounters `json:"delta,omitempty"`
+}
+
+// fluentBitSample is one scrape, keyed by plugin instance name (e.g. "tail.0").
+type fluentBitSample struct {
+	Inputs  map[string]pluginCounters
+	Outputs map[string]pluginCounters
+}
+
+// fluentBitMetricsReport is the payload of a FluentBitMetrics event.
+type fluentBitMetricsReport struct {
+	Source    string    `json:"source"`
+	Timestamp time.Time `json:"timestamp"`
+	// IntervalSeconds is the time since the previous scrape the deltas cover
+	IntervalSeconds float64                  `json:"interval_seconds,omitempty"`
+	Inputs          map[string]pluginMetrics `json:"inputs"`
+	Outputs         map[string]pluginMetrics `json:"outputs"`
+}
+
+// prometheusCounters maps Fluent Bit's Prometheus metric names to the
+// counter they feed.
+var prometheusCounters = map[string]struct {
+	output bool
+	field  func(*pluginCounters) *float64
+}{
+	"fluentbit_input_records_total":       {false, func(c *pluginCounters) *float64 { return &c.Records }},
+	"fluentbit_input_bytes_total":         {false, func(c *pluginCounters) *float64 { return &c.Bytes }},
+	"fluentbit_output_proc_records_total": {true, func(c *pluginCounters) *float64 { return &c.Records }},
+	"fluentbit_output_proc_bytes_total":   {true, func(c *pluginCounters) *float64 { return &c.Bytes }},
+	"fluentbit_output_retries_total":      {true, func(c *pluginCounters) *float64 { return &c.Retries }},
+	"fluentbit_output_errors_total":       {true, func(c *pluginCounters) *float64 { return &c.Errors }},
+}
+
+// MetricsReport scrapes Fluent Bit's plugin counters and reports them with
+// their change since the previous scrape.
+func (d *fluentBitDriver) MetricsReport(ctx context.Context) (string, interface{}, error) {
+	sample, source, err := d.scrapeMetrics(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	report := fluentBitMetricsReport{Source: source, Timestamp: now}
+
+	d.metricsMu.Lock()
+	prev, prevAt := d.lastMetrics, d.lastScrape
+	d.lastMetrics, d.lastScrape = &sample, now
+	d.metricsMu.Unlock()
+
+	var prevInputs, prevOutputs map[string]pluginCounters
+	if prev != nil {
+		report.IntervalSeconds = now.Sub(prevAt).Seconds()
+		prevInputs, prevOutputs = prev.Inputs, prev.Outputs
+	}
+	report.Inputs = pluginDeltas(sample.Inputs, prevInputs, prev != nil)
+	report.Outputs = pluginDeltas(sample.Outputs, prevOutputs, prev != nil)
+	return "FluentBitMetrics", report, nil
+}
+
+// pluginDeltas pairs each plugin's totals with their change since prev.
+// A counter that went down means Fluent Bit restarted or reloaded, which
+// resets it, so the new total is the delta.
+func pluginDeltas(cur, prev map[string]pluginCounters, havePrev bool) map[string]pluginMetrics {
+	out := make(map[string]pluginMetrics, len(cur))
+	for name, c := range cur {
+		m := pluginMetrics{Total: c}
+		if havePrev {
+			p := prev[name]
+			m.Delta = &pluginCounters{
+				Records: counterDelta(c.Records, p.Records),
+				Bytes:   counterDelta(c.Bytes, p.Bytes),
+				Retries: counterDelta(c.Retries, p.Retries),
+				Errors:  counterDelta(c.Errors, p.Errors),
+			}
+		}
+		out[name] = m
+	}
+	return out
+}
+
+func counterDelta(cur, prev float64) float64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
+
+// scrapeMetrics reads the Prometheus endpoint, falling back to the v1 JSON
+// endpoint on Fluent Bit versions without it.
+func (d *fluentBitDriver) scrapeMetrics(ctx context.Context) (fluentBitSample, string, error) {
+	const v2, v1 = "/api/v2/metrics/prometheus", "/api/v1/metrics"
+	body, err := d.fetch(ctx, v2)
+	if err == nil {
+		sample, err := parsePrometheusMetrics(body)
+		return sample, v2, err
+	}
+	body, v1Err := d.fetch(ctx, v1)
+	if v1Err != nil {
+		return fluentBitSample{}, "", fmt.Errorf("failed to scrape Fluent Bit metrics: %v; %v", err, v1Err)
+	}
+	sample, err := parseJSONMetrics(body)
+	return sample, v1, err
+}
+
+func (d *fluentBitDriver) fetch(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.api(path), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: HTTP %d", path, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// parsePrometheusMetrics reads the plugin counters from Fluent Bit's
+// Prometheus text exposition, e.g.
+//
+//	fluentbit_output_proc_records_total{name="stdout.0"} 54 1509150350542
+func parsePrometheusMetrics(data []byte) (fluentBitSample, error) {
+	sample := fluentBitSample{Inputs: map[string]pluginCounters{}, Outputs: map[string]pluginCounters{}}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		open := strings.IndexByte(line, '{')
+		end := strings.IndexByte(line, '}')
+		if open < 0 || end < open {
+			continue
+		}
+		counter, ok := prometheusCounters[line[:open]]
+		if !ok {
+			continue
+		}
+		name := labelValue(line[open+1:end], "name")
+		fields := strings.Fields(line[end+1:])
+		if name == "" || len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return fluentBitSample{}, fmt.Errorf("bad value in %q: %w", line, err)
+		}
+		plugins := sample.Inputs
+		if counter.output {
+			plugins = sample.Outputs
+		}
+		c := plugins[name]
+		*counter.field(&c) = v
+		plugins[name] = c
+	}
+	return sample, scanner.Err()
+}
+
+// labelValue returns the value of label key in `a="x",b="y"`.
+func labelValue(labels, key string) string {
+	for _, pair := range strings.Split(labels, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) == key {
+			if unquoted, err := strconv.Unquote(strings.TrimSpace(v)); err == nil {
+				return unquoted
+			}
+		}
+	}
+	return ""
+}
+
+// parseJSONMetrics reads the plugin counters from /api/v1/metrics.
+func parseJSONMetrics(data []byte) (fluentBitSample, error) {
+	var raw struct {
+		Input map[string]struct {
+			Records float64 `json:"records"`
+			Bytes   float64 `json:"bytes"`
+		} `json:"input"`
+		Output map[string]struct {
+			ProcRecords float64 `json:"proc_records"`
+			ProcBytes   float64 `json:"proc_bytes"`
+			Retries     float64 `json:"retries"`
+			Errors      float64 `json:"errors"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fluentBitSample{}, fmt.Errorf("failed to decode Fluent Bit metrics: %w", err)
+	}
+	sample := fluentBitSample{Inputs: map[string]pluginCounters{}, Outputs: map[string]pluginCounters{}}
+	for name, in := range raw.Input {
+		sample.Inputs[name] = pluginCounters{Records: in.Records, Bytes: in.Bytes}
+	}
+	for name, out := range raw.Output {
+		sample.Outputs[name] = pluginCounters{Records: out.ProcRecords, Bytes: out.ProcBytes, Retries: out.Retries, Errors: out.Errors}
+	}
+	return sample, nil
+}
+
+// proto is the typed form of the report.
+func (r fluentBitMetricsReport) proto() *controlpb.MetricsReport {
+	return &controlpb.MetricsReport{
+		Source:          r.Source,
+		IntervalSeconds: r.IntervalSeconds,
+		Inputs:          pluginMetricsProto(r.Inputs),
+		Outputs:         pluginMetricsProto(r.Outputs),
+	}
+}
+
+func pluginMetricsProto(plugins map[string]pluginMetrics) map[string]*controlpb.PluginMetrics {
+	counters := func(c pluginCounters) *controlpb.PluginCounters {
+		return &controlpb.PluginCounters{Records: c.Records, Bytes: c.Bytes, Retries: c.Retries, Errors: c.Errors}
+	}
+	out := make(map[string]*controlpb.PluginMetrics, len(plugins))
+	for name, m := range plugins {
+		p := &controlpb.PluginMetrics{Total: counters(m.Total)}
+		if m.Delta != nil {
+			p.Delta = counters(*m.Delta)
+		}
+		out[name] = p
+	}
+	return out
+}
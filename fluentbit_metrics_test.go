@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+const prometheusSample = `# HELP fluentbit_input_records_total Number of input records.
+# TYPE fluentbit_input_records_total counter
+fluentbit_input_records_total{name="tail.0"} %d 1509150350542
+fluentbit_input_bytes_total{name="tail.0"} %d 1509150350542
+fluentbit_output_proc_records_total{name="http.0"} %d
+fluentbit_output_proc_bytes_total{name="http.0"} 2048
+fluentbit_output_retries_total{name="http.0"} 3
+fluentbit_output_errors_total{name="http.0"} 1
+fluentbit_uptime{hostname="edge"} 100
+`
+
+// TestParsePrometheusMetrics tests reading plugin counters from the Prometheus endpoint
+func TestParsePrometheusMetrics(t *testing.T) {
+	got, err := parsePrometheusMetrics([]byte(fmt.Sprintf(prometheusSample, 10, 1000, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fluentBitSample{
+		Inputs:  map[string]pluginCounters{"tail.0": {Records: 10, Bytes: 1000}},
+		Outputs: map[string]pluginCounters{"http.0": {Records: 8, Bytes: 2048, Retries: 3, Errors: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestParseJSONMetrics tests reading plugin counters from /api/v1/metrics
+func TestParseJSONMetrics(t *testing.T) {
+	data := `{"input":{"cpu.0":{"records":8,"bytes":2536}},
+		"output":{"stdout.0":{"proc_records":5,"proc_bytes":1603,"errors":2,"retries":1,"retries_failed":0}}}`
+	got, err := parseJSONMetrics([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fluentBitSample{
+		Inputs:  map[string]pluginCounters{"cpu.0": {Records: 8, Bytes: 2536}},
+		Outputs: map[string]pluginCounters{"stdout.0": {Records: 5, Bytes: 1603, Retries: 1, Errors: 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestFluentBitMetricsDeltas tests deltas between scrapes, including counter resets
+func TestFluentBitMetricsDeltas(t *testing.T) {
+	var mu sync.Mutex
+	records := []int{10, 25, 5}
+	scrapes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/metrics/prometheus" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		n := records[scrapes]
+		scrapes++
+		fmt.Fprintf(w, prometheusSample, n, n*100, n)
+	}))
+	defer srv.Close()
+	d := newFluentBitDriver(DriverConfig{ReloadEndpoint: srv.URL + "/api/v2/reload"}).(*fluentBitDriver)
+
+	wantDeltas := []*pluginCounters{
+		nil,
+		{Records: 15, Bytes: 1500},
+		// Fluent Bit restarted: the counters start over
+		{Records: 5, Bytes: 500},
+	}
+	for i, want := range wantDeltas {
+		eventType, report, err := d.MetricsReport(context.Background())
+		if err != nil {
+			t.Fatalf("scrape %d: %v", i, err)
+		}
+		r := report.(fluentBitMetricsReport)
+		if eventType != "FluentBitMetrics" || r.Source != "/api/v2/metrics/prometheus" {
+			t.Errorf("scrape %d: %s from %s", i, eventType, r.Source)
+		}
+		if got := r.Inputs["tail.0"].Delta; !reflect.DeepEqual(got, want) {
+			t.Errorf("scrape %d: input delta %+v, want %+v", i, got, want)
+		}
+	}
+}
+
+// TestFetchMetrics tests the FetchMetrics command against the v1 endpoint
+func TestFetchMetrics(t *testing.T) {
+	a, fb := newFluentBitAgent(t)
+	fb.Config.Handler.(*http.ServeMux).HandleFunc("/api/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"input":{"dummy.0":{"records":3,"bytes":90}},"output":{"stdout.0":{"proc_records":3,"proc_bytes":90}}}`)
+	})
+
+	a.handleCommand(context.Background(), &controlpb.Command{Type: "FetchMetrics", CorrelationId: "c-1"})
+	ev := nextEvent(t, a, "FluentBitMetrics")
+	if ev.CorrelationId != "c-1" {
+		t.Errorf("correlation id %q", ev.CorrelationId)
+	}
+	var report fluentBitMetricsReport
+	decodeEvent(t, ev, &report)
+	if report.Source != "/api/v1/metrics" || report.Outputs["stdout.0"].Total.Records != 3 {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// heartbeatProtocolVersion is the first protocol version with Heartbeat.
+const heartbeatProtocolVersion = 3
+
+// errServerSilent fails a stream on which the server stopped sending, which
+// is how a half-open connection shows itself.
+var errServerSilent = errors.New("no heartbeat from server")
+
+// heartbeats tracks the Heartbeat exchange on one stream. The receive loop
+// and the goroutine sending heartbeats share it.
+type heartbeats struct {
+	mu sync.Mutex
+	// seq is the last heartbeat sent, peerSeq the last one received
+	seq     uint64
+	peerSeq uint64
+	// lastHeard is when anything last arrived from the server
+	lastHeard time.Time
+	// interval hands a renegotiated interval to the sending goroutine
+	interval chan time.Duration
+}
+
+func newHeartbeats() *heartbeats {
+	return &heartbeats{lastHeard: time.Now(), interval: make(chan time.Duration, 1)}
+}
+
+// heard restarts the silence timer.
+func (h *heartbeats) heard() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastHeard = time.Now()
+}
+
+// setInterval changes how often heartbeats are sent; only the newest
+// interval matters.
+func (h *heartbeats) setInterval(d time.Duration) {
+	select {
+	case <-h.interval:
+	default:
+	}
+	h.interval <- d
+}
+
+// heartbeatTimeout is how long the server may stay silent.
+func (a *DeviceAgent) heartbeatTimeout() time.Duration {
+	if a.opts.HeartbeatTimeout > 0 {
+		return a.opts.HeartbeatTimeout
+	}
+	return 3 * a.negotiated.heartbeatInterval()
+}
+
+// received notes a message from the server; any message proves the stream
+// is alive, not only heartbeats.
+func (a *DeviceAgent) received(h *heartbeats, envelope *controlpb.Envelope) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastHeard = time.Now()
+	hb := envelope.GetHeartbeat()
+	if hb == nil {
+		return
+	}
+	if h.peerSeq > 0 && hb.GetSeq() != h.peerSeq+1 {
+		a.log.Debug("Server heartbeats missing", "seq", hb.GetSeq(), "previous", h.peerSeq)
+	}
+	if ack := hb.GetAckSeq(); ack+1 < h.seq {
+		a.log.Debug("Server is behind on our heartbeats", "seq", h.seq, "ack_seq", hb.GetAckSeq())
+	}
+	h.peerSeq = hb.GetSeq()
+}
+
+// checkSilence fails once the server has been silent for longer than the
+// timeout. Servers that did not negotiate heartbeats are not expected to
+// send any.
+func (a *DeviceAgent) checkSilence(h *heartbeats) error {
+	if a.negotiated.protocol() < heartbeatProtocolVersion {
+		return nil
+	}
+	h.mu.Lock()
+	silent := time.Since(h.lastHeard)
+	h.mu.Unlock()
+	if silent > a.heartbeatTimeout() {
+		return fmt.Errorf("%w for %s", errServerSilent, silent.Round(time.Second))
+	}
+	return nil
+}
+
+// heartbeat sends the next heartbeat to servers that negotiated them.
+func (a *DeviceAgent) heartbeat(h *heartbeats) {
+	if a.negotiated.protocol() < heartbeatProtocolVersion {
+		return
+	}
+	h.mu.Lock()
+	h.seq++
+	envelope := &controlpb.Envelope{
+		Body: &controlpb.Envelope_Heartbeat{
+			Heartbeat: &controlpb.Heartbeat{Seq: h.seq, AckSeq: h.peerSeq, TsUnixNano: time.Now().UnixNano()},
+		},
+	}
+	h.mu.Unlock()
+	// A heartbeat is only worth anything on the stream it was meant for
+	if err := a.out.enqueue(envelope, PriorityNormal, false); err != nil {
+		a.log.Warn("Failed to queue heartbeat", "error", err)
+	}
+}
+
+// sendHeartbeats sends heartbeats until ctx is done. It runs apart from the
+// receive loop so they keep going while a handler, such as a long config
+// push, holds the loop up.
+func (a *DeviceAgent) sendHeartbeats(ctx context.Context, h *heartbeats) {
+	ticker := time.NewTicker(a.negotiated.heartbeatInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-h.interval:
+			ticker.Reset(d)
+		case <-ticker.C:
+			a.heartbeat(h)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// nextHeartbeat skips other messages until the agent sends a heartbeat
+func nextHeartbeat(t *testing.T, stream *memoryStream) *controlpb.Heartbeat {
+	t.Helper()
+	for {
+		if hb := stream.next(t).GetHeartbeat(); hb != nil {
+			return hb
+		}
+	}
+}
+
+// TestHeartbeatTimeout tests heartbeat sequencing and reconnecting when the server goes silent
+func TestHeartbeatTimeout(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "fluent-bit.conf")
+	os.WriteFile(configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+	mt := newMemoryTransport()
+	a := NewDeviceAgent("unused", "device-1", "file", configPath, "", Options{
+		Transport:        mt,
+		Backoff:          BackoffPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 1},
+		MonitorInterval:  time.Hour,
+		HeartbeatTimeout: 1500 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop()
+	stream := mt.accept(t)
+	stream.next(t) // registration
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_RegisterAck{RegisterAck: &controlpb.RegisterAck{
+		ProtocolVersion:     controlProtocolVersion,
+		HeartbeatIntervalMs: 1000,
+	}}})
+
+	if hb := nextHeartbeat(t, stream); hb.Seq != 1 || hb.AckSeq != 0 {
+		t.Errorf("first heartbeat = %v", hb)
+	}
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_Heartbeat{Heartbeat: &controlpb.Heartbeat{Seq: 1, AckSeq: 1}}})
+	if hb := nextHeartbeat(t, stream); hb.Seq != 2 || hb.AckSeq != 1 {
+		t.Errorf("second heartbeat = %v", hb)
+	}
+
+	// The server stops answering: the stream is dropped and replaced
+	old := stream
+	stream = mt.accept(t)
+	if !errors.Is(old.Err(), errServerSilent) {
+		t.Errorf("old stream failed with %v, want %v", old.Err(), errServerSilent)
+	}
+	if reg := stream.next(t).GetRegister(); reg.GetNodeId() != "device-1" {
+		t.Errorf("first message after reconnect is not a registration: %v", reg)
+	}
+	if conn := a.stats.connection(); !strings.Contains(conn.LastError, errServerSilent.Error()) {
+		t.Errorf("last error = %q", conn.LastError)
+	}
+}
+
+// TestNoHeartbeatsBeforeNegotiation tests that a server without RegisterAck is never timed out
+func TestNoHeartbeatsBeforeNegotiation(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	a.opts.HeartbeatTimeout = time.Millisecond
+	hb := newHeartbeats()
+	hb.lastHeard = time.Now().Add(-time.Hour)
+	a.resetNegotiation()
+	a.heartbeat(hb)
+	if err := a.checkSilence(hb); err != nil || hb.seq != 0 {
+		t.Errorf("protocol 1: err %v, seq %d", err, hb.seq)
+	}
+
+	a.negotiated.protocolVersion = heartbeatProtocolVersion
+	if err := a.checkSilence(hb); !errors.Is(err, errServerSilent) {
+		t.Errorf("protocol %d: err %v, want %v", heartbeatProtocolVersion, err, errServerSilent)
+	}
+}
+
+// slowValidator accepts every config after delay
+type slowValidator struct{ delay time.Duration }
+
+func (v slowValidator) Validate(ctx context.Context, config []byte) error {
+	select {
+	case <-time.After(v.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TestSlowHandlerKeepsStream tests that a handler running longer than the
+// heartbeat timeout does not count as the server going silent, and that
+// heartbeats keep going out while it runs
+func TestSlowHandlerKeepsStream(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "fluent-bit.conf")
+	os.WriteFile(configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+	mt := newMemoryTransport()
+	a := NewDeviceAgent("unused", "device-1", "file", configPath, "", Options{
+		Transport:        mt,
+		Backoff:          BackoffPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 1},
+		MonitorInterval:  time.Hour,
+		HeartbeatTimeout: 1500 * time.Millisecond,
+		Validator:        slowValidator{delay: 2500 * time.Millisecond},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop()
+	stream := mt.accept(t)
+	stream.next(t) // registration
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_RegisterAck{RegisterAck: &controlpb.RegisterAck{
+		ProtocolVersion:     controlProtocolVersion,
+		HeartbeatIntervalMs: 1000,
+	}}})
+
+	config := []byte("[OUTPUT]\n    Name null\n")
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_ConfigPush{ConfigPush: &controlpb.ConfigPush{
+		DeviceId:   "device-1",
+		ConfigData: config,
+		ConfigHash: configHash(config),
+	}}})
+	sent := 0
+	for {
+		env := stream.next(t)
+		if env.GetHeartbeat() != nil {
+			sent++
+		}
+		if ack := env.GetConfigAck(); ack != nil && ack.GetConfigHash() == configHash(config) {
+			if !ack.GetSuccess() {
+				t.Fatalf("push failed: %s", ack.GetErrorMessage())
+			}
+			break
+		}
+	}
+	if sent == 0 {
+		t.Error("no heartbeats sent while the push was validated")
+	}
+
+	// The server answers the next heartbeat and the stream stays up
+	hb := nextHeartbeat(t, stream)
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_Heartbeat{Heartbeat: &controlpb.Heartbeat{Seq: 1, AckSeq: hb.Seq}}})
+	nextHeartbeat(t, stream)
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream dropped after a slow push: %v", err)
+	}
+	select {
+	case s := <-mt.accepted:
+		t.Fatalf("agent reconnected after a slow push (new stream %p)", s)
+	default:
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// Outcomes recorded for each config that reached the device.
+const (
+	HistoryApplied    = "applied"
+	HistoryRejected   = "rejected"
+	HistoryRolledBack = "rolled_back"
+	HistoryFailed     = "failed"
+)
+
+// historyEntry describes one version in the config history.
+type historyEntry struct {
+	Version       int       `json:"version"`
+	Hash          string    `json:"hash"`
+	Timestamp     time.Time `json:"timestamp"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	Size          int       `json:"size"`
+}
+
+// configHistory is a versioned on-disk record of every config pushed to the
+// device. Each version is stored as <version>.json (metadata) plus
+// <version>.conf (content) so entries can be listed without reading configs.
+type configHistory struct {
+	dir string
+	max int
+
+	mu      sync.Mutex
+	entries []historyEntry
+}
+
+func openConfigHistory(dir string, max int) (*configHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history dir: %w", err)
+	}
+	h := &configHistory{dir: dir, max: max}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history dir: %w", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		var e historyEntry
+		if err := json.Unmarshal(data, &e); err != nil || e.Version == 0 {
+			continue
+		}
+		h.entries = append(h.entries, e)
+	}
+	sort.Slice(h.entries, func(i, j int) bool { return h.entries[i].Version < h.entries[j].Version })
+	return h, nil
+}
+
+func (h *configHistory) path(version int, ext string) string {
+	return filepath.Join(h.dir, fmt.Sprintf("%06d%s", version, ext))
+}
+
+// record stores a new version and prunes the oldest beyond the cap.
+func (h *configHistory) record(content []byte, correlationID, outcome, errMsg string) (historyEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	version := 1
+	if n := len(h.entries); n > 0 {
+		version = h.entries[n-1].Version + 1
+	}
+	e := historyEntry{
+		Version:       version,
+		Hash:          configHash(content),
+		Timestamp:     time.Now().UTC(),
+		CorrelationID: correlationID,
+		Outcome:       outcome,
+		Error:         errMsg,
+		Size:          len(content),
+	}
+
+	if err := writeFileAtomic(h.path(version, ".conf"), content, 0644); err != nil {
+		return e, err
+	}
+	meta, _ := json.Marshal(e)
+	// Metadata goes last: a version without it is ignored on load
+	if err := writeFileAtomic(h.path(version, ".json"), meta, 0644); err != nil {
+		os.Remove(h.path(version, ".conf"))
+		return e, err
+	}
+	h.entries = append(h.entries, e)
+
+	for h.max > 0 && len(h.entries) > h.max {
+		old := h.entries[0]
+		os.Remove(h.path(old.Version, ".json"))
+		os.Remove(h.path(old.Version, ".conf"))
+		h.entries = h.entries[1:]
+	}
+	return e, nil
+}
+
+// list returns all retained versions, oldest first.
+func (h *configHistory) list() []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]historyEntry(nil), h.entries...)
+}
+
+// findApplied returns the newest version with hash that was applied.
+func (h *configHistory) findApplied(hash string) (historyEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if e := h.entries[i]; e.Hash == hash && e.Outcome == HistoryApplied {
+			return e, true
+		}
+	}
+	return historyEntry{}, false
+}
+
+// get returns a version's metadata and content.
+func (h *configHistory) get(version int) (historyEntry, []byte, error) {
+	h.mu.Lock()
+	var entry historyEntry
+	found := false
+	for _, e := range h.entries {
+		if e.Version == version {
+			entry, found = e, true
+			break
+		}
+	}
+	h.mu.Unlock()
+
+	if !found {
+		return entry, nil, fmt.Errorf("config version %d not in history", version)
+	}
+	content, err := os.ReadFile(h.path(version, ".conf"))
+	if err != nil {
+		return entry, nil, fmt.Errorf("failed to read config version %d: %w", version, err)
+	}
+	return entry, content, nil
+}
+
+// versionLabel names a version in diff headers.
+func versionLabel(version int) string {
+	if version == 0 {
+		return "running"
+	}
+	return "v" + strconv.Itoa(version)
+}
+
+// recordHistory adds a pushed config to the history; failures only cost the
+// history entry, never the push itself.
+func (a *DeviceAgent) recordHistory(content []byte, correlationID, outcome string, applyErr error) {
+	if a.history == nil {
+		return
+	}
+	errMsg := ""
+	if applyErr != nil {
+		errMsg = applyErr.Error()
+	}
+	e, err := a.history.record(content, correlationID, outcome, errMsg)
+	if err != nil {
+		a.log.Warn("Failed to record config history", "error", err)
+		return
+	}
+	a.log.Info("Recorded config version", "version", e.Version, "outcome", outcome, attrConfigHash, e.Hash, attrCorrelationID, correlationID)
+}
+
+// handleListConfigHistory answers ListConfigHistory with the retained
+// versions, without their content.
+func (a *DeviceAgent) handleListConfigHistory(ctx context.Context, cmd *controlpb.Command) {
+	if a.history == nil {
+		a.commandFailed(ctx, cmd, errors.New("config history is disabled"))
+		return
+	}
+	versions := a.history.list()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"versions": versions,
+	})
+	typed := &controlpb.ConfigHistory{}
+	for _, e := range versions {
+		typed.Versions = append(typed.Versions, &controlpb.HistoryEntry{
+			Version:           int32(e.Version),
+			Hash:              e.Hash,
+			TimestampUnixNano: unixNano(e.Timestamp),
+			CorrelationId:     e.CorrelationID,
+			Outcome:           e.Outcome,
+			Error:             e.Error,
+			Size:              int32(e.Size),
+		})
+	}
+	a.sendResult(ctx, &controlpb.Event{
+		Type:          "ConfigHistory",
+		Payload:       string(payload),
+		CorrelationId: cmd.GetCorrelationId(),
+		Body:          &controlpb.Event_ConfigHistory{ConfigHistory: typed},
+	})
+}
+
+// handleDiffConfig answers DiffConfig with a unified diff between two
+// versions. Omitting "to" (or 0) diffs against the running config.
+func (a *DeviceAgent) handleDiffConfig(ctx context.Context, cmd *controlpb.Command, req *controlpb.DiffConfigRequest) {
+	if a.history == nil {
+		a.commandFailed(ctx, cmd, errors.New("config history is disabled"))
+		return
+	}
+
+	_, from, err := a.history.get(int(req.GetFrom()))
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+	var to []byte
+	if req.GetTo() == 0 {
+		to, err = a.driver.EffectiveConfig(ctx)
+	} else {
+		_, to, err = a.history.get(int(req.GetTo()))
+	}
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+
+	diff := unifiedDiff(versionLabel(int(req.GetFrom())), versionLabel(int(req.GetTo())), from, to)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"from": req.GetFrom(),
+		"to":   req.GetTo(),
+		"diff": diff,
+	})
+	a.sendResult(ctx, &controlpb.Event{
+		Type:          "ConfigDiff",
+		Payload:       string(payload),
+		CorrelationId: cmd.GetCorrelationId(),
+		Body:          &controlpb.Event_ConfigDiff{ConfigDiff: &controlpb.ConfigDiff{From: req.GetFrom(), To: req.GetTo(), Diff: diff}},
+	})
+}
+
+// handleRollbackConfig answers RollbackConfig by pushing the stored config
+// through the regular apply path, so it is validated, acked and recorded
+// like any other push.
+func (a *DeviceAgent) handleRollbackConfig(ctx context.Context, cmd *controlpb.Command, req *controlpb.RollbackConfigRequest) {
+	if a.history == nil {
+		a.commandFailed(ctx, cmd, errors.New("config history is disabled"))
+		return
+	}
+	entry, content, err := a.history.get(int(req.GetVersion()))
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+
+	a.log.Info("Rolling back to config version", "version", entry.Version, attrConfigHash, entry.Hash, attrCorrelationID, cmd.GetCorrelationId())
+	ack := a.handleConfigPush(ctx, &controlpb.ConfigPush{
+		DeviceId:   a.nodeID,
+		ConfigData: content,
+		ConfigHash: entry.Hash,
+		AgentType:  a.agentType,
+	}, cmd.GetCorrelationId())
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"version": entry.Version,
+		"hash":    entry.Hash,
+		"success": ack.Success,
+		"error":   ack.ErrorMessage,
+	})
+	a.sendResult(ctx, &controlpb.Event{
+		Type:          "ConfigRollback",
+		Payload:       string(payload),
+		CorrelationId: cmd.GetCorrelationId(),
+		Body: &controlpb.Event_ConfigRollback{ConfigRollback: &controlpb.ConfigRollback{
+			Version: int32(entry.Version),
+			Hash:    entry.Hash,
+			Success: ack.Success,
+			Error:   ack.ErrorMessage,
+		}},
+	})
+}
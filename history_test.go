@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// TestUnifiedDiff tests hunk headers and context handling
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{
+			name: "identical",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		{
+			name: "single change",
+			from: "a\nb\nc\n",
+			to:   "a\nB\nc\n",
+			want: "--- v1\n+++ v2\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "from empty",
+			from: "",
+			to:   "a\n",
+			want: "--- v1\n+++ v2\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "distant changes split into hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			to:   "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- v1\n+++ v2\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			from: "1\n2\n3\n4\n5\n",
+			to:   "one\n2\n3\n4\nfive\n",
+			want: "--- v1\n+++ v2\n@@ -1,5 +1,5 @@\n-1\n+one\n 2\n 3\n 4\n-5\n+five\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("v1", "v2", []byte(tt.from), []byte(tt.to))
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestConfigHistoryPersistsAndPrunes tests reopening the store and the version cap
+func TestConfigHistoryPersistsAndPrunes(t *testing.T) {
+	dir := t.TempDir()
+	h, err := openConfigHistory(dir, 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, c := range []string{"one", "two", "three"} {
+		if _, err := h.record([]byte(c), "corr-"+c, HistoryApplied, ""); err != nil {
+			t.Fatalf("record %s: %v", c, err)
+		}
+	}
+
+	h, err = openConfigHistory(dir, 2)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	entries := h.list()
+	if len(entries) != 2 || entries[0].Version != 2 || entries[1].Version != 3 {
+		t.Fatalf("got versions %+v, want 2 and 3", entries)
+	}
+	if _, _, err := h.get(1); err == nil {
+		t.Error("pruned version 1 is still readable")
+	}
+	e, content, err := h.get(3)
+	if err != nil || string(content) != "three" || e.CorrelationID != "corr-three" || e.Hash != configHash([]byte("three")) {
+		t.Errorf("get(3) = %+v, %q, %v", e, content, err)
+	}
+
+	// Numbering continues after a restart
+	if e, _ := h.record([]byte("four"), "", HistoryRejected, "bad"); e.Version != 4 {
+		t.Errorf("next version = %d, want 4", e.Version)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 4 {
+		t.Errorf("got %d files, want 2 versions x 2 files", len(files))
+	}
+}
+
+// TestConfigHistoryCommands tests listing, diffing and rolling back pushed configs
+func TestConfigHistoryCommands(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	ctx := context.Background()
+	v1 := []byte("[OUTPUT]\n    Name stdout\n")
+	v2 := []byte("[OUTPUT]\n    Name null\n")
+
+	a.handleConfigPush(ctx, &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: v1}, "push-1")
+	a.handleConfigPush(ctx, &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: []byte("[OUTPUT]\n    Name BROKEN\n")}, "push-2")
+	a.handleConfigPush(ctx, &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: v2}, "push-3")
+	drainAcks(a)
+
+	a.handleCommand(ctx, &controlpb.Command{Type: "ListConfigHistory", CorrelationId: "list"})
+	var list struct {
+		Versions []historyEntry `json:"versions"`
+	}
+	decodeEvent(t, nextEvent(t, a, "ConfigHistory"), &list)
+	var outcomes []string
+	for _, e := range list.Versions {
+		outcomes = append(outcomes, e.Outcome)
+	}
+	if got := strings.Join(outcomes, ","); got != "applied,rolled_back,applied" {
+		t.Errorf("outcomes = %s, want applied,rolled_back,applied", got)
+	}
+
+	a.handleCommand(ctx, &controlpb.Command{Type: "DiffConfig", Payload: `{"from":1}`, CorrelationId: "diff"})
+	var diff struct {
+		Diff string `json:"diff"`
+	}
+	decodeEvent(t, nextEvent(t, a, "ConfigDiff"), &diff)
+	if !strings.Contains(diff.Diff, "+++ running") || !strings.Contains(diff.Diff, "-    Name stdout\n+    Name null\n") {
+		t.Errorf("unexpected diff against running config:\n%s", diff.Diff)
+	}
+
+	a.handleCommand(ctx, &controlpb.Command{Type: "RollbackConfig", Payload: `{"version":1}`, CorrelationId: "rollback"})
+	if ack := nextAck(t, a); !ack.Success || ack.ConfigHash != configHash(v1) {
+		t.Errorf("rollback ack: success=%v hash=%s, want success with %s", ack.Success, ack.ConfigHash, configHash(v1))
+	}
+	var rb struct {
+		Version int  `json:"version"`
+		Success bool `json:"success"`
+	}
+	ev := nextEvent(t, a, "ConfigRollback")
+	decodeEvent(t, ev, &rb)
+	if rb.Version != 1 || !rb.Success || ev.CorrelationId != "rollback" {
+		t.Errorf("rollback event = %+v (correlation %s)", rb, ev.CorrelationId)
+	}
+	if got, _ := os.ReadFile(a.configPath); string(got) != string(v1) {
+		t.Errorf("live config = %q, want version 1", got)
+	}
+	if entries := a.history.list(); entries[len(entries)-1].CorrelationID != "rollback" {
+		t.Errorf("rollback not recorded as a new version: %+v", entries[len(entries)-1])
+	}
+
+	a.handleCommand(ctx, &controlpb.Command{Type: "RollbackConfig", Payload: `{"version":42}`, CorrelationId: "missing"})
+	if ev := nextEvent(t, a, "CommandFailed"); !strings.Contains(ev.Payload, "not in history") {
+		t.Errorf("missing version: got %s", ev.Payload)
+	}
+}
+
+// nextEvent pops queued events until one of the given type arrives
+func nextEvent(t *testing.T, a *DeviceAgent, eventType string) *controlpb.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-a.out.normal:
+			if ev := msg.env.GetEvent(); ev != nil && ev.Type == eventType {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("no %s event queued", eventType)
+			return nil
+		}
+	}
+}
+
+func decodeEvent(t *testing.T, ev *controlpb.Event, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(ev.Payload), v); err != nil {
+		t.Fatalf("%s payload %q: %v", ev.Type, ev.Payload, err)
+	}
+}
+
+func drainAcks(a *DeviceAgent) {
+	for {
+		select {
+		case <-a.out.high:
+		default:
+			return
+		}
+	}
+}
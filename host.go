@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// hostInfo describes the machine the agent runs on. Fields that cannot be
+// read on the current platform are left empty.
+type hostInfo struct {
+	Hostname      string     `json:"hostname"`
+	OS            string     `json:"os"`
+	Arch          string     `json:"arch"`
+	OSRelease     string     `json:"os_release,omitempty"`
+	Kernel        string     `json:"kernel,omitempty"`
+	UptimeSeconds float64    `json:"uptime_seconds,omitempty"`
+	CPUs          int        `json:"cpus"`
+	LoadAverage   []float64  `json:"load_average,omitempty"`
+	Memory        *memInfo   `json:"memory,omitempty"`
+	Disk          *diskUsage `json:"disk,omitempty"`
+}
+
+type memInfo struct {
+	TotalBytes     uint64 `json:"total_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+}
+
+type diskUsage struct {
+	Path       string `json:"path"`
+	TotalBytes uint64 `json:"total_bytes"`
+	FreeBytes  uint64 `json:"free_bytes"`
+}
+
+// readHostInfo gathers host details from /proc; diskPath selects the
+// filesystem whose usage is reported.
+func readHostInfo(diskPath string) hostInfo {
+	info := hostInfo{OS: runtime.GOOS, Arch: runtime.GOARCH, CPUs: runtime.NumCPU()}
+	info.Hostname, _ = os.Hostname()
+	info.OSRelease = readOSRelease()
+
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		info.Kernel = strings.TrimSpace(string(data))
+	}
+	if data, err := os.ReadFile("/proc/uptime"); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) > 0 {
+			info.UptimeSeconds, _ = strconv.ParseFloat(fields[0], 64)
+		}
+	}
+	if data, err := os.ReadFile("/proc/loadavg"); err == nil {
+		info.LoadAverage = parseLoadAvg(data)
+	}
+	if data, err := os.ReadFile("/proc/meminfo"); err == nil {
+		info.Memory = parseMeminfo(data)
+	}
+	if du, err := statDisk(diskPath); err == nil {
+		info.Disk = du
+	}
+	return info
+}
+
+// parseLoadAvg returns the 1, 5 and 15 minute load averages.
+func parseLoadAvg(data []byte) []float64 {
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil
+	}
+	loads := make([]float64, 3)
+	for i := range loads {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil
+		}
+		loads[i] = v
+	}
+	return loads
+}
+
+// parseMeminfo reads MemTotal and MemAvailable (reported in kB).
+func parseMeminfo(data []byte) *memInfo {
+	var mem memInfo
+	found := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			mem.TotalBytes = kb * 1024
+			found++
+		case "MemAvailable:":
+			mem.AvailableBytes = kb * 1024
+			found++
+		}
+	}
+	if found == 0 {
+		return nil
+	}
+	return &mem
+}
+
+// readBootID returns the kernel's id for the current boot, which changes on
+// every reboot ("" when unavailable).
+func readBootID() string {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
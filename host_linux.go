@@ -0,0 +1,18 @@
+//go:build linux
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// statDisk reports the usage of the filesystem holding path.
+func statDisk(path string) (*diskUsage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+	return &diskUsage{
+		Path:       path,
+		TotalBytes: st.Blocks * uint64(st.Bsize),
+		FreeBytes:  st.Bavail * uint64(st.Bsize),
+	}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// statDisk is only implemented on Linux.
+func statDisk(path string) (*diskUsage, error) {
+	return nil, errors.New("disk usage is not supported on this platform")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// startHTTPServer serves /healthz, /readyz and /metrics on addr (--listen).
+func (a *DeviceAgent) startHTTPServer(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	a.httpServer = &http.Server{Handler: a.httpHandler(), ReadHeaderTimeout: 10 * time.Second}
+	a.log.Info("Serving health and metrics", "addr", ln.Addr().String())
+	go func() {
+		if err := a.httpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			a.log.Error("HTTP server stopped", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (a *DeviceAgent) stopHTTPServer() {
+	if a.httpServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	a.httpServer.Shutdown(ctx)
+}
+
+func (a *DeviceAgent) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	// The process answers, so it is alive
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	// Ready once registered on the stream to the server
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !a.stats.connection().Connected {
+			http.Error(w, "not registered", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		a.writeMetrics(r.Context(), w)
+	})
+	return mux
+}
+
+// transportStates are exported as one device_agent_transport_state series each.
+var transportStates = []TransportState{StateIdle, StateConnecting, StateReady, StateTransientFailure, StateShutdown}
+
+// writeMetrics writes the agent's metrics in the Prometheus text format.
+func (a *DeviceAgent) writeMetrics(ctx context.Context, w io.Writer) {
+	info := readBuildInfo()
+	metricHeader(w, "device_agent_build_info", "gauge", "Agent build information.")
+	fmt.Fprintf(w, "device_agent_build_info{version=%q,go_version=%q,node_id=%q} 1\n", info.Version, info.GoVersion, a.nodeID)
+	metricHeader(w, "device_agent_uptime_seconds", "gauge", "Seconds since the agent started.")
+	fmt.Fprintf(w, "device_agent_uptime_seconds %s\n", formatFloat(time.Since(a.stats.startedAt).Seconds()))
+
+	a.stats.writeMetrics(w)
+
+	metricHeader(w, "device_agent_send_queue_messages", "gauge", "Messages queued in memory for sending.")
+	fmt.Fprintf(w, "device_agent_send_queue_messages %d\n", len(a.out.high)+len(a.out.normal))
+	if a.outbox != nil {
+		metricHeader(w, "device_agent_outbox_messages", "gauge", "Undelivered messages persisted in the outbox.")
+		fmt.Fprintf(w, "device_agent_outbox_messages %d\n", a.outbox.Len())
+		metricHeader(w, "device_agent_outbox_dropped_total", "counter", "Messages discarded because the outbox was full.")
+		fmt.Fprintf(w, "device_agent_outbox_dropped_total %d\n", a.outbox.Dropped())
+	}
+
+	if hasCapability(a.driver, CapabilityHealth) {
+		health := a.driver.Health(ctx)
+		metricHeader(w, "device_agent_collector_up", "gauge", "Whether the managed collector is reachable and healthy.")
+		fmt.Fprintf(w, "device_agent_collector_up{agent_type=%q} %d\n", a.agentType, boolMetric(health.Healthy))
+	}
+}
+
+// writeMetrics writes the connection and config push metrics.
+func (s *agentStats) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metricHeader(w, "device_agent_stream_connected", "gauge", "Whether the agent is registered on a stream to the server.")
+	fmt.Fprintf(w, "device_agent_stream_connected %d\n", boolMetric(s.conn.Connected))
+	metricHeader(w, "device_agent_transport_state", "gauge", "Current state of the transport to the server.")
+	for _, state := range transportStates {
+		fmt.Fprintf(w, "device_agent_transport_state{state=%q} %d\n", state, boolMetric(s.transport == state))
+	}
+	metricHeader(w, "device_agent_stream_connects_total", "counter", "Streams opened and registered, including reconnects.")
+	fmt.Fprintf(w, "device_agent_stream_connects_total %d\n", s.conn.Connects)
+	metricHeader(w, "device_agent_stream_disconnects_total", "counter", "Registered streams that were lost.")
+	fmt.Fprintf(w, "device_agent_stream_disconnects_total %d\n", s.conn.Disconnects)
+	metricHeader(w, "device_agent_stream_connect_failures_total", "counter", "Connect or register attempts that failed.")
+	fmt.Fprintf(w, "device_agent_stream_connect_failures_total %d\n", s.conn.FailedConnects)
+
+	metricHeader(w, "device_agent_config_pushes_total", "counter", "Config pushes by outcome.")
+	outcomes := []string{HistoryApplied, HistoryRejected, HistoryRolledBack, HistoryFailed}
+	for outcome := range s.pushes {
+		if !slices.Contains(outcomes, outcome) {
+			outcomes = append(outcomes, outcome)
+		}
+	}
+	sort.Strings(outcomes)
+	for _, outcome := range outcomes {
+		fmt.Fprintf(w, "device_agent_config_pushes_total{outcome=%q} %d\n", outcome, s.pushes[outcome])
+	}
+
+	metricHeader(w, "device_agent_config_apply_duration_seconds", "histogram", "Time from receiving a config push to acking it.")
+	for i, le := range applyBuckets {
+		fmt.Fprintf(w, "device_agent_config_apply_duration_seconds_bucket{le=%q} %d\n", formatFloat(le), s.applyCounts[i])
+	}
+	fmt.Fprintf(w, "device_agent_config_apply_duration_seconds_bucket{le=\"+Inf\"} %d\n", s.applyCount)
+	fmt.Fprintf(w, "device_agent_config_apply_duration_seconds_sum %s\n", formatFloat(s.applySum))
+	fmt.Fprintf(w, "device_agent_config_apply_duration_seconds_count %d\n", s.applyCount)
+}
+
+func metricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
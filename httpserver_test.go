@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// TestHealthEndpoints tests /healthz and /readyz across registration and a dropped stream
+func TestHealthEndpoints(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "fluent-bit.conf")
+	os.WriteFile(configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+	mt := newMemoryTransport()
+	a := NewDeviceAgent("unused", "device-1", "fluentbit", configPath, "", Options{
+		Transport:       mt,
+		Backoff:         BackoffPolicy{Initial: time.Hour, Max: time.Hour, Multiplier: 1},
+		MonitorInterval: time.Hour,
+	})
+	srv := httptest.NewServer(a.httpHandler())
+	defer srv.Close()
+
+	if code, _ := get(t, srv.URL+"/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz = %d before start", code)
+	}
+	if code, _ := get(t, srv.URL+"/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d before registering, want 503", code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop()
+	stream := mt.accept(t)
+	if code, _ := get(t, srv.URL+"/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz = %d once registered, want 200", code)
+	}
+
+	stream.fail(errors.New("connection reset"))
+	waitFor(t, func() bool {
+		code, _ := get(t, srv.URL+"/readyz")
+		return code == http.StatusServiceUnavailable
+	})
+}
+
+// TestMetricsEndpoint tests the Prometheus metrics after config pushes
+func TestMetricsEndpoint(t *testing.T) {
+	a, fb := newFluentBitAgent(t)
+	ctx := context.Background()
+	good := []byte("[OUTPUT]\n    Name stdout\n")
+	a.handleConfigPush(ctx, &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: good, ConfigHash: configHash(good)}, "c-1")
+	a.handleConfigPush(ctx, &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: good, ConfigHash: "wrong"}, "c-2")
+
+	srv := httptest.NewServer(a.httpHandler())
+	defer srv.Close()
+	_, body := get(t, srv.URL+"/metrics")
+	for _, want := range []string{
+		`device_agent_config_pushes_total{outcome="applied"} 1`,
+		`device_agent_config_pushes_total{outcome="rejected"} 1`,
+		`device_agent_config_apply_duration_seconds_count 2`,
+		`device_agent_config_apply_duration_seconds_bucket{le="+Inf"} 2`,
+		`device_agent_stream_connected 0`,
+		`device_agent_collector_up{agent_type="fluentbit"} 1`,
+		"device_agent_send_queue_messages ",
+		"device_agent_outbox_messages ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %q", want)
+		}
+	}
+
+	fb.Close()
+	if _, body := get(t, srv.URL+"/metrics"); !strings.Contains(body, `device_agent_collector_up{agent_type="fluentbit"} 0`) {
+		t.Error("collector still reported up after Fluent Bit went away")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// uuidPattern matches the canonical text form of a UUID.
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// labelFlag collects repeated --label key=value flags.
+type labelFlag map[string]string
+
+func (l labelFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l labelFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("label %q is not key=value", s)
+	}
+	l[key] = value
+	return nil
+}
+
+// loadInstanceUID returns the instance UID kept in path, generating and
+// saving a random one on first run. Unlike the node ID it tells apart two
+// installs that were given the same --node-id, e.g. a replaced device.
+func loadInstanceUID(path string) (string, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		if uid := strings.TrimSpace(string(data)); uuidPattern.MatchString(uid) {
+			return uid, nil
+		}
+	}
+	uid, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create state dir: %w", err)
+	}
+	if err := writeFileAtomic(path, []byte(uid+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to save instance UID: %w", err)
+	}
+	return uid, nil
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate instance UID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return formatUUID(b[:]), nil
+}
+
+func formatUUID(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// parseUUID returns the 16 bytes of a UUID in its canonical form.
+func parseUUID(uid string) ([]byte, error) {
+	if !uuidPattern.MatchString(uid) {
+		return nil, fmt.Errorf("invalid UUID %q", uid)
+	}
+	return hex.DecodeString(strings.ReplaceAll(uid, "-", ""))
+}
+
+// readOSRelease returns the distribution name from os-release(5), if any.
+func readOSRelease() string {
+	for _, path := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		if data, err := os.ReadFile(path); err == nil {
+			return parseOSRelease(data)
+		}
+	}
+	return ""
+}
+
+// parseOSRelease returns PRETTY_NAME, or NAME and VERSION_ID without one.
+func parseOSRelease(data []byte) string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		fields[key] = value
+	}
+	if pretty := fields["PRETTY_NAME"]; pretty != "" {
+		return pretty
+	}
+	return strings.TrimSpace(fields["NAME"] + " " + fields["VERSION_ID"])
+}
+
+// identity describes this agent install to the server.
+func (a *DeviceAgent) identity() *controlpb.EdgeIdentity {
+	hostname, _ := os.Hostname()
+	return &controlpb.EdgeIdentity{
+		NodeId:       a.nodeID,
+		Version:      readBuildInfo().Version,
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		AgentType:    a.agentType,
+		Capabilities: a.driver.Capabilities(),
+		InstanceUid:  a.instanceUID,
+		Hostname:     hostname,
+		OsRelease:    readOSRelease(),
+		Labels:       a.opts.Labels,
+		// What the server may ask for, instead of finding out from CommandUnknown
+		ProtocolVersion: controlProtocolVersion,
+		Commands:        a.commands(),
+		ConfigFormats:   a.configFormats(),
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestLoadInstanceUID tests that the instance UID is generated once and then reused
+func TestLoadInstanceUID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "instance-id")
+	uid, err := loadInstanceUID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uuidPattern.MatchString(uid) || uid[14] != '4' {
+		t.Errorf("uid = %q, want a version 4 UUID", uid)
+	}
+	again, err := loadInstanceUID(path)
+	if err != nil || again != uid {
+		t.Errorf("second load = %q, %v; want %q", again, err, uid)
+	}
+	if b, err := parseUUID(uid); err != nil || formatUUID(b) != uid {
+		t.Errorf("parseUUID(%q) = %x, %v", uid, b, err)
+	}
+
+	// A damaged file is replaced rather than reported as the UID
+	if err := os.WriteFile(path, []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fresh, err := loadInstanceUID(path); err != nil || fresh == uid || !uuidPattern.MatchString(fresh) {
+		t.Errorf("after damage = %q, %v", fresh, err)
+	}
+}
+
+// TestLabelFlag tests parsing repeated --label flags
+func TestLabelFlag(t *testing.T) {
+	labels := labelFlag{}
+	for _, s := range []string{"site=plant-7", "rack = b2", "empty="} {
+		if err := labels.Set(s); err != nil {
+			t.Errorf("Set(%q): %v", s, err)
+		}
+	}
+	if got := labels.String(); got != "empty=,rack= b2,site=plant-7" {
+		t.Errorf("labels = %q", got)
+	}
+	for _, s := range []string{"novalue", "=x"} {
+		if err := labels.Set(s); err == nil {
+			t.Errorf("Set(%q) accepted", s)
+		}
+	}
+}
+
+// TestParseOSRelease tests picking the distribution name from os-release
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"NAME=\"Debian GNU/Linux\"\nVERSION_ID=\"12\"\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n", "Debian GNU/Linux 12 (bookworm)"},
+		{"# comment\nNAME=Alpine\nVERSION_ID=3.19.1\n", "Alpine 3.19.1"},
+		{"NAME='Yocto'\n", "Yocto"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parseOSRelease([]byte(tt.data)); got != tt.want {
+			t.Errorf("parseOSRelease(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+// TestRegisterIdentity tests the identity sent on registration
+func TestRegisterIdentity(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{StateDir: dir, Labels: map[string]string{"site": "plant-7"}}
+	a := NewDeviceAgent("unused", "device-1", "fluentbit", filepath.Join(dir, "fluent-bit.conf"), "", opts)
+	reg := a.registerEnvelope().GetRegister()
+	if reg.Platform != runtime.GOOS+"/"+runtime.GOARCH || reg.Version == "" || reg.Version == "1.0.0" {
+		t.Errorf("platform %q, version %q", reg.Platform, reg.Version)
+	}
+	if reg.Labels["site"] != "plant-7" || !uuidPattern.MatchString(reg.InstanceUid) {
+		t.Errorf("labels %v, instance uid %q", reg.Labels, reg.InstanceUid)
+	}
+
+	// The UID survives a restart of the agent
+	b := NewDeviceAgent("unused", "device-1", "fluentbit", filepath.Join(dir, "fluent-bit.conf"), "", opts)
+	if uid := b.registerEnvelope().GetRegister().InstanceUid; uid != reg.InstanceUid {
+		t.Errorf("instance uid changed from %q to %q", reg.InstanceUid, uid)
+	}
+}
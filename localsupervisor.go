@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// localSupervisorDriver hands configs to a local supervisor service that
+// runs next to the collector (used for otelcol).
+type localSupervisorDriver struct {
+	nodeID string
+	url    string
+	client *http.Client
+	// The local supervisor has no restart endpoint, so restarts go to the
+	// collector process: the --exec child, or the process in the pid file
+	// which the local supervisor starts again once it exits
+	child          *processSupervisor
+	pidFile        string
+	restartTimeout time.Duration
+	pollInterval   time.Duration
+}
+
+func newLocalSupervisorDriver(cfg DriverConfig) Driver {
+	return &localSupervisorDriver{
+		nodeID:         cfg.NodeID,
+		url:            cfg.LocalSupervisorURL,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		child:          cfg.Child,
+		pidFile:        cfg.PIDFile,
+		restartTimeout: cfg.ReloadTimeout,
+		pollInterval:   cfg.ReloadPollInterval,
+	}
+}
+
+func (d *localSupervisorDriver) Capabilities() []string {
+	caps := []string{CapabilityApplyConfig, CapabilityEffectiveConfig, CapabilityHealth}
+	if d.child != nil || d.pidFile != "" {
+		caps = append(caps, CapabilityRestart)
+	}
+	return caps
+}
+
+func (d *localSupervisorDriver) Apply(ctx context.Context, config []byte) (ApplyResult, error) {
+	// Forward config to local supervisor via HTTP
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url+"/config", bytes.NewReader(config))
+	if err != nil {
+		return ApplyResult{}, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		nodeLogger(d.nodeID).Warn("Failed to forward config to local supervisor", "error", err)
+		return ApplyResult{}, fmt.Errorf("HTTP error: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		nodeLogger(d.nodeID).Warn("Local supervisor rejected config", "status", resp.StatusCode, "body", string(body))
+		return ApplyResult{}, fmt.Errorf("local supervisor rejected config: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	nodeLogger(d.nodeID).Info("Local supervisor accepted config")
+	return ApplyResult{}, nil
+}
+
+// EffectiveConfig asks the local supervisor for the config it is running.
+func (d *localSupervisorDriver) EffectiveConfig(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url+"/config", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config from local supervisor: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("local supervisor returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	config, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config response: %w", err)
+	}
+	return config, nil
+}
+
+// Health reports whether the local supervisor answers; it has no health
+// endpoint of its own.
+func (d *localSupervisorDriver) Health(ctx context.Context) DriverHealth {
+	if _, err := d.EffectiveConfig(ctx); err != nil {
+		return DriverHealth{Status: err.Error()}
+	}
+	return DriverHealth{Healthy: true, Status: "local supervisor reachable"}
+}
+
+func (d *localSupervisorDriver) Reload(ctx context.Context) error {
+	return errNotSupported
+}
+
+// Restart restarts the --exec child, or stops the collector in the pid file
+// and waits for the local supervisor to start a new one.
+func (d *localSupervisorDriver) Restart(ctx context.Context) error {
+	switch {
+	case d.child != nil:
+		return d.child.Restart(ctx)
+	case d.pidFile != "":
+	default:
+		return errNotSupported
+	}
+
+	proc, err := findProcess(d.pidFile)
+	if err != nil {
+		return err
+	}
+	nodeLogger(d.nodeID).Info("Stopping collector for the local supervisor to restart", "pid", proc.Pid)
+	if err := proc.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to signal collector process %d: %w", proc.Pid, err)
+	}
+
+	deadline := time.Now().Add(d.restartTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.pollInterval):
+		}
+		if next, err := findProcess(d.pidFile); err == nil && next.Pid != proc.Pid {
+			nodeLogger(d.nodeID).Info("Collector restarted", "pid", next.Pid)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("collector was not restarted within %s", d.restartTimeout)
+		}
+	}
+}
+
+func (d *localSupervisorDriver) Metrics(ctx context.Context) (map[string]float64, error) {
+	return nil, errNotSupported
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// Attribute keys shared by every log line that carries them.
+const (
+	attrNodeID        = "node_id"
+	attrCorrelationID = "correlation_id"
+	attrConfigHash    = "config_hash"
+)
+
+// agentLogLines is how many lines of the agent's own log FetchLogs can return.
+const agentLogLines = 2000
+
+var (
+	// logLevel is the level of the default logger; SetLogLevel changes it at runtime.
+	logLevel = new(slog.LevelVar)
+	// agentLogs keeps the most recent log lines for FetchLogs
+	agentLogs = newLineRing(agentLogLines)
+)
+
+// setupLogging makes a text or JSON slog handler at level the default
+// logger, which also receives the output of the log package. Lines written
+// to w are kept in agentLogs as well.
+func setupLogging(w io.Writer, format, level string) error {
+	if level != "" {
+		lvl, err := parseLogLevel(level)
+		if err != nil {
+			return err
+		}
+		logLevel.Set(lvl)
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+	w = io.MultiWriter(agentLogs, w)
+	var h slog.Handler
+	switch format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+func parseLogLevel(s string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return lvl, nil
+}
+
+// nodeLogger returns the default logger tagged with the device's node id.
+func nodeLogger(nodeID string) *slog.Logger {
+	return slog.With(attrNodeID, nodeID)
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// levelOverride tracks a temporary SetLogLevel so it can be reverted.
+type levelOverride struct {
+	mu sync.Mutex
+	// base is the level to return to; only meaningful while timer is set
+	base  slog.Level
+	timer *time.Timer
+	// gen tells a firing timer whether a later request superseded it
+	gen int
+}
+
+// handleSetLogLevel changes the log level, optionally reverting it after a TTL.
+func (a *DeviceAgent) handleSetLogLevel(ctx context.Context, cmd *controlpb.Command, req *controlpb.SetLogLevelRequest) {
+	level, err := parseLogLevel(req.GetLevel())
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+	ttl := time.Duration(req.GetTtlMs()) * time.Millisecond
+	if ttl < 0 {
+		a.commandFailed(ctx, cmd, fmt.Errorf("invalid SetLogLevel ttl %s", ttl))
+		return
+	}
+
+	o := &a.levelOverride
+	o.mu.Lock()
+	previous := logLevel.Level()
+	o.gen++
+	if o.timer != nil {
+		// A new request replaces the pending revert but keeps its target
+		o.timer.Stop()
+		o.timer = nil
+	} else {
+		o.base = previous
+	}
+	logLevel.Set(level)
+	report := map[string]interface{}{
+		"level":    level.String(),
+		"previous": previous.String(),
+	}
+	change := &controlpb.LogLevelChange{Level: level.String(), Previous: previous.String()}
+	if ttl > 0 {
+		revertAt := time.Now().Add(ttl)
+		report["revert_at"] = revertAt
+		report["revert_to"] = o.base.String()
+		change.RevertAtUnixNano = revertAt.UnixNano()
+		change.RevertTo = o.base.String()
+		gen, correlationID := o.gen, cmd.GetCorrelationId()
+		o.timer = time.AfterFunc(ttl, func() { a.revertLogLevel(ctx, gen, correlationID) })
+	}
+	o.mu.Unlock()
+
+	a.log.Info("Log level changed", "level", level, "previous", previous, "ttl", ttl, attrCorrelationID, cmd.GetCorrelationId())
+	payload, _ := json.Marshal(report)
+	a.sendResult(ctx, &controlpb.Event{
+		Type:          "LogLevelChanged",
+		Payload:       string(payload),
+		CorrelationId: cmd.GetCorrelationId(),
+		Body:          &controlpb.Event_LogLevel{LogLevel: change},
+	})
+}
+
+// revertLogLevel restores the level from before a SetLogLevel whose TTL ran out.
+func (a *DeviceAgent) revertLogLevel(ctx context.Context, gen int, correlationID string) {
+	o := &a.levelOverride
+	o.mu.Lock()
+	if o.gen != gen {
+		// Superseded by a later SetLogLevel
+		o.mu.Unlock()
+		return
+	}
+	o.timer = nil
+	level := o.base
+	logLevel.Set(level)
+	o.mu.Unlock()
+
+	a.log.Info("Log level reverted", "level", level, attrCorrelationID, correlationID)
+	payload, _ := json.Marshal(map[string]string{"level": level.String()})
+	a.sendResult(ctx, &controlpb.Event{
+		Type:          "LogLevelReverted",
+		Payload:       string(payload),
+		CorrelationId: correlationID,
+		Body:          &controlpb.Event_LogLevel{LogLevel: &controlpb.LogLevelChange{Level: level.String()}},
+	})
+}
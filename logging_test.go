@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// restoreLogging puts the default logger and level back after a test.
+func restoreLogging(t *testing.T) {
+	logger, level := slog.Default(), logLevel.Level()
+	t.Cleanup(func() {
+		slog.SetDefault(logger)
+		logLevel.Set(level)
+	})
+}
+
+// TestSetupLogging tests the output formats and levels
+func TestSetupLogging(t *testing.T) {
+	restoreLogging(t)
+
+	var buf bytes.Buffer
+	if err := setupLogging(&buf, "json", "warn"); err != nil {
+		t.Fatal(err)
+	}
+	nodeLogger("device-1").Info("hidden")
+	nodeLogger("device-1").Warn("Config rejected", attrConfigHash, "abc", attrCorrelationID, "c-1")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("output is not a single JSON line: %q", buf.String())
+	}
+	for key, want := range map[string]string{"msg": "Config rejected", "node_id": "device-1", "config_hash": "abc", "correlation_id": "c-1"} {
+		if line[key] != want {
+			t.Errorf("%s = %v, want %q", key, line[key], want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"xml", "info"}, {"text", "verbose"}} {
+		if err := setupLogging(&buf, bad[0], bad[1]); err == nil {
+			t.Errorf("setupLogging(%q, %q) succeeded", bad[0], bad[1])
+		}
+	}
+}
+
+// TestSetLogLevel tests changing the level at runtime and reverting it after a TTL
+func TestSetLogLevel(t *testing.T) {
+	restoreLogging(t)
+	logLevel.Set(slog.LevelInfo)
+	a := NewDeviceAgent("unused", "device-1", "file", filepath.Join(t.TempDir(), "agent.conf"), "", Options{})
+	ctx := context.Background()
+
+	a.handleCommand(ctx, &controlpb.Command{Type: "SetLogLevel", CorrelationId: "c-1", Payload: `{"level":"debug","ttl":"50ms"}`})
+	var changed map[string]interface{}
+	decodeEvent(t, nextEvent(t, a, "LogLevelChanged"), &changed)
+	if changed["level"] != "DEBUG" || changed["previous"] != "INFO" || changed["revert_to"] != "INFO" {
+		t.Errorf("LogLevelChanged = %v", changed)
+	}
+	if logLevel.Level() != slog.LevelDebug {
+		t.Errorf("level = %s, want DEBUG", logLevel.Level())
+	}
+
+	if ev := nextEvent(t, a, "LogLevelReverted"); ev.CorrelationId != "c-1" {
+		t.Errorf("revert correlation id %q", ev.CorrelationId)
+	}
+	if logLevel.Level() != slog.LevelInfo {
+		t.Errorf("level after TTL = %s, want INFO", logLevel.Level())
+	}
+
+	// Without a TTL the change sticks
+	a.handleCommand(ctx, &controlpb.Command{Type: "SetLogLevel", CorrelationId: "c-2", Payload: `{"level":"warn"}`})
+	nextEvent(t, a, "LogLevelChanged")
+	time.Sleep(100 * time.Millisecond)
+	if logLevel.Level() != slog.LevelWarn {
+		t.Errorf("level = %s, want WARN", logLevel.Level())
+	}
+
+	for _, payload := range []string{`{"level":"loud"}`, `{"level":"info","ttl":"soon"}`, `not json`} {
+		a.handleCommand(ctx, &controlpb.Command{Type: "SetLogLevel", CorrelationId: "c-3", Payload: payload})
+		var failed map[string]string
+		decodeEvent(t, nextEvent(t, a, "CommandFailed"), &failed)
+		if !strings.Contains(failed["error"], "SetLogLevel") && !strings.Contains(failed["error"], "log level") {
+			t.Errorf("payload %s: error %q", payload, failed["error"])
+		}
+	}
+}
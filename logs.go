@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+const (
+	// defaultLogLines and maxLogLines bound the lines FetchLogs returns per source
+	defaultLogLines = 200
+	maxLogLines     = 5000
+	// logChunkBytes is the rough payload size at which a Logs event is split
+	logChunkBytes = 64 << 10
+	// maxLogFileRead is how much of the end of a collector log file is read
+	maxLogFileRead = 4 << 20
+)
+
+// logQuery holds the validated FetchLogs parameters.
+type logQuery struct {
+	// Source is "agent", "collector" or empty for both
+	Source string
+	Lines  int
+
+	since time.Time
+	grep  *regexp.Regexp
+}
+
+// logChunk is the payload of a Logs event. Large results are split over
+// several events that share the command's correlation id.
+type logChunk struct {
+	Source string   `json:"source"`
+	Chunk  int      `json:"chunk"`
+	Chunks int      `json:"chunks"`
+	Lines  []string `json:"lines"`
+}
+
+func newLogQuery(req *controlpb.FetchLogsRequest, now time.Time) (logQuery, error) {
+	q := logQuery{Source: req.GetSource(), Lines: int(req.GetLines())}
+	switch q.Source {
+	case "", "agent", "collector":
+	default:
+		return q, fmt.Errorf("unknown log source %q (want agent or collector)", q.Source)
+	}
+	if q.Lines <= 0 {
+		q.Lines = defaultLogLines
+	}
+	if q.Lines > maxLogLines {
+		q.Lines = maxLogLines
+	}
+	if req.GetSinceUnixNano() > 0 {
+		q.since = time.Unix(0, req.GetSinceUnixNano())
+	}
+	if age := time.Duration(req.GetMaxAgeMs()) * time.Millisecond; age > 0 && now.Add(-age).After(q.since) {
+		q.since = now.Add(-age)
+	}
+	if req.GetGrep() != "" {
+		re, err := regexp.Compile(req.GetGrep())
+		if err != nil {
+			return q, fmt.Errorf("invalid grep pattern: %w", err)
+		}
+		q.grep = re
+	}
+	return q, nil
+}
+
+// filter returns the last q.Lines lines that match since and grep. Lines
+// without a known time are never dropped by since.
+func (q logQuery) filter(lines []ringLine) []string {
+	var out []string
+	for _, l := range lines {
+		if !q.since.IsZero() && !l.At.IsZero() && l.At.Before(q.since) {
+			continue
+		}
+		if q.grep != nil && !q.grep.MatchString(l.Text) {
+			continue
+		}
+		out = append(out, l.Text)
+	}
+	if len(out) > q.Lines {
+		out = out[len(out)-q.Lines:]
+	}
+	return out
+}
+
+// handleFetchLogs sends the requested agent and collector log lines as Logs events.
+func (a *DeviceAgent) handleFetchLogs(ctx context.Context, cmd *controlpb.Command, req *controlpb.FetchLogsRequest) {
+	q, err := newLogQuery(req, time.Now())
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+
+	if q.Source == "" || q.Source == "agent" {
+		a.sendLogs(ctx, cmd, "agent", q.filter(agentLogs.Lines()))
+	}
+	if q.Source == "" || q.Source == "collector" {
+		lines, err := a.collectorLogs()
+		switch {
+		case err != nil && q.Source == "collector":
+			a.commandFailed(ctx, cmd, err)
+		case err != nil:
+			a.log.Debug("Collector logs unavailable", "error", err)
+		default:
+			a.sendLogs(ctx, cmd, "collector", q.filter(lines))
+		}
+	}
+}
+
+// collectorLogs returns the collector's output captured from the child
+// process, or else the end of its log file.
+func (a *DeviceAgent) collectorLogs() ([]ringLine, error) {
+	if a.child != nil {
+		return a.child.output.Lines(), nil
+	}
+	if a.opts.CollectorLogFile != "" {
+		return readLogFile(a.opts.CollectorLogFile)
+	}
+	return nil, errors.New("no collector logs: run the collector with --exec or set --collector-log-file")
+}
+
+// sendLogs sends lines in events of about logChunkBytes each. They are not
+// kept in the outbox: chunks share a correlation id, so they would replace
+// each other there, and the server can simply ask again.
+func (a *DeviceAgent) sendLogs(ctx context.Context, cmd *controlpb.Command, source string, lines []string) {
+	var chunks [][]string
+	var cur []string
+	size := 0
+	for _, line := range lines {
+		if size+len(line) > logChunkBytes && len(cur) > 0 {
+			chunks = append(chunks, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, line)
+		size += len(line) + 3 // quotes and comma
+	}
+	chunks = append(chunks, cur)
+
+	for i, c := range chunks {
+		if c == nil {
+			c = []string{}
+		}
+		chunk := logChunk{Source: source, Chunk: i + 1, Chunks: len(chunks), Lines: c}
+		payload, _ := json.Marshal(chunk)
+		envelope := a.eventEnvelope(&controlpb.Event{
+			Type:          "Logs",
+			Payload:       string(payload),
+			CorrelationId: cmd.GetCorrelationId(),
+			Body: &controlpb.Event_Logs{Logs: &controlpb.LogChunk{
+				Source: chunk.Source,
+				Chunk:  int32(chunk.Chunk),
+				Chunks: int32(chunk.Chunks),
+				Lines:  chunk.Lines,
+			}},
+		})
+		if err := a.out.enqueue(envelope, PriorityNormal, false); err != nil {
+			a.log.Warn("Failed to queue logs", "source", source, "chunk", i+1, "chunks", len(chunks),
+				attrCorrelationID, cmd.GetCorrelationId(), "error", err)
+			return
+		}
+	}
+}
+
+// logTimeLayouts are the line prefixes of Fluent Bit ("[2024/01/02 15:04:05]")
+// and the OpenTelemetry Collector ("2024-01-02T15:04:05.000Z").
+var logTimeLayouts = []struct {
+	re     *regexp.Regexp
+	layout string
+}{
+	{regexp.MustCompile(`^\[(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2})\]`), "2006/01/02 15:04:05"},
+	{regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2}))`), time.RFC3339Nano},
+}
+
+// readLogFile returns the last lines of a collector log file. Each line's
+// time is taken from its timestamp prefix; continuation lines inherit the
+// time of the line before them.
+func readLogFile(path string) ([]ringLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open collector log: %w", err)
+	}
+	defer f.Close()
+	partial := false
+	if st, err := f.Stat(); err == nil && st.Size() > maxLogFileRead {
+		if _, err := f.Seek(st.Size()-maxLogFileRead, io.SeekStart); err == nil {
+			partial = true
+		}
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collector log: %w", err)
+	}
+
+	var lines []ringLine
+	var last time.Time
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), maxChildLine)
+	scanner.Split(scanCappedLines)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if partial {
+			// The read most likely started mid-line
+			partial = false
+			continue
+		}
+		if t, ok := parseLogTime(text); ok {
+			last = t
+		}
+		lines = append(lines, ringLine{At: last, Text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read collector log: %w", err)
+	}
+	return lines, nil
+}
+
+// scanCappedLines is bufio.ScanLines, except that a line longer than
+// maxChildLine is returned in pieces, as lineRing does, instead of
+// stopping the scan short of the newest lines.
+func scanCappedLines(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) >= maxChildLine && bytes.IndexByte(data[:maxChildLine], '\n') < 0 {
+		return maxChildLine, data[:maxChildLine], nil
+	}
+	return bufio.ScanLines(data, atEOF)
+}
+
+func parseLogTime(line string) (time.Time, bool) {
+	for _, l := range logTimeLayouts {
+		m := l.re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if t, err := time.ParseInLocation(l.layout, m[1], time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
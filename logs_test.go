@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// TestParseLogQuery tests the legacy FetchLogs payload, its defaults and validation
+func TestParseLogQuery(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		payload string
+		lines   int
+		since   time.Time
+		wantErr bool
+	}{
+		{payload: "", lines: defaultLogLines},
+		{payload: `{"lines":50,"since":"10m"}`, lines: 50, since: now.Add(-10 * time.Minute)},
+		{payload: `{"lines":100000,"since":"2024-03-01T11:00:00Z"}`, lines: maxLogLines, since: now.Add(-time.Hour)},
+		{payload: `{"source":"collector","grep":"error|warn"}`, lines: defaultLogLines},
+		{payload: `{"source":"kernel"}`, wantErr: true},
+		{payload: `{"since":"yesterday"}`, wantErr: true},
+		{payload: `{"grep":"("}`, wantErr: true},
+		{payload: `not json`, wantErr: true},
+	}
+	for _, tt := range tests {
+		cmd := &controlpb.Command{Type: "FetchLogs", Payload: tt.payload}
+		var q logQuery
+		err := upgradeCommand(cmd)
+		if err == nil {
+			q, err = newLogQuery(cmd.GetFetchLogs(), now)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.payload, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (q.Lines != tt.lines || !q.since.Equal(tt.since)) {
+			t.Errorf("%s: lines %d since %v, want %d %v", tt.payload, q.Lines, q.since, tt.lines, tt.since)
+		}
+	}
+}
+
+// logEvents collects the Logs events queued for source
+func logEvents(t *testing.T, a *DeviceAgent, source string) []logChunk {
+	t.Helper()
+	var chunks []logChunk
+	for {
+		var chunk logChunk
+		decodeEvent(t, nextEvent(t, a, "Logs"), &chunk)
+		if chunk.Source != source || chunk.Chunk != len(chunks)+1 {
+			t.Fatalf("got %s chunk %d/%d, want %s chunk %d", chunk.Source, chunk.Chunk, chunk.Chunks, source, len(chunks)+1)
+		}
+		chunks = append(chunks, chunk)
+		if chunk.Chunk == chunk.Chunks {
+			return chunks
+		}
+	}
+}
+
+// TestFetchAgentLogs tests grep and line count on the agent's own log
+func TestFetchAgentLogs(t *testing.T) {
+	restoreLogging(t)
+	if err := setupLogging(io.Discard, "text", "info"); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := newFluentBitAgent(t)
+	for i := 0; i < 5; i++ {
+		a.log.Info("FetchLogs marker", "n", i)
+	}
+	a.log.Info("Unrelated line")
+
+	a.handleCommand(context.Background(), &controlpb.Command{
+		Type: "FetchLogs", CorrelationId: "c-1", Payload: `{"source":"agent","lines":3,"grep":"FetchLogs marker"}`,
+	})
+	chunks := logEvents(t, a, "agent")
+	lines := chunks[0].Lines
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines: %q", len(lines), lines)
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, fmt.Sprintf("n=%d", i+2)) {
+			t.Errorf("line %d = %q", i, line)
+		}
+	}
+}
+
+// TestFetchCollectorLogFile tests the since filter on a collector log file
+func TestFetchCollectorLogFile(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	a.handleCommand(context.Background(), &controlpb.Command{Type: "FetchLogs", CorrelationId: "c-1", Payload: `{"source":"collector"}`})
+	ev := nextEvent(t, a, "CommandFailed")
+	if !strings.Contains(ev.Payload, "no collector logs") {
+		t.Errorf("payload = %s", ev.Payload)
+	}
+
+	old := time.Now().Add(-2 * time.Hour).Format("2006/01/02 15:04:05")
+	recent := time.Now().Add(-time.Minute).Format("2006/01/02 15:04:05")
+	a.opts.CollectorLogFile = filepath.Join(t.TempDir(), "fluent-bit.log")
+	log := fmt.Sprintf("[%s] [ info] old line\n[%s] [error] new line\n    continued\n", old, recent)
+	if err := os.WriteFile(a.opts.CollectorLogFile, []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a.handleCommand(context.Background(), &controlpb.Command{Type: "FetchLogs", CorrelationId: "c-2", Payload: `{"source":"collector","since":"1h"}`})
+	lines := logEvents(t, a, "collector")[0].Lines
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "new line") || lines[1] != "    continued" {
+		t.Errorf("lines = %q", lines)
+	}
+}
+
+// TestReadLogFileLongLine tests that an over-long line is split instead of hiding the lines after it
+func TestReadLogFileLongLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "otelcol.log")
+	long := strings.Repeat("x", maxChildLine+10)
+	if err := os.WriteFile(path, []byte("first\n"+long+"\nlast\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := readLogFile(path)
+	if err != nil {
+		t.Fatalf("readLogFile: %v", err)
+	}
+	var texts []string
+	for _, l := range lines {
+		texts = append(texts, l.Text)
+	}
+	want := []string{"first", long[:maxChildLine], long[maxChildLine:], "last"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("got %d lines, want %d ending with %q", len(texts), len(want), "last")
+	}
+}
+
+// TestFetchLogsChunks tests that a large result is split across events
+func TestFetchLogsChunks(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	a.opts.CollectorLogFile = filepath.Join(t.TempDir(), "collector.log")
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, "%04d %s\n", i, strings.Repeat("x", 195))
+	}
+	if err := os.WriteFile(a.opts.CollectorLogFile, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a.handleCommand(context.Background(), &controlpb.Command{Type: "FetchLogs", CorrelationId: "c-1", Payload: `{"source":"collector","lines":1000}`})
+	chunks := logEvents(t, a, "collector")
+	if len(chunks) < 3 {
+		t.Fatalf("got %d chunks for 200KB", len(chunks))
+	}
+	var lines []string
+	for _, c := range chunks {
+		lines = append(lines, c.Lines...)
+	}
+	if len(lines) != 1000 || !strings.HasPrefix(lines[0], "0000 ") || !strings.HasPrefix(lines[999], "0999 ") {
+		t.Errorf("got %d lines, first %.10q last %.10q", len(lines), lines[0], lines[len(lines)-1])
+	}
+}
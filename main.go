@@ -1,220 +1,495 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-
 	"local.dev/opamp-device-agent/api/controlpb"
 )
 
 func main() {
+	defBackoff := DefaultBackoffPolicy()
 	var (
 		supervisorAddr = flag.String("supervisor", "localhost:50051", "Supervisor address")
 		nodeID         = flag.String("node-id", "", "Node ID (e.g., device-1, device-2)")
-		agentType      = flag.String("agent-type", "", "Agent type: fluentbit, otelcol (empty = use local-supervisor)")
+		agentType      = flag.String("agent-type", "", "Driver for the managed collector: fluentbit, otelcol or local-supervisor (otelcol behind a local supervisor), otelcol-direct (otelcol managed by the agent) or file (empty = local-supervisor)")
 		configPath     = flag.String("config-path", "/config/fluent-bit.conf", "Config file path for direct agent management")
 		reloadEndpoint = flag.String("reload-endpoint", "http://localhost:2020/api/v2/reload", "HTTP endpoint to trigger config reload")
-		_              = flag.String("opamp-server", "", "Deprecated - ignored")
+		reloadSignal   = flag.String("reload-signal", "", "File driver: signal (e.g. HUP) sent to the process in --pid-file to reload instead of calling --reload-endpoint")
+		pidFile        = flag.String("pid-file", "", "Pid file of the collector process: signalled on reload by the file and otelcol-direct drivers, stopped on restart by the local-supervisor driver")
+		otelcolBin     = flag.String("otelcol-bin", "otelcol", "otelcol-direct driver: collector binary used to validate configs")
+		execCmd        = flag.String("exec", "", `Run the collector as a child and restart it when it exits, e.g. "/usr/bin/fluent-bit -c /config/fluent-bit.conf"`)
+		collectorLog   = flag.String("collector-log-file", "", "Collector log file for FetchLogs when the collector is not run with --exec")
+		healthEndpoint = flag.String("health-endpoint", "http://localhost:13133/", "otelcol-direct driver: URL of the collector's health_check extension")
+		allowReboot    = flag.Bool("allow-reboot", os.Getenv("ALLOW_REBOOT") == "true", "Let the Reboot command reboot the host (env ALLOW_REBOOT)")
+		rebootCmd      = flag.String("reboot-command", "reboot", "Command run by the Reboot command")
+		tlsCA          = flag.String("tls-ca", os.Getenv("TLS_CA_FILE"), "CA bundle used to verify the supervisor (env TLS_CA_FILE, empty = system roots)")
+		tlsCert        = flag.String("tls-cert", os.Getenv("TLS_CERT_FILE"), "Client certificate presented to the supervisor (env TLS_CERT_FILE)")
+		tlsKey         = flag.String("tls-key", os.Getenv("TLS_KEY_FILE"), "Client private key (env TLS_KEY_FILE)")
+		tlsServerName  = flag.String("tls-server-name", os.Getenv("TLS_SERVER_NAME"), "Override the server name used to verify the supervisor certificate (env TLS_SERVER_NAME)")
+		tlsReload      = flag.Duration("tls-reload-interval", time.Minute, "How often to check the client certificate on disk for rotation")
+		insecureConn   = flag.Bool("insecure", os.Getenv("TLS_INSECURE") == "true", "Disable TLS and connect to the supervisor in plaintext (env TLS_INSECURE)")
+		backoffInitial = flag.Duration("backoff-initial", defBackoff.Initial, "Initial reconnect delay")
+		backoffMax     = flag.Duration("backoff-max", defBackoff.Max, "Maximum reconnect delay")
+		backoffMult    = flag.Float64("backoff-multiplier", defBackoff.Multiplier, "Reconnect delay multiplier per attempt")
+		backoffJitter  = flag.Float64("backoff-jitter", defBackoff.Jitter, "Fraction of the reconnect delay to randomize (0-1)")
+		redialAfter    = flag.Int("redial-after", defBackoff.RedialAfter, "Redial the gRPC connection after this many consecutive stream failures")
+		keepaliveTime  = flag.Duration("grpc-keepalive-time", 0, "Ping the supervisor after the gRPC connection has been idle this long (0 = off; must not undercut the server's enforcement policy)")
+		keepaliveWait  = flag.Duration("grpc-keepalive-timeout", 20*time.Second, "Close the gRPC connection when a keepalive ping is not acked within this time")
+		keepaliveIdle  = flag.Bool("grpc-keepalive-without-stream", false, "Send keepalive pings even while no stream is open")
+		heartbeatWait  = flag.Duration("heartbeat-timeout", 0, "Reconnect when nothing is heard from the supervisor for this long (0 = three heartbeat intervals)")
+		maxRetries     = flag.Int("max-retries", 0, "Exit non-zero after this many failed reconnect attempts (0 = retry forever)")
+		sendQueueSize  = flag.Int("send-queue-size", 256, "Capacity of each outbound message queue")
+		monitorEvery   = flag.Duration("monitor-interval", 30*time.Second, "Heartbeat interval, also of the config hash report and fallback drift check (the supervisor may negotiate another)")
+		metricsEvery   = flag.Duration("metrics-interval", time.Minute, "How often collector metrics are reported (0 = only on FetchMetrics)")
+		driftFix       = flag.Bool("drift-remediate", false, "Restore the managed config when --config-path is edited out of band")
+		stateDir       = flag.String("state-dir", "", "Directory for agent state such as the outbox (default: .agent-state next to --config-path)")
+		outboxMax      = flag.Int("outbox-max", 1000, "Maximum undelivered messages kept on disk before the oldest are dropped")
+		historyMax     = flag.Int("history-max", 50, "Number of pushed config versions kept in the local history")
+		reloadTimeout  = flag.Duration("reload-timeout", 10*time.Second, "How long to wait for Fluent Bit to come back after a config reload")
+		validate       = flag.String("validate", "", `Validate configs before applying: "builtin" (classic Fluent Bit parser) or a command with a {config} placeholder, e.g. "fluent-bit --dry-run -c {config}"`)
+		validateTime   = flag.Duration("validate-timeout", 30*time.Second, "Timeout for the validator command")
+		opampServer    = flag.String("opamp-server", os.Getenv("OPAMP_SERVER"), "Speak OpAMP to this server instead of the Control service: ws(s):// for WebSocket, http(s):// for polling (env OPAMP_SERVER)")
+		opampPoll      = flag.Duration("opamp-poll-interval", 30*time.Second, "How often to poll an http(s):// OpAMP server")
+		logFormat      = flag.String("log-format", os.Getenv("LOG_FORMAT"), "Log output format: text or json (env LOG_FORMAT, empty = text)")
+		logLevelFlag   = flag.String("log-level", os.Getenv("LOG_LEVEL"), "Log level: debug, info, warn or error (env LOG_LEVEL, empty = info)")
+		listenAddr     = flag.String("listen", os.Getenv("LISTEN_ADDR"), "Serve /healthz, /readyz and /metrics on this address, e.g. :9100 (env LISTEN_ADDR, empty = off)")
 		_              = flag.String("otel-config", "", "Deprecated - ignored")
 	)
+	labels := labelFlag{}
+	flag.Var(labels, "label", "Attribute reported with the device identity, as key=value (repeatable)")
 	flag.Parse()
 
+	if err := setupLogging(os.Stderr, *logFormat, *logLevelFlag); err != nil {
+		fatal("Invalid logging flags", "error", err)
+	}
 	if *nodeID == "" {
-		log.Fatal("--node-id is required")
+		fatal("--node-id is required")
 	}
 
-	agent := NewDeviceAgent(*supervisorAddr, *nodeID, *agentType, *configPath, *reloadEndpoint)
+	if _, ok := drivers[*agentType]; !ok {
+		fatal("Unknown --agent-type", "agent_type", *agentType, "known", strings.Join(driverNames(), ", "))
+	}
+	reloadSig, err := parseSignal(*reloadSignal)
+	if err != nil {
+		fatal("Invalid --reload-signal", "error", err)
+	}
+
+	validator, err := newConfigValidator(*validate, *configPath, *validateTime)
+	if err != nil {
+		fatal("Invalid --validate", "error", err)
+	}
+
+	agent := NewDeviceAgent(*supervisorAddr, *nodeID, *agentType, *configPath, *reloadEndpoint, Options{
+		TLS: TLSConfig{
+			CAFile:         *tlsCA,
+			CertFile:       *tlsCert,
+			KeyFile:        *tlsKey,
+			ServerName:     *tlsServerName,
+			Insecure:       *insecureConn,
+			ReloadInterval: *tlsReload,
+		},
+		Keepalive: KeepaliveConfig{
+			Time:                *keepaliveTime,
+			Timeout:             *keepaliveWait,
+			PermitWithoutStream: *keepaliveIdle,
+		},
+		HeartbeatTimeout: *heartbeatWait,
+		Backoff: BackoffPolicy{
+			Initial:     *backoffInitial,
+			Max:         *backoffMax,
+			Multiplier:  *backoffMult,
+			Jitter:      *backoffJitter,
+			RedialAfter: *redialAfter,
+			MaxRetries:  *maxRetries,
+		},
+		SendQueueSize:    *sendQueueSize,
+		MonitorInterval:  *monitorEvery,
+		MetricsInterval:  *metricsEvery,
+		Listen:           *listenAddr,
+		DriftRemediate:   *driftFix,
+		StateDir:         *stateDir,
+		OutboxMax:        *outboxMax,
+		HistoryMax:       *historyMax,
+		ReloadTimeout:    *reloadTimeout,
+		ReloadSignal:     reloadSig,
+		PIDFile:          *pidFile,
+		OtelcolBinary:    *otelcolBin,
+		HealthEndpoint:   *healthEndpoint,
+		ValidateTimeout:  *validateTime,
+		Labels:           labels,
+		Exec:             strings.Fields(*execCmd),
+		CollectorLogFile: *collectorLog,
+		AllowReboot:      *allowReboot,
+		RebootCommand:    strings.Fields(*rebootCmd),
+		Validator:        validator,
+		OpAMP: OpAMPConfig{
+			ServerURL:    *opampServer,
+			PollInterval: *opampPoll,
+		},
+	})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	if err := agent.Start(ctx); err != nil {
-		log.Fatalf("Failed to start agent: %v", err)
+		fatal("Failed to start agent", "error", err)
 	}
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
-	<-sigs
 
-	log.Println("Shutting down device agent...")
-	agent.Stop()
+	select {
+	case <-sigs:
+		slog.Info("Shutting down device agent")
+		agent.Stop()
+	case err := <-agent.Err():
+		// Exit non-zero so Kubernetes restarts the pod
+		agent.Stop()
+		if errors.Is(err, errRestartRequested) {
+			slog.Info("Device agent exiting to be restarted", "reason", err, "exit_code", exitRestartRequested)
+			os.Exit(exitRestartRequested)
+		}
+		fatal("Device agent gave up", "error", err)
+	}
 }
 
 type DeviceAgent struct {
-	supervisorAddr     string
-	nodeID             string
-	agentType          string
-	configPath         string
-	reloadEndpoint     string
-	localSupervisorURL string
-
-	conn   *grpc.ClientConn
-	client controlpb.ControlServiceClient
-	stream controlpb.ControlService_ControlClient
+	supervisorAddr string
+	nodeID         string
+	// instanceUID identifies this install; it is kept in the state dir
+	instanceUID string
+	agentType   string
+	configPath  string
+	opts        Options
+	errCh       chan error
+	// log carries the node id on every line
+	log *slog.Logger
+
+	// driver is the only way the agent touches the collector
+	driver Driver
+	// child runs the collector when --exec is set
+	child     *processSupervisor
+	childDone <-chan struct{}
+	transport Transport
+	// out owns the current stream; all writes go through it
+	out        *sender
+	outbox     *outbox
+	history    *configHistory
+	cancel     context.CancelFunc
+	senderDone chan struct{}
+	stats      *agentStats
+	// negotiated is what the server accepted in its RegisterAck
+	negotiated negotiated
+	// heartbeatCh hands a negotiated interval to the runtime monitor loop
+	heartbeatCh chan time.Duration
+	// actions rate-limits restarts and reboots
+	actions *actionLog
+	// pendingOnce reports an action left by the previous run once the first
+	// stream is registered
+	pendingOnce sync.Once
+	// httpServer serves health and metrics when Listen is set
+	httpServer *http.Server
+	// levelOverride reverts a SetLogLevel with a TTL
+	levelOverride levelOverride
+
+	// configMu is held while the agent writes the config file, so drift
+	// checks never see a half-finished apply
+	configMu sync.Mutex
+	// managedConfig is the config the agent last applied (nil = unknown)
+	managedConfig []byte
+	// driftHash is the drifted content already reported
+	driftHash string
+
+	reportedMu sync.Mutex
+	// reportedHash is the effective config hash last sent with its content
+	reportedHash string
+}
+
+// Options carries the optional agent settings that are not part of the
+// device identity.
+type Options struct {
+	TLS             TLSConfig
+	Keepalive       KeepaliveConfig
+	Backoff         BackoffPolicy
+	SendQueueSize   int
+	MonitorInterval time.Duration
+	// HeartbeatTimeout is how long the server may stay silent before the
+	// stream is dropped (0 = three heartbeat intervals)
+	HeartbeatTimeout time.Duration
+	// MetricsInterval is how often collector metrics are reported (0 = off)
+	MetricsInterval time.Duration
+	// Listen is the address of the health and metrics server (empty = off)
+	Listen        string
+	StateDir      string
+	OutboxMax     int
+	HistoryMax    int
+	ReloadTimeout time.Duration
+	// ReloadPollInterval is how often the hot reload counter is checked
+	ReloadPollInterval time.Duration
+	// ReloadSignal and PIDFile make the file driver reload by signal
+	ReloadSignal syscall.Signal
+	PIDFile      string
+	// OtelcolBinary, HealthEndpoint and ValidateTimeout configure the otelcol-direct driver
+	OtelcolBinary   string
+	HealthEndpoint  string
+	ValidateTimeout time.Duration
+	// Labels are extra key=value attributes of the device identity
+	Labels map[string]string
+	// Exec is the collector command line when the agent runs the collector itself
+	Exec []string
+	// CollectorLogFile is what FetchLogs reads when there is no child to capture
+	CollectorLogFile string
+	// AllowReboot lets the Reboot command run RebootCommand
+	AllowReboot   bool
+	RebootCommand []string
+	// FlushTimeout bounds how long RestartAgent and Reboot wait for queued messages to go out
+	FlushTimeout time.Duration
+	// Validator is optional; nil applies configs unchecked
+	Validator ConfigValidator
+	// DriftRemediate restores the managed config after out-of-band edits
+	DriftRemediate bool
+	// OpAMP replaces the Control service with an OpAMP server when set
+	OpAMP OpAMPConfig
+	// Transport overrides the transport chosen from the options above
+	Transport Transport
+	// Driver overrides the driver registered for the agent type
+	Driver Driver
 }
 
-func NewDeviceAgent(supervisorAddr, nodeID, agentType, configPath, reloadEndpoint string) *DeviceAgent {
+func NewDeviceAgent(supervisorAddr, nodeID, agentType, configPath, reloadEndpoint string, opts Options) *DeviceAgent {
 	// Local supervisor runs in same namespace, accessible via K8s service
 	// Allow override via env var LOCAL_SUPERVISOR_URL
 	localSupervisorURL := os.Getenv("LOCAL_SUPERVISOR_URL")
 	if localSupervisorURL == "" {
 		localSupervisorURL = fmt.Sprintf("http://local-supervisor-%s-svc:8080", nodeID)
 	}
-	return &DeviceAgent{
-		supervisorAddr:     supervisorAddr,
-		nodeID:             nodeID,
-		agentType:          agentType,
-		configPath:         configPath,
-		reloadEndpoint:     reloadEndpoint,
-		localSupervisorURL: localSupervisorURL,
+	if opts.Backoff.Initial <= 0 {
+		opts.Backoff = DefaultBackoffPolicy()
+	}
+	if opts.SendQueueSize <= 0 {
+		opts.SendQueueSize = 256
+	}
+	if opts.MonitorInterval <= 0 {
+		opts.MonitorInterval = 30 * time.Second
+	}
+	if opts.ReloadTimeout <= 0 {
+		opts.ReloadTimeout = 10 * time.Second
+	}
+	if opts.ValidateTimeout <= 0 {
+		opts.ValidateTimeout = 30 * time.Second
+	}
+	if opts.ReloadPollInterval <= 0 {
+		opts.ReloadPollInterval = 250 * time.Millisecond
+	}
+	if opts.HistoryMax <= 0 {
+		opts.HistoryMax = 50
+	}
+	if opts.OpAMP.PollInterval <= 0 {
+		opts.OpAMP.PollInterval = 30 * time.Second
+	}
+	if opts.StateDir == "" {
+		opts.StateDir = filepath.Join(filepath.Dir(configPath), ".agent-state")
+	}
+	if len(opts.RebootCommand) == 0 {
+		opts.RebootCommand = []string{"reboot"}
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 10 * time.Second
 	}
-}
-
-func (a *DeviceAgent) Start(ctx context.Context) error {
-	log.Printf("[Device %s] Connecting to supervisor at %s", a.nodeID, a.supervisorAddr)
 
-	conn, err := grpc.NewClient(
-		a.supervisorAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	// Without a usable outbox, undelivered messages are only kept in memory
+	ob, err := openOutbox(nodeID, filepath.Join(opts.StateDir, "outbox"), opts.OutboxMax)
 	if err != nil {
-		return err
+		nodeLogger(nodeID).Warn("Outbox disabled", "error", err)
+		ob = nil
+	} else if n := ob.Len(); n > 0 {
+		nodeLogger(nodeID).Info("Loaded undelivered messages from outbox", "count", n)
 	}
-	a.conn = conn
-	a.client = controlpb.NewControlServiceClient(conn)
-
-	stream, err := a.client.Control(ctx)
+	history, err := openConfigHistory(filepath.Join(opts.StateDir, "history"), opts.HistoryMax)
 	if err != nil {
-		return err
+		nodeLogger(nodeID).Warn("Config history disabled", "error", err)
+		history = nil
+	}
+	instanceUID, err := loadInstanceUID(filepath.Join(opts.StateDir, "instance-id"))
+	if err != nil {
+		// Fall back to the UID derived from the node ID, which is at least stable
+		instanceUID = formatUUID(opampInstanceUID(nodeID))
+		nodeLogger(nodeID).Warn("Instance UID not persisted", "instance_uid", instanceUID, "error", err)
+	}
+	a := &DeviceAgent{
+		supervisorAddr: supervisorAddr,
+		nodeID:         nodeID,
+		instanceUID:    instanceUID,
+		agentType:      agentType,
+		configPath:     configPath,
+		opts:           opts,
+		errCh:          make(chan error, 1),
+		log:            nodeLogger(nodeID),
+		outbox:         ob,
+		history:        history,
+		out:            newSender(nodeID, opts.SendQueueSize, ob),
+		senderDone:     make(chan struct{}),
+		stats:          newAgentStats(),
+		heartbeatCh:    make(chan time.Duration, 1),
+		actions:        openActionLog(filepath.Join(opts.StateDir, "actions.json")),
+	}
+	a.negotiated.heartbeat = opts.MonitorInterval
+	a.out.typedEvents = func() bool { return a.negotiated.protocol() >= typedProtocolVersion }
+	if len(opts.Exec) > 0 {
+		a.child = newProcessSupervisor(nodeID, opts.Exec)
+	}
+	a.driver = opts.Driver
+	if a.driver == nil {
+		a.driver, err = newDriver(agentType, DriverConfig{
+			NodeID:             nodeID,
+			ConfigPath:         configPath,
+			LastGoodPath:       a.lastGoodConfigPath(),
+			ReloadEndpoint:     reloadEndpoint,
+			ReloadTimeout:      opts.ReloadTimeout,
+			ReloadPollInterval: opts.ReloadPollInterval,
+			LocalSupervisorURL: localSupervisorURL,
+			ReloadSignal:       opts.ReloadSignal,
+			PIDFile:            opts.PIDFile,
+			OtelcolBinary:      opts.OtelcolBinary,
+			ValidateTimeout:    opts.ValidateTimeout,
+			HealthEndpoint:     opts.HealthEndpoint,
+			Child:              a.child,
+		})
+		if err != nil {
+			nodeLogger(nodeID).Warn("Using the local supervisor", "error", err)
+			a.driver = newLocalSupervisorDriver(DriverConfig{
+				NodeID:             nodeID,
+				ReloadTimeout:      opts.ReloadTimeout,
+				ReloadPollInterval: opts.ReloadPollInterval,
+				LocalSupervisorURL: localSupervisorURL,
+				PIDFile:            opts.PIDFile,
+				Child:              a.child,
+			})
+		}
 	}
-	a.stream = stream
+	switch {
+	case opts.Transport != nil:
+		a.transport = opts.Transport
+	case opts.OpAMP.ServerURL != "":
+		a.transport = newOpAMPTransport(nodeID, configPath, instanceUID, opts.OpAMP, opts.TLS)
+	default:
+		a.transport = newGRPCTransport(supervisorAddr, nodeID, opts.TLS, opts.Keepalive, opts.Backoff.RedialAfter)
+	}
+	return a
+}
 
-	if err := a.register(); err != nil {
-		return err
+// Err reports fatal agent errors, such as exhausting the reconnect retries.
+func (a *DeviceAgent) Err() <-chan error {
+	return a.errCh
+}
+
+func (a *DeviceAgent) Start(ctx context.Context) error {
+	if a.opts.OpAMP.ServerURL != "" {
+		a.log.Info("Connecting to OpAMP server", "url", a.opts.OpAMP.ServerURL)
+	} else {
+		a.log.Info("Connecting to supervisor", "addr", a.supervisorAddr)
 	}
 
-	log.Printf("[Device %s] Connected and registered to supervisor", a.nodeID)
+	if a.opts.Listen != "" {
+		if err := a.startHTTPServer(a.opts.Listen); err != nil {
+			return err
+		}
+	}
 
-	// Send initial effective config
-	if err := a.sendInitialEffectiveConfig(); err != nil {
-		log.Printf("[Device %s] Failed to send initial effective config: %v", a.nodeID, err)
-		// Continue anyway - not a fatal error
+	ctx, a.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(a.senderDone)
+		a.out.run(ctx)
+	}()
+	go a.watchTransportState(ctx)
+
+	// The collector runs whether or not the supervisor can be reached
+	if a.child != nil {
+		go a.child.run(ctx)
+		a.childDone = a.child.Done()
+	}
+
+	if err := a.connect(ctx); err != nil {
+		a.log.Warn("Failed to connect to supervisor, retrying", "error", err)
+		go a.reconnect(ctx)
+	} else {
+		a.log.Info("Connected and registered to supervisor")
 	}
 
-	go a.receiveLoop(ctx)
 	go a.runtimeMonitorLoop(ctx)
+	if a.opts.MetricsInterval > 0 && hasCapability(a.driver, CapabilityMetrics) {
+		go a.metricsLoop(ctx)
+	}
+	if hasCapability(a.driver, CapabilityDriftDetection) {
+		go a.driftWatchLoop(ctx)
+	}
 
 	return nil
 }
 
+// watchTransportState logs stream state changes reported by the transport.
+func (a *DeviceAgent) watchTransportState(ctx context.Context) {
+	states := a.transport.States()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case state := <-states:
+			a.log.Info("Stream state changed", "state", state.String())
+			a.stats.transportChanged(state)
+		}
+	}
+}
+
 func (a *DeviceAgent) runtimeMonitorLoop(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(a.opts.MonitorInterval)
 	defer ticker.Stop()
 
-	log.Printf("[Device %s] Starting runtime monitor loop (30s interval)", a.nodeID)
+	a.log.Debug("Starting runtime monitor loop", "interval", a.opts.MonitorInterval)
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[Device %s] Runtime monitor loop stopped", a.nodeID)
+			a.log.Debug("Runtime monitor loop stopped")
 			return
+		case interval := <-a.heartbeatCh:
+			a.log.Debug("Runtime monitor interval changed", "interval", interval)
+			ticker.Reset(interval)
 		case <-ticker.C:
-			// Periodically verify runtime state and send updated effective config if Fluent Bit state differs
-			effectiveConfig, err := a.getFluentBitRuntimeConfig()
-			if err != nil {
-				log.Printf("[Device %s] Runtime monitor: failed to get config: %v", a.nodeID, err)
-				continue
-			}
-
-			// Send updated effective config
-			ack := &controlpb.ConfigAck{
-				DeviceId:        a.nodeID,
-				ConfigHash:      fmt.Sprintf("runtime-check-%d", time.Now().Unix()),
-				Success:         true,
-				EffectiveConfig: effectiveConfig,
-			}
-
-			envelope := &controlpb.Envelope{
-				Body: &controlpb.Envelope_ConfigAck{
-					ConfigAck: ack,
-				},
-			}
-
-			if err := a.stream.Send(envelope); err != nil {
-				log.Printf("[Device %s] Failed to send runtime config update: %v", a.nodeID, err)
-			} else {
-				log.Printf("[Device %s] Sent runtime-verified config (%d bytes)", a.nodeID, len(effectiveConfig))
+			// Catches drift the watcher missed, e.g. on platforms without inotify
+			if hasCapability(a.driver, CapabilityDriftDetection) {
+				a.checkDrift(ctx)
 			}
+			a.sendConfigHeartbeat(ctx)
 		}
 	}
 }
 
-func (a *DeviceAgent) register() error {
-	reg := &controlpb.EdgeIdentity{
-		NodeId:    a.nodeID,
-		Version:   "1.0.0",
-		Platform:  "linux/amd64",
-		AgentType: a.agentType,
-	}
-
-	envelope := &controlpb.Envelope{
+// registerEnvelope builds the registration that must be the first message on every stream.
+func (a *DeviceAgent) registerEnvelope() *controlpb.Envelope {
+	return &controlpb.Envelope{
 		Body: &controlpb.Envelope_Register{
-			Register: reg,
+			Register: a.identity(),
 		},
 	}
-
-	return a.stream.Send(envelope)
 }
 
-func (a *DeviceAgent) getFluentBitRuntimeConfig() ([]byte, error) {
-	// Query Fluent Bit's actual runtime state to detect real emission status
-	// This ensures we report what Fluent Bit is ACTUALLY doing, not just the config file
-	// Derive base URL from reload endpoint (e.g., http://fluentbit-device-1.opamp-edge.svc.cluster.local:2020)
-	uptimeURL := strings.Replace(a.reloadEndpoint, "/api/v2/reload", "/api/v1/uptime", 1)
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(uptimeURL)
-	if err != nil {
-		// Fluent Bit not responding, fall back to file
-		log.Printf("[Device %s] FluentBit API not available, reading from file: %v", a.nodeID, err)
-		return os.ReadFile(a.configPath)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		log.Printf("[Device %s] FluentBit API returned %d, falling back to file", a.nodeID, resp.StatusCode)
-		return os.ReadFile(a.configPath)
-	}
-
-	// Fluent Bit is running - read the config file but verify it matches reality
-	// In production, we would parse Fluent Bit's actual output plugin configuration
-	// For now, read file and add a runtime verification marker
-	fileConfig, err := os.ReadFile(a.configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	log.Printf("[Device %s] FluentBit is running (verified via API), reporting config from file", a.nodeID)
-	return fileConfig, nil
-}
-
-func (a *DeviceAgent) sendInitialEffectiveConfig() error {
-	// Get actual runtime config - queries Fluent Bit to verify it's running
-	effectiveConfig, err := a.getFluentBitRuntimeConfig()
+func (a *DeviceAgent) sendInitialEffectiveConfig(ctx context.Context) error {
+	// Get actual runtime config from the driver
+	effectiveConfig, err := a.driver.EffectiveConfig(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get runtime config: %w", err)
 	}
@@ -222,7 +497,7 @@ func (a *DeviceAgent) sendInitialEffectiveConfig() error {
 	// Send as a ConfigAck with the current config
 	ack := &controlpb.ConfigAck{
 		DeviceId:        a.nodeID,
-		ConfigHash:      fmt.Sprintf("initial-%d", time.Now().Unix()),
+		ConfigHash:      configHash(effectiveConfig),
 		Success:         true,
 		EffectiveConfig: effectiveConfig,
 	}
@@ -233,304 +508,386 @@ func (a *DeviceAgent) sendInitialEffectiveConfig() error {
 		},
 	}
 
-	log.Printf("[Device %s] Sending initial effective config (%d bytes, runtime verified)", a.nodeID, len(effectiveConfig))
-	return a.stream.Send(envelope)
+	a.log.Info("Sending initial effective config", attrConfigHash, ack.ConfigHash, "bytes", len(effectiveConfig))
+	// Regenerated on every (re)connect, so not worth persisting
+	if err := a.out.enqueue(envelope, PriorityHigh, false); err != nil {
+		return err
+	}
+	a.setReportedConfigHash(ack.ConfigHash)
+	return nil
 }
 
-func (a *DeviceAgent) receiveLoop(ctx context.Context) {
-	log.Printf("[Device %s] Starting receive loop", a.nodeID)
+func (a *DeviceAgent) receiveLoop(ctx context.Context, stream Stream) {
+	a.log.Debug("Starting receive loop")
+	hb := newHeartbeats()
+	hbCtx, stopHeartbeats := context.WithCancel(ctx)
+	defer stopHeartbeats()
+	go a.sendHeartbeats(hbCtx, hb)
+	ticker := time.NewTicker(a.negotiated.heartbeatInterval())
+	defer ticker.Stop()
+	registered := false
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[Device %s] Receive loop context done", a.nodeID)
+			a.log.Debug("Receive loop stopped")
 			return
-		default:
-			envelope, err := a.stream.Recv()
-			if err != nil {
-				log.Printf("[Device %s] Receive error: %v, attempting reconnect...", a.nodeID, err)
-				a.reconnect(ctx)
-				return
+		case <-stream.Done():
+			a.log.Warn("Stream lost, reconnecting", "error", stream.Err())
+			a.stats.streamDown(stream.Err())
+			stopHeartbeats()
+			a.reconnect(ctx)
+			return
+		case <-ticker.C:
+			if !registered {
+				// A legacy server never acknowledges the registration
+				registered = true
+				a.registered(ctx)
 			}
-
-			log.Printf("[Device %s] Received envelope", a.nodeID)
-			switch body := envelope.Body.(type) {
-			case *controlpb.Envelope_Command:
-				a.handleCommand(ctx, body.Command)
-			case *controlpb.Envelope_ConfigPush:
-				a.handleConfigPush(ctx, body.ConfigPush)
-			default:
-				log.Printf("[Device %s] Unknown envelope type", a.nodeID)
+			if err := a.checkSilence(hb); err != nil {
+				// Recv would block forever on a half-open connection
+				a.log.Warn("Server went silent, dropping stream", "error", err)
+				stream.Abort(err)
+			}
+		case envelope := <-stream.Recv():
+			a.log.Debug("Received envelope")
+			a.received(hb, envelope)
+			a.handleEnvelope(ctx, envelope)
+			// Nothing is read while a handler runs (a config push can take
+			// longer than the timeout), so silence counts from when the
+			// loop is listening again
+			hb.heard()
+			if envelope.GetRegisterAck() != nil {
+				ticker.Reset(a.negotiated.heartbeatInterval())
+				hb.setInterval(a.negotiated.heartbeatInterval())
+				if !registered {
+					registered = true
+					a.registered(ctx)
+				}
 			}
 		}
 	}
 }
 
-func (a *DeviceAgent) handleCommand(ctx context.Context, cmd *controlpb.Command) {
-	log.Printf("[Device %s] Received command: type=%s, correlationId=%s",
-		a.nodeID, cmd.GetType(), cmd.GetCorrelationId())
-
-	switch cmd.GetType() {
-	case "FetchStatus":
-		status := fmt.Sprintf(`{"device_id":"%s","status":"online","timestamp":%d}`,
-			a.nodeID, time.Now().Unix())
-		a.sendEvent(ctx, "StatusReport", status, cmd.GetCorrelationId())
+// handleEnvelope dispatches one message from the server.
+func (a *DeviceAgent) handleEnvelope(ctx context.Context, envelope *controlpb.Envelope) {
+	switch body := envelope.Body.(type) {
+	case *controlpb.Envelope_Command:
+		a.handleCommand(ctx, body.Command)
+	case *controlpb.Envelope_RegisterAck:
+		a.handleRegisterAck(ctx, body.RegisterAck)
+	case *controlpb.Envelope_Heartbeat:
+		// Already accounted for by the receive loop
+	case *controlpb.Envelope_ConfigPush:
+		// Pushes carry no correlation id; the hash identifies them
+		a.handleConfigPush(ctx, body.ConfigPush, body.ConfigPush.ConfigHash)
+	default:
+		a.log.Warn("Unknown envelope type", "type", fmt.Sprintf("%T", envelope.Body))
+	}
+}
 
-	case "Reboot":
-		log.Printf("[Device %s] Reboot requested", a.nodeID)
-		a.sendEvent(ctx, "RebootAcknowledged", "Device rebooting", cmd.GetCorrelationId())
+// handleCommand dispatches a command on its typed body. Legacy commands
+// from servers that only set type and payload are given one first.
+func (a *DeviceAgent) handleCommand(ctx context.Context, cmd *controlpb.Command) {
+	err := upgradeCommand(cmd)
+	a.log.Info("Received command", "type", cmd.GetType(), attrCorrelationID, cmd.GetCorrelationId())
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+
+	switch body := cmd.Body.(type) {
+	case *controlpb.Command_FetchStatus:
+		report := a.buildStatus(ctx)
+		payload, _ := json.Marshal(report)
+		a.sendResult(ctx, &controlpb.Event{
+			Type:          "StatusReport",
+			Payload:       string(payload),
+			CorrelationId: cmd.GetCorrelationId(),
+			Body:          &controlpb.Event_Status{Status: report.proto()},
+		})
+
+	case *controlpb.Command_Restart:
+		// An unset target must not default to restarting anything
+		if _, ok := restartActions[body.Restart.GetTarget()]; !ok {
+			a.commandFailed(ctx, cmd, fmt.Errorf("unsupported restart target %s", body.Restart.GetTarget()))
+			return
+		}
+		a.handleAction(ctx, cmd)
 
-	case "UpdateConfig":
+	case *controlpb.Command_UpdateConfig:
 		// Handle config update via Command (same as ConfigPush)
-		log.Printf("[Device %s] Received UpdateConfig command, size=%d", a.nodeID, len(cmd.GetPayload()))
+		a.log.Info("Received UpdateConfig command", "bytes", len(body.UpdateConfig.GetConfigData()), attrCorrelationID, cmd.GetCorrelationId())
 		configPush := &controlpb.ConfigPush{
 			DeviceId:   a.nodeID,
-			ConfigData: []byte(cmd.GetPayload()),
+			ConfigData: body.UpdateConfig.GetConfigData(),
+			ConfigHash: body.UpdateConfig.GetConfigHash(),
 		}
-		a.handleConfigPush(ctx, configPush)
+		a.handleConfigPush(ctx, configPush, cmd.GetCorrelationId())
 
-	default:
-		log.Printf("[Device %s] Unknown command type: %s", a.nodeID, cmd.GetType())
-		a.sendEvent(ctx, "CommandUnknown", fmt.Sprintf("Unknown command: %s", cmd.GetType()), cmd.GetCorrelationId())
-	}
-}
+	case *controlpb.Command_FetchLogs:
+		a.handleFetchLogs(ctx, cmd, body.FetchLogs)
 
-func (a *DeviceAgent) handleConfigPush(ctx context.Context, cfg *controlpb.ConfigPush) {
-	log.Printf("[Device %s] Received ConfigPush: device=%s, hash=%s, size=%d",
-		a.nodeID, cfg.DeviceId, cfg.ConfigHash, len(cfg.ConfigData))
+	case *controlpb.Command_SetLogLevel:
+		a.handleSetLogLevel(ctx, cmd, body.SetLogLevel)
 
-	ack := &controlpb.ConfigAck{
-		DeviceId:   cfg.DeviceId,
-		ConfigHash: cfg.ConfigHash,
-	}
-
-	var err error
-	if a.agentType == "fluentbit" {
-		// Direct management: write config and call reload API
-		err = a.handleFluentBitConfig(cfg.ConfigData)
-		if err == nil {
-			ack.Success = true
-			// Get actual runtime config with verification
-			effectiveConfig, readErr := a.getFluentBitRuntimeConfig()
-			if readErr != nil {
-				log.Printf("[Device %s] Failed to get runtime config: %v", a.nodeID, readErr)
-				ack.EffectiveConfig = cfg.ConfigData // fallback to pushed config
-			} else {
-				ack.EffectiveConfig = effectiveConfig
-				log.Printf("[Device %s] Reporting runtime-verified effective config (%d bytes)", a.nodeID, len(effectiveConfig))
-			}
-		} else {
-			ack.Success = false
-			ack.ErrorMessage = err.Error()
-		}
-	} else {
-		// Forward to local supervisor (for otelcol or other agents)
-		err = a.forwardToLocalSupervisor(cfg, ack)
-		if err == nil {
-			// Read actual running config from local supervisor
-			effectiveConfig, readErr := a.getEffectiveConfigFromLocalSupervisor()
-			if readErr != nil {
-				log.Printf("[Device %s] Failed to get effective config from local supervisor: %v", a.nodeID, readErr)
-				ack.EffectiveConfig = cfg.ConfigData // fallback to pushed config
-			} else {
-				ack.EffectiveConfig = effectiveConfig
-				log.Printf("[Device %s] Got effective config from local supervisor (%d bytes)", a.nodeID, len(effectiveConfig))
-			}
-		}
-	}
+	case *controlpb.Command_FetchMetrics:
+		a.handleFetchMetrics(ctx, cmd)
 
-	// Send ACK back to supervisor
-	a.sendConfigAck(ctx, ack)
-}
+	case *controlpb.Command_ListConfigHistory:
+		a.handleListConfigHistory(ctx, cmd)
 
-func (a *DeviceAgent) handleFluentBitConfig(configData []byte) error {
-	// Write config to file
-	log.Printf("[Device %s] Writing Fluent Bit config to %s", a.nodeID, a.configPath)
+	case *controlpb.Command_DiffConfig:
+		a.handleDiffConfig(ctx, cmd, body.DiffConfig)
 
-	// Ensure directory exists
-	dir := filepath.Dir(a.configPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config dir: %w", err)
-	}
+	case *controlpb.Command_RollbackConfig:
+		a.handleRollbackConfig(ctx, cmd, body.RollbackConfig)
 
-	if err := os.WriteFile(a.configPath, configData, 0644); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	default:
+		a.log.Warn("Unknown command type", "type", cmd.GetType(), attrCorrelationID, cmd.GetCorrelationId())
+		a.sendResult(ctx, &controlpb.Event{
+			Type:          "CommandUnknown",
+			Payload:       fmt.Sprintf("Unknown command: %s", cmd.GetType()),
+			CorrelationId: cmd.GetCorrelationId(),
+			Body:          &controlpb.Event_CommandUnknown{CommandUnknown: &controlpb.CommandError{Command: cmd.GetType()}},
+		})
 	}
-	log.Printf("[Device %s] Config written successfully", a.nodeID)
-
-	// Small delay to ensure filesystem sync before reload
-	time.Sleep(500 * time.Millisecond)
+}
 
-	// Call Fluent Bit reload API - fire and forget with short timeout
-	// FluentBit hot reload can hang during certain config transitions,
-	// but the reload actually succeeds even if the HTTP response times out
-	log.Printf("[Device %s] Calling Fluent Bit reload API: %s", a.nodeID, a.reloadEndpoint)
+// handleConfigPush applies a pushed config, records it in the history and
+// acks it. The ack that was sent is returned.
+func (a *DeviceAgent) handleConfigPush(ctx context.Context, cfg *controlpb.ConfigPush, correlationID string) *controlpb.ConfigAck {
+	logger := a.log.With(attrConfigHash, cfg.ConfigHash, attrCorrelationID, correlationID)
+	logger.Info("Received ConfigPush", "device_id", cfg.DeviceId, "bytes", len(cfg.ConfigData))
 
-	// Use a goroutine to call reload API without blocking
-	go func() {
-		client := &http.Client{Timeout: 10 * time.Second}
-		req, _ := http.NewRequest("POST", a.reloadEndpoint, bytes.NewReader([]byte{}))
-		req.Header.Set("Content-Type", "application/json")
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("[Device %s] Reload API call error (may still succeed): %v", a.nodeID, err)
-			return
-		}
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		log.Printf("[Device %s] Fluent Bit reload response: %s", a.nodeID, string(body))
+	ack := &controlpb.ConfigAck{
+		DeviceId:      cfg.DeviceId,
+		ConfigHash:    cfg.ConfigHash,
+		CorrelationId: correlationID,
+	}
+	start := time.Now()
+	outcome := HistoryApplied
+	defer func() {
+		a.stats.configApplied(applyStatus{
+			Hash:    cfg.ConfigHash,
+			Outcome: outcome,
+			Success: ack.Success,
+			Error:   ack.ErrorMessage,
+			At:      time.Now(),
+		}, time.Since(start))
 	}()
 
-	// Give FluentBit time to process the reload
-	time.Sleep(2 * time.Second)
-	log.Printf("[Device %s] Fluent Bit reload triggered (async)", a.nodeID)
-	return nil
-}
-
-func (a *DeviceAgent) forwardToLocalSupervisor(cfg *controlpb.ConfigPush, ack *controlpb.ConfigAck) error {
-	// Forward config to local supervisor via HTTP
-	url := fmt.Sprintf("%s/config", a.localSupervisorURL)
-	resp, err := http.Post(url, "application/yaml", bytes.NewReader(cfg.ConfigData))
-
-	if err != nil {
-		log.Printf("[Device %s] Failed to forward config to local supervisor: %v", a.nodeID, err)
-		ack.Success = false
-		ack.ErrorMessage = fmt.Sprintf("HTTP error: %v", err)
-		return err
+	// Make sure we received exactly what the supervisor sent
+	if err := verifyConfigHash(cfg.ConfigData, cfg.ConfigHash); err != nil {
+		logger.Warn("Config rejected", "error", err)
+		outcome = HistoryRejected
+		a.recordHistory(cfg.ConfigData, correlationID, outcome, err)
+		a.rejectConfig(ctx, ack, err)
+		return ack
+	}
+
+	// Reject bad configs before anything touches the live file
+	if a.opts.Validator != nil {
+		if err := a.opts.Validator.Validate(ctx, cfg.ConfigData); err != nil {
+			logger.Warn("Config rejected by validator", "error", err)
+			outcome = HistoryRejected
+			a.recordHistory(cfg.ConfigData, correlationID, outcome, err)
+			a.rejectConfig(ctx, ack, err)
+			return ack
+		}
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
 
-	if resp.StatusCode == http.StatusOK {
-		log.Printf("[Device %s] Local supervisor accepted config", a.nodeID)
+	result, err := a.applyConfig(ctx, cfg.ConfigData)
+	if result.EventType != "" {
+		payload, _ := json.Marshal(result.Event)
+		a.sendEvent(ctx, result.EventType, string(payload), cfg.ConfigHash)
+	}
+	if result.RolledBack {
+		outcome = HistoryRolledBack
+	}
+	if err == nil {
 		ack.Success = true
-		ack.EffectiveConfig = cfg.ConfigData
-		return nil
+	} else {
+		ack.ErrorMessage = err.Error()
+	}
+	// Report what is live now: the new config, or what a failed apply left running
+	effectiveConfig, readErr := a.driver.EffectiveConfig(ctx)
+	switch {
+	case readErr == nil:
+		ack.EffectiveConfig = effectiveConfig
+		logger.Debug("Reporting effective config", "bytes", len(effectiveConfig))
+	case err == nil:
+		logger.Warn("Failed to get effective config", "error", readErr)
+		ack.EffectiveConfig = cfg.ConfigData // fallback to pushed config
+	default:
+		logger.Warn("Failed to get effective config", "error", readErr)
 	}
 
-	log.Printf("[Device %s] Local supervisor rejected config: %s", a.nodeID, string(body))
-	ack.Success = false
-	ack.ErrorMessage = fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(body))
-	return fmt.Errorf("local supervisor rejected: %s", string(body))
+	if err != nil && outcome == HistoryApplied {
+		outcome = HistoryFailed
+	}
+	a.recordHistory(cfg.ConfigData, correlationID, outcome, err)
+
+	// Send ACK back to supervisor
+	a.sendConfigAck(ctx, ack)
+	return ack
 }
 
-func (a *DeviceAgent) getEffectiveConfigFromLocalSupervisor() ([]byte, error) {
-	// Get the actual running config from local supervisor
-	url := fmt.Sprintf("%s/config", a.localSupervisorURL)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get config from local supervisor: %w", err)
+// applyConfig applies config through the driver. configMu is held
+// throughout, so drift checks never see a half-finished apply.
+func (a *DeviceAgent) applyConfig(ctx context.Context, config []byte) (ApplyResult, error) {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	result, err := a.driver.Apply(ctx, config)
+	if err == nil {
+		a.managedConfig = config
+	} else if result.RolledBack {
+		if restored, readErr := os.ReadFile(a.configPath); readErr == nil {
+			a.managedConfig = restored
+		}
 	}
-	defer resp.Body.Close()
+	return result, err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("local supervisor returned status %d: %s", resp.StatusCode, string(body))
-	}
+// lastGoodConfigPath is where the most recent config that reloaded cleanly is kept.
+func (a *DeviceAgent) lastGoodConfigPath() string {
+	return filepath.Join(a.opts.StateDir, filepath.Base(a.configPath)+".last-good")
+}
 
-	config, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config response: %w", err)
-	}
+// rejectConfig acks a push that was refused before being applied, reporting
+// the config that is still running.
+func (a *DeviceAgent) rejectConfig(ctx context.Context, ack *controlpb.ConfigAck, err error) {
+	ack.Success = false
+	ack.ErrorMessage = err.Error()
 
-	return config, nil
+	current, readErr := a.driver.EffectiveConfig(ctx)
+	if readErr != nil {
+		a.log.Warn("Failed to read current config for rejected push", attrConfigHash, ack.ConfigHash, "error", readErr)
+	} else {
+		ack.EffectiveConfig = current
+	}
+	a.sendConfigAck(ctx, ack)
 }
 
+// sendConfigAck queues an ack. Its hash always describes the effective
+// config, so the supervisor can compare it with what it pushed; only when the
+// effective config is unknown does the pushed hash stay for correlation.
 func (a *DeviceAgent) sendConfigAck(ctx context.Context, ack *controlpb.ConfigAck) {
+	if ack.EffectiveConfig != nil {
+		ack.ConfigHash = configHash(ack.EffectiveConfig)
+		a.setReportedConfigHash(ack.ConfigHash)
+	}
+
 	envelope := &controlpb.Envelope{
 		Body: &controlpb.Envelope_ConfigAck{
 			ConfigAck: ack,
 		},
 	}
 
-	if err := a.stream.Send(envelope); err != nil {
-		log.Printf("[Device %s] Failed to send ConfigAck: %v", a.nodeID, err)
+	if err := a.out.enqueue(envelope, PriorityHigh, true); err != nil {
+		a.log.Error("Failed to queue ConfigAck", attrConfigHash, ack.ConfigHash, "error", err)
 	} else {
-		log.Printf("[Device %s] Sent ConfigAck: success=%v", a.nodeID, ack.Success)
+		a.log.Info("Queued ConfigAck", "success", ack.Success, attrConfigHash, ack.ConfigHash)
 	}
 }
 
 func (a *DeviceAgent) sendEvent(ctx context.Context, eventType, payload, correlationID string) {
-	event := &controlpb.Event{
+	a.sendResult(ctx, &controlpb.Event{
 		Type:          eventType,
 		Payload:       payload,
-		TsUnixNano:    time.Now().UnixNano(),
 		CorrelationId: correlationID,
-	}
-
-	envelope := &controlpb.Envelope{
-		Body: &controlpb.Envelope_Event{
-			Event: event,
-		},
-	}
+	})
+}
 
-	if err := a.stream.Send(envelope); err != nil {
-		log.Printf("[Device %s] Failed to send event: %v", a.nodeID, err)
+// sendResult queues an event that may carry a typed body besides its payload.
+func (a *DeviceAgent) sendResult(ctx context.Context, event *controlpb.Event) {
+	if err := a.out.enqueue(a.eventEnvelope(event), PriorityNormal, true); err != nil {
+		a.log.Error("Failed to queue event", "type", event.Type, attrCorrelationID, event.CorrelationId, "error", err)
 	}
 }
 
-func (a *DeviceAgent) statusReportLoop(ctx context.Context) {
-	ticker := time.NewTicker(60 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			status := fmt.Sprintf(`{"device_id":"%s","status":"online","uptime":%d}`,
-				a.nodeID, time.Now().Unix())
-			a.sendEvent(ctx, "PeriodicStatus", status, "")
-		}
-	}
+// commandFailed reports a command that could not be carried out.
+func (a *DeviceAgent) commandFailed(ctx context.Context, cmd *controlpb.Command, err error) {
+	a.log.Warn("Command failed", "type", cmd.GetType(), attrCorrelationID, cmd.GetCorrelationId(), "error", err)
+	payload, _ := json.Marshal(map[string]string{
+		"command": cmd.GetType(),
+		"error":   err.Error(),
+	})
+	a.sendResult(ctx, &controlpb.Event{
+		Type:          "CommandFailed",
+		Payload:       string(payload),
+		CorrelationId: cmd.GetCorrelationId(),
+		Body:          &controlpb.Event_CommandFailed{CommandFailed: &controlpb.CommandError{Command: cmd.GetType(), Error: err.Error()}},
+	})
 }
 
 func (a *DeviceAgent) Stop() {
-	if a.stream != nil {
-		a.stream.CloseSend()
+	// Cancelling stops the sender, which closes the stream it owns
+	if a.cancel != nil {
+		a.cancel()
+		<-a.senderDone
 	}
-	if a.conn != nil {
-		a.conn.Close()
+	// The collector gets its grace period to exit before the agent does
+	if a.childDone != nil {
+		<-a.childDone
 	}
+	a.transport.Close()
+	a.stopHTTPServer()
 }
 
 func (a *DeviceAgent) reconnect(ctx context.Context) {
-	for {
+	policy := a.opts.Backoff
+
+	for attempt := 0; ; attempt++ {
+		if policy.MaxRetries > 0 && attempt >= policy.MaxRetries {
+			err := fmt.Errorf("failed to reconnect to supervisor after %d attempts", attempt)
+			a.log.Error("Giving up", "error", err)
+			select {
+			case a.errCh <- err:
+			default:
+			}
+			return
+		}
+
+		delay := policy.Delay(attempt, nil)
+		a.log.Info("Reconnecting to supervisor", "delay", delay.Round(time.Millisecond), "attempt", attempt+1)
 		select {
 		case <-ctx.Done():
 			return
-		default:
-			log.Printf("[Device %s] Reconnecting to supervisor...", a.nodeID)
-			time.Sleep(5 * time.Second)
-
-			// Close existing connection
-			if a.stream != nil {
-				a.stream.CloseSend()
-			}
-
-			// Create new stream
-			stream, err := a.client.Control(ctx)
-			if err != nil {
-				log.Printf("[Device %s] Reconnect failed: %v", a.nodeID, err)
-				continue
-			}
-			a.stream = stream
+		case <-time.After(delay):
+		}
 
-			// Re-register
-			if err := a.register(); err != nil {
-				log.Printf("[Device %s] Re-register failed: %v", a.nodeID, err)
-				continue
-			}
+		if err := a.connect(ctx); err != nil {
+			a.log.Warn("Reconnect failed", "error", err)
+			continue
+		}
+		a.log.Info("Reconnected")
+		return
+	}
+}
 
-			log.Printf("[Device %s] Reconnected successfully", a.nodeID)
+// connect opens a stream, registers on it and starts receiving. The
+// transport redials when the connection looks stale.
+func (a *DeviceAgent) connect(ctx context.Context) error {
+	stream, err := a.transport.Connect(ctx)
+	if err != nil {
+		a.stats.connectFailed(err)
+		return err
+	}
 
-			// Send initial effective config after reconnection
-			if err := a.sendInitialEffectiveConfig(); err != nil {
-				log.Printf("[Device %s] Failed to send initial effective config on reconnect: %v", a.nodeID, err)
-				// Continue anyway - not a fatal error
-			}
+	// Hand the stream to the sender and register; this also closes the old stream
+	if err := a.out.attach(ctx, stream, a.registerEnvelope()); err != nil {
+		a.stats.connectFailed(err)
+		return fmt.Errorf("failed to register: %w", err)
+	}
+	a.stats.streamUp()
+	a.resetNegotiation()
 
-			go a.receiveLoop(ctx)
-			return
-		}
+	if err := a.sendInitialEffectiveConfig(ctx); err != nil {
+		a.log.Warn("Failed to send initial effective config", "error", err)
+		// Continue anyway - not a fatal error
 	}
+
+	go a.receiveLoop(ctx, stream)
+	return nil
 }
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// fakeFluentBit is an httptest stand-in for the Fluent Bit HTTP server. It
+// rejects reloads while the config file on disk contains "BROKEN" and
+// accepts but never completes them while it contains "STALL".
+type fakeFluentBit struct {
+	*httptest.Server
+	configPath string
+
+	mu             sync.Mutex
+	reloads        int
+	hotReloadCount int64
+	uptimeSec      int64
+	// noCounter emulates Fluent Bit versions without GET /api/v2/reload
+	noCounter bool
+	// restart makes a reload reset uptime instead of bumping the counter
+	restart bool
+	// healthCode is the /api/v1/health status; 0 emulates Health_Check off
+	healthCode int
+}
+
+func newFakeFluentBit(t *testing.T, configPath string) *fakeFluentBit {
+	t.Helper()
+	fb := &fakeFluentBit{configPath: configPath, uptimeSec: 100}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/reload", func(w http.ResponseWriter, r *http.Request) {
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+
+		if r.Method == http.MethodGet {
+			if fb.noCounter {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"hot_reload_count":%d}`, fb.hotReloadCount)
+			return
+		}
+
+		io.Copy(io.Discard, r.Body)
+		fb.reloads++
+		data, _ := os.ReadFile(fb.configPath)
+		switch {
+		case strings.Contains(string(data), "BROKEN"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"reload":"failed","status":-1}`))
+			return
+		case strings.Contains(string(data), "STALL"):
+		case fb.restart:
+			fb.uptimeSec = 0
+		default:
+			fb.hotReloadCount++
+		}
+		w.Write([]byte(`{"reload":"done","status":0}`))
+	})
+	mux.HandleFunc("/api/v1/uptime", func(w http.ResponseWriter, r *http.Request) {
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+		fmt.Fprintf(w, `{"uptime_sec":%d}`, fb.uptimeSec)
+	})
+	mux.HandleFunc("/api/v1/health", func(w http.ResponseWriter, r *http.Request) {
+		fb.mu.Lock()
+		defer fb.mu.Unlock()
+		switch fb.healthCode {
+		case 0:
+			http.NotFound(w, r)
+		case http.StatusOK:
+			fmt.Fprint(w, "ok")
+		default:
+			w.WriteHeader(fb.healthCode)
+			fmt.Fprint(w, "error")
+		}
+	})
+	fb.Server = httptest.NewServer(mux)
+	t.Cleanup(fb.Close)
+	return fb
+}
+
+func (fb *fakeFluentBit) Reloads() int {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return fb.reloads
+}
+
+func newFluentBitAgent(t *testing.T) (*DeviceAgent, *fakeFluentBit) {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "fluent-bit.conf")
+	fb := newFakeFluentBit(t, configPath)
+	a := NewDeviceAgent("unused", "device-1", "fluentbit", configPath, fb.URL+"/api/v2/reload", Options{
+		ReloadTimeout:      time.Second,
+		ReloadPollInterval: 10 * time.Millisecond,
+	})
+	return a, fb
+}
+
+// reloadOf returns the Fluent Bit reload reported with an apply
+func reloadOf(t *testing.T, applied ApplyResult) reloadResult {
+	t.Helper()
+	result, ok := applied.Event.(reloadResult)
+	if !ok || applied.EventType != "FluentBitReload" {
+		t.Fatalf("apply reported %s %#v, want a FluentBitReload", applied.EventType, applied.Event)
+	}
+	return result
+}
+
+// TestFluentBitDriverApplies tests a clean apply and last-known-good retention
+func TestFluentBitDriverApplies(t *testing.T) {
+	a, fb := newFluentBitAgent(t)
+	good := []byte("[OUTPUT]\n    Name stdout\n")
+
+	applied, err := a.applyConfig(context.Background(), good)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if result := reloadOf(t, applied); result.Outcome != ReloadApplied || result.CountBefore != 0 || result.CountAfter != 1 {
+		t.Errorf("got result %+v, want applied with count 0 -> 1", result)
+	}
+	if got, _ := os.ReadFile(a.configPath); string(got) != string(good) {
+		t.Errorf("live config = %q, want %q", got, good)
+	}
+	if got, _ := os.ReadFile(a.lastGoodConfigPath()); string(got) != string(good) {
+		t.Errorf("last-good config = %q, want %q", got, good)
+	}
+	if fb.Reloads() != 1 {
+		t.Errorf("reloads = %d, want 1", fb.Reloads())
+	}
+}
+
+// TestFluentBitDriverRollsBack tests that a rejected config is replaced
+// by the last-known-good one and reloaded again
+func TestFluentBitDriverRollsBack(t *testing.T) {
+	a, fb := newFluentBitAgent(t)
+	good := []byte("[OUTPUT]\n    Name stdout\n")
+	if _, err := a.applyConfig(context.Background(), good); err != nil {
+		t.Fatalf("apply good config: %v", err)
+	}
+
+	applied, err := a.applyConfig(context.Background(), []byte("[OUTPUT]\n    BROKEN\n"))
+	if err == nil {
+		t.Fatal("expected error for rejected config")
+	}
+	if result := reloadOf(t, applied); result.Outcome != ReloadRejected || !result.RolledBack || !applied.RolledBack {
+		t.Errorf("got result %+v, want rejected and rolled back", result)
+	}
+	if !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("error %q does not mention the rollback", err)
+	}
+	if got, _ := os.ReadFile(a.configPath); string(got) != string(good) {
+		t.Errorf("live config after rollback = %q, want %q", got, good)
+	}
+	// good apply, rejected apply, rollback reload
+	if fb.Reloads() != 3 {
+		t.Errorf("reloads = %d, want 3", fb.Reloads())
+	}
+}
+
+// TestReloadOutcomes tests how the reload result is classified
+func TestReloadOutcomes(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		setup   func(fb *fakeFluentBit)
+		want    ReloadOutcome
+		wantMsg string
+	}{
+		{"counter increments", "[OUTPUT]\n", nil, ReloadApplied, "reload applied"},
+		{"uptime resets", "[OUTPUT]\n", func(fb *fakeFluentBit) { fb.restart = true }, ReloadApplied, "uptime reset"},
+		{"no counter available", "[OUTPUT]\n", func(fb *fakeFluentBit) { fb.noCounter = true }, ReloadApplied, "liveness only"},
+		{"rejected", "BROKEN", nil, ReloadRejected, "reload rejected: HTTP 400"},
+		{"counter never moves", "STALL", nil, ReloadTimedOut, "reload timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, fb := newFluentBitAgent(t)
+			d := a.driver.(*fluentBitDriver)
+			d.reloadTimeout = 200 * time.Millisecond
+			if tt.setup != nil {
+				tt.setup(fb)
+			}
+			os.WriteFile(a.configPath, []byte(tt.config), 0644)
+
+			result := d.reload(context.Background())
+			if result.Outcome != tt.want {
+				t.Errorf("got outcome %q, want %q (%s)", result.Outcome, tt.want, result)
+			}
+			if !strings.Contains(result.String(), tt.wantMsg) {
+				t.Errorf("result %q does not contain %q", result, tt.wantMsg)
+			}
+		})
+	}
+}
+
+// TestReloadStopsOnCancel tests that shutting down does not wait out the reload timeout
+func TestReloadStopsOnCancel(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	d := a.driver.(*fluentBitDriver)
+	d.reloadTimeout = time.Minute
+	os.WriteFile(a.configPath, []byte("STALL"), 0644)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := d.reload(ctx)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("reload returned after %s", elapsed)
+	}
+	if result.Outcome == ReloadApplied || !strings.Contains(result.Detail, "stopped waiting") {
+		t.Errorf("result = %s", result)
+	}
+}
+
+// TestWriteFileAtomic tests that the target is replaced and no temp files remain
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "fluent-bit.conf")
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write %q: %v", content, err)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != "second" {
+		t.Errorf("got %q, want %q", got, "second")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files in dir, want 1", len(entries))
+	}
+}
+
+// TestVerifyConfigHash tests SHA-256 verification of pushed configs
+func TestVerifyConfigHash(t *testing.T) {
+	data := []byte("[OUTPUT]\n    Name stdout\n")
+	sum := configHash(data)
+
+	tests := []struct {
+		name    string
+		hash    string
+		wantErr bool
+	}{
+		{"plain hex", sum, false},
+		{"prefixed upper case", "sha256:" + strings.ToUpper(sum), false},
+		{"empty hash is not verified", "", false},
+		{"mismatch", configHash([]byte("other")), true},
+		{"legacy opaque hash", "v42", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyConfigHash(data, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyConfigHash(%q) = %v, wantErr %v", tt.hash, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestHandleConfigPushReportsEffectiveHash tests that acks carry the hash of
+// the config on disk, both after an apply and after a rejected push
+func TestHandleConfigPushReportsEffectiveHash(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	good := []byte("[OUTPUT]\n    Name stdout\n")
+
+	a.handleConfigPush(context.Background(), &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: good, ConfigHash: configHash(good)}, "")
+	ack := nextAck(t, a)
+	if !ack.Success || ack.ConfigHash != configHash(good) {
+		t.Errorf("apply ack: success=%v hash=%s, want success with %s", ack.Success, ack.ConfigHash, configHash(good))
+	}
+
+	tampered := []byte("[OUTPUT]\n    Name null\n")
+	a.handleConfigPush(context.Background(), &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: tampered, ConfigHash: configHash(good)}, "")
+	ack = nextAck(t, a)
+	if ack.Success || !strings.Contains(ack.ErrorMessage, "hash mismatch") {
+		t.Errorf("tampered push: success=%v error=%q, want hash mismatch", ack.Success, ack.ErrorMessage)
+	}
+	if ack.ConfigHash != configHash(good) {
+		t.Errorf("tampered push ack hash = %s, want hash of running config", ack.ConfigHash)
+	}
+	if got, _ := os.ReadFile(a.configPath); string(got) != string(good) {
+		t.Errorf("tampered config was written: %q", got)
+	}
+}
+
+// nextAck pops the next queued ConfigAck from the agent's sender
+func nextAck(t *testing.T, a *DeviceAgent) *controlpb.ConfigAck {
+	t.Helper()
+	select {
+	case msg := <-a.out.high:
+		return msg.env.GetConfigAck()
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ConfigAck queued")
+		return nil
+	}
+}
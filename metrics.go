@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// typedMetrics is implemented by metrics reports that have a typed form.
+type typedMetrics interface {
+	proto() *controlpb.MetricsReport
+}
+
+// collectorMetrics returns the metrics event for the driver: its detailed
+// report when it has one (FluentBitMetrics), otherwise its plain counters.
+func (a *DeviceAgent) collectorMetrics(ctx context.Context) (*controlpb.Event, error) {
+	if r, ok := a.driver.(metricsReporter); ok {
+		eventType, report, err := r.MetricsReport(ctx)
+		if err != nil {
+			return nil, err
+		}
+		payload, _ := json.Marshal(report)
+		event := &controlpb.Event{Type: eventType, Payload: string(payload)}
+		if typed, ok := report.(typedMetrics); ok {
+			event.Body = &controlpb.Event_Metrics{Metrics: typed.proto()}
+		}
+		return event, nil
+	}
+	metrics, err := a.driver.Metrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"timestamp": time.Now(),
+		"metrics":   metrics,
+	})
+	return &controlpb.Event{
+		Type:    "CollectorMetrics",
+		Payload: string(payload),
+		Body:    &controlpb.Event_Metrics{Metrics: &controlpb.MetricsReport{Values: metrics}},
+	}, nil
+}
+
+// metricsLoop reports collector metrics every MetricsInterval.
+func (a *DeviceAgent) metricsLoop(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.MetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			event, err := a.collectorMetrics(ctx)
+			if err != nil {
+				a.log.Warn("Failed to collect metrics", "error", err)
+				continue
+			}
+			// Samples are not worth keeping while offline; the next one's totals cover the gap
+			if err := a.out.enqueue(a.eventEnvelope(event), PriorityNormal, false); err != nil {
+				a.log.Warn("Failed to queue metrics", "error", err)
+			}
+		}
+	}
+}
+
+func (a *DeviceAgent) handleFetchMetrics(ctx context.Context, cmd *controlpb.Command) {
+	event, err := a.collectorMetrics(ctx)
+	if err != nil {
+		a.commandFailed(ctx, cmd, err)
+		return
+	}
+	event.CorrelationId = cmd.GetCorrelationId()
+	a.sendResult(ctx, event)
+}
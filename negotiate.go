@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// controlProtocolVersion is the newest Control protocol the agent speaks.
+// Version 1 is the original stream, version 2 adds RegisterAck, version 3
+// adds Heartbeat and version 4 typed command and event bodies.
+const controlProtocolVersion = 4
+
+// minHeartbeatInterval keeps a misconfigured server from making the agent
+// report its config hash in a tight loop.
+const minHeartbeatInterval = time.Second
+
+// negotiated holds the settings the server accepted for the current stream.
+type negotiated struct {
+	mu              sync.Mutex
+	protocolVersion uint32
+	heartbeat       time.Duration
+}
+
+func (n *negotiated) protocol() uint32 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.protocolVersion
+}
+
+func (n *negotiated) heartbeatInterval() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.heartbeat
+}
+
+// commands lists the command types handleCommand can carry out with the
+// current driver and options.
+func (a *DeviceAgent) commands() []string {
+	cmds := []string{"FetchStatus", "UpdateConfig", "FetchLogs", "SetLogLevel", ActionRestartAgent}
+	if hasCapability(a.driver, CapabilityMetrics) {
+		cmds = append(cmds, "FetchMetrics")
+	}
+	if hasCapability(a.driver, CapabilityRestart) {
+		cmds = append(cmds, ActionRestartCollector)
+	}
+	if a.opts.AllowReboot {
+		cmds = append(cmds, ActionReboot)
+	}
+	if a.history != nil {
+		cmds = append(cmds, "ListConfigHistory", "DiffConfig", "RollbackConfig")
+	}
+	return cmds
+}
+
+// configFormats names the config syntax the collector expects, so the
+// server does not push YAML to a collector that reads the classic format.
+func (a *DeviceAgent) configFormats() []string {
+	ext := strings.TrimPrefix(filepath.Ext(a.configPath), ".")
+	switch {
+	case ext == "yaml" || ext == "yml":
+		return []string{"yaml"}
+	case a.agentType == "fluentbit":
+		return []string{"fluentbit"}
+	case ext != "":
+		return []string{ext}
+	}
+	return nil
+}
+
+// resetNegotiation falls back to protocol version 1 until the server on a
+// new stream acknowledges the registration.
+func (a *DeviceAgent) resetNegotiation() {
+	a.negotiated.mu.Lock()
+	defer a.negotiated.mu.Unlock()
+	a.negotiated.protocolVersion = 1
+}
+
+// handleRegisterAck adopts the settings the server accepted and catches up
+// with the config it wants the device to run.
+func (a *DeviceAgent) handleRegisterAck(ctx context.Context, ack *controlpb.RegisterAck) {
+	version := ack.GetProtocolVersion()
+	if version == 0 || version > controlProtocolVersion {
+		a.log.Warn("Server accepted an unsupported protocol version, using ours", "protocol_version", version, "supported", controlProtocolVersion)
+		version = controlProtocolVersion
+	}
+	heartbeat := time.Duration(ack.GetHeartbeatIntervalMs()) * time.Millisecond
+	if heartbeat <= 0 {
+		heartbeat = a.opts.MonitorInterval
+	}
+	if heartbeat < minHeartbeatInterval {
+		heartbeat = minHeartbeatInterval
+	}
+
+	a.negotiated.mu.Lock()
+	a.negotiated.protocolVersion = version
+	changed := heartbeat != a.negotiated.heartbeat
+	a.negotiated.heartbeat = heartbeat
+	a.negotiated.mu.Unlock()
+	if changed {
+		// Only the newest interval matters to the monitor loop
+		select {
+		case <-a.heartbeatCh:
+		default:
+		}
+		a.heartbeatCh <- heartbeat
+	}
+	a.log.Info("Registration acknowledged", "protocol_version", version, "heartbeat_interval", heartbeat,
+		"desired_config_hash", ack.GetDesiredConfigHash())
+
+	if desired := ack.GetDesiredConfigHash(); desired != "" && desired != a.reportedConfigHash() {
+		a.catchUpConfig(ctx, desired)
+	}
+}
+
+// registered runs once the server on a new stream has acknowledged the
+// registration, or has been taken for a legacy server that never will.
+// Only then is it known whether events may carry typed bodies, so parked
+// events and the report of a pending action wait for it.
+func (a *DeviceAgent) registered(ctx context.Context) {
+	a.out.replayOutbox()
+	// Only once: a RestartAgent may be pending again by a later reconnect
+	a.pendingOnce.Do(func() { a.reportPendingAction(ctx) })
+}
+
+// catchUpConfig applies the desired config from the local history when the
+// device has run it before; otherwise it asks the server to push it.
+func (a *DeviceAgent) catchUpConfig(ctx context.Context, desired string) {
+	if a.history != nil {
+		if entry, ok := a.history.findApplied(desired); ok {
+			if _, content, err := a.history.get(entry.Version); err == nil {
+				a.log.Info("Restoring desired config from history", "version", entry.Version, attrConfigHash, desired)
+				// Acked like a push, so the hash identifies it
+				a.handleConfigPush(ctx, &controlpb.ConfigPush{
+					DeviceId:   a.nodeID,
+					ConfigData: content,
+					ConfigHash: desired,
+					AgentType:  a.agentType,
+				}, desired)
+				return
+			}
+		}
+	}
+	a.log.Info("Desired config not available locally", attrConfigHash, desired, "effective", a.reportedConfigHash())
+	payload, _ := json.Marshal(map[string]string{
+		"desired_hash":   desired,
+		"effective_hash": a.reportedConfigHash(),
+	})
+	a.sendEvent(ctx, "ConfigOutOfDate", string(payload), desired)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// TestRegisterAdvertisesCommands tests that every advertised command is one the agent handles
+func TestRegisterAdvertisesCommands(t *testing.T) {
+	a, _ := newFluentBitAgent(t)
+	reg := a.registerEnvelope().GetRegister()
+	if reg.ProtocolVersion != controlProtocolVersion || len(reg.ConfigFormats) != 1 || reg.ConfigFormats[0] != "fluentbit" {
+		t.Errorf("protocol %d, formats %v", reg.ProtocolVersion, reg.ConfigFormats)
+	}
+
+	for _, c := range reg.Commands {
+		switch c {
+		case ActionReboot:
+			t.Errorf("Reboot advertised without --allow-reboot")
+			continue
+		case ActionRestartAgent, ActionRestartCollector, "UpdateConfig":
+			// Disruptive; covered by their own tests
+			continue
+		}
+		a.handleCommand(context.Background(), &controlpb.Command{Type: c, Payload: "{}"})
+		for done := false; !done; {
+			select {
+			case msg := <-a.out.normal:
+				if ev := msg.env.GetEvent(); ev.GetType() == "CommandUnknown" {
+					t.Errorf("advertised command %s is unknown", c)
+				}
+			default:
+				done = true
+			}
+		}
+	}
+}
+
+// TestRegisterAck tests adopting the negotiated heartbeat and catching up with the desired config
+func TestRegisterAck(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "fluent-bit.conf")
+	os.WriteFile(configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+	mt := newMemoryTransport()
+	a := NewDeviceAgent("unused", "device-1", "file", configPath, "", Options{
+		Transport:       mt,
+		Backoff:         BackoffPolicy{Initial: time.Hour, Max: time.Hour, Multiplier: 1},
+		MonitorInterval: time.Hour,
+	})
+	desired := []byte("[OUTPUT]\n    Name null\n")
+	a.recordHistory(desired, "earlier", HistoryApplied, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop()
+	stream := mt.accept(t)
+	stream.next(t) // registration
+	stream.next(t) // initial effective config
+	if v := a.negotiated.protocol(); v != 1 {
+		t.Errorf("protocol before ack = %d, want 1", v)
+	}
+
+	// Version 2 has no Heartbeat messages to get in the way
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_RegisterAck{RegisterAck: &controlpb.RegisterAck{
+		ProtocolVersion:     2,
+		HeartbeatIntervalMs: 1000,
+		DesiredConfigHash:   configHash(desired),
+	}}})
+
+	// The desired config is in the history, so it is applied without a push
+	ack := stream.next(t).GetConfigAck()
+	if ack.GetConfigHash() != configHash(desired) || !ack.GetSuccess() {
+		t.Fatalf("got ack %v, want the desired config applied", ack)
+	}
+	if got, _ := os.ReadFile(configPath); string(got) != string(desired) {
+		t.Errorf("config = %q", got)
+	}
+	// The hour-long monitor interval was replaced by the negotiated second
+	if hb := stream.next(t).GetConfigAck(); hb.GetConfigHash() != configHash(desired) {
+		t.Errorf("got %v, want a config heartbeat", hb)
+	}
+	if v := a.negotiated.protocol(); v != 2 {
+		t.Errorf("protocol = %d", v)
+	}
+
+	// A config the device never ran has to be pushed
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_RegisterAck{RegisterAck: &controlpb.RegisterAck{
+		ProtocolVersion:   2,
+		DesiredConfigHash: "sha256:unknown",
+	}}})
+	for {
+		if ev := stream.next(t).GetEvent(); ev.GetType() == "ConfigOutOfDate" {
+			if ev.CorrelationId != "sha256:unknown" {
+				t.Errorf("correlation id %q", ev.CorrelationId)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+	"local.dev/opamp-device-agent/api/opamppb"
+)
+
+// OpAMPConfig selects the OpAMP client mode. A ws:// or wss:// server URL
+// keeps a WebSocket open; http:// or https:// polls the server.
+type OpAMPConfig struct {
+	ServerURL    string
+	PollInterval time.Duration
+}
+
+// opampCapability is the custom capability carrying agent Events and
+// Commands, which have no native OpAMP equivalent. Message data is the
+// protojson encoding of the controlpb message named by the message type.
+const opampCapability = "io.opamp-device-agent.control"
+
+const opampCapabilities = uint64(opamppb.AgentCapabilities_AgentCapabilities_ReportsStatus |
+	opamppb.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig |
+	opamppb.AgentCapabilities_AgentCapabilities_ReportsEffectiveConfig |
+	opamppb.AgentCapabilities_AgentCapabilities_ReportsRemoteConfig |
+	opamppb.AgentCapabilities_AgentCapabilities_AcceptsRestartCommand)
+
+// maxOpAMPMessage bounds server responses read over HTTP.
+const maxOpAMPMessage = 16 << 20
+
+// opampInstanceUID derives a stable 16-byte instance UID (a name-based UUID)
+// from the node ID, for when the persisted instance UID cannot be used.
+func opampInstanceUID(nodeID string) []byte {
+	sum := sha1.Sum([]byte("opamp-device-agent/" + nodeID))
+	uid := sum[:16]
+	uid[6] = uid[6]&0x0f | 0x50 // version 5
+	uid[8] = uid[8]&0x3f | 0x80 // RFC 4122 variant
+	return uid
+}
+
+// opampSession is the agent's OpAMP state. It outlives individual
+// connections so that a reconnect reports the same identity, sequence and
+// remote config status instead of starting over.
+type opampSession struct {
+	nodeID string
+	// configName is the preferred key in multi-file remote configs
+	configName  string
+	contentType string
+
+	mu           sync.Mutex
+	instanceUID  []byte
+	seq          uint64
+	description  *opamppb.AgentDescription
+	effective    *opamppb.EffectiveConfig
+	remoteStatus *opamppb.RemoteConfigStatus
+	// pendingHash is the content hash of the remote config being applied
+	pendingHash string
+}
+
+// newOpAMPSession starts a session reporting instanceUID, the agent's
+// persisted instance UID; servers key agents on it.
+func newOpAMPSession(nodeID, configPath, instanceUID string) *opampSession {
+	uid, err := parseUUID(instanceUID)
+	if err != nil {
+		nodeLogger(nodeID).Warn("Using instance UID derived from the node ID", "error", err)
+		uid = opampInstanceUID(nodeID)
+	}
+	contentType := "text/plain"
+	if strings.HasSuffix(configPath, ".yaml") || strings.HasSuffix(configPath, ".yml") {
+		contentType = "text/yaml"
+	}
+	return &opampSession{
+		nodeID:      nodeID,
+		configName:  filepath.Base(configPath),
+		contentType: contentType,
+		instanceUID: uid,
+	}
+}
+
+// stamp fills the header fields every AgentToServer message carries.
+func (s *opampSession) stamp(msg *opamppb.AgentToServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	msg.InstanceUid = s.instanceUID
+	msg.SequenceNum = s.seq
+	msg.Capabilities = opampCapabilities
+}
+
+// fullState is the report sent on connect and whenever the server asks.
+func (s *opampSession) fullState() *opamppb.AgentToServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &opamppb.AgentToServer{
+		AgentDescription:   s.description,
+		EffectiveConfig:    s.effective,
+		RemoteConfigStatus: s.remoteStatus,
+		CustomCapabilities: &opamppb.CustomCapabilities{Capabilities: []string{opampCapability}},
+	}
+}
+
+// fromEnvelope maps an outgoing Envelope onto an AgentToServer message.
+func (s *opampSession) fromEnvelope(env *controlpb.Envelope) (*opamppb.AgentToServer, error) {
+	switch body := env.Body.(type) {
+	case *controlpb.Envelope_Register:
+		s.mu.Lock()
+		s.description = agentDescription(body.Register)
+		s.mu.Unlock()
+		return s.fullState(), nil
+
+	case *controlpb.Envelope_ConfigAck:
+		return s.fromConfigAck(body.ConfigAck), nil
+
+	case *controlpb.Envelope_Event:
+		data, err := protojson.Marshal(body.Event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode event: %w", err)
+		}
+		return &opamppb.AgentToServer{
+			CustomMessage: &opamppb.CustomMessage{Capability: opampCapability, Type: "Event", Data: data},
+		}, nil
+	}
+	return nil, fmt.Errorf("envelope type %T has no OpAMP equivalent", env.Body)
+}
+
+// fromConfigAck reports the effective config and, if the ack settles the
+// remote config being applied, its status. A hash-only ack without a pending
+// remote config becomes a plain heartbeat.
+func (s *opampSession) fromConfigAck(ack *controlpb.ConfigAck) *opamppb.AgentToServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := &opamppb.AgentToServer{}
+	if ack.EffectiveConfig != nil {
+		s.effective = &opamppb.EffectiveConfig{
+			ConfigMap: &opamppb.AgentConfigMap{
+				ConfigMap: map[string]*opamppb.AgentConfigFile{
+					"": {Body: ack.EffectiveConfig, ContentType: s.contentType},
+				},
+			},
+		}
+		msg.EffectiveConfig = s.effective
+	}
+
+	// Heartbeats always succeed, so only a failure or the pushed content
+	// itself can settle the pending remote config
+	if s.pendingHash != "" && s.remoteStatus != nil && (!ack.Success || ack.ConfigHash == s.pendingHash) {
+		status := &opamppb.RemoteConfigStatus{
+			LastRemoteConfigHash: s.remoteStatus.LastRemoteConfigHash,
+			Status:               opamppb.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED,
+		}
+		if !ack.Success {
+			status.Status = opamppb.RemoteConfigStatuses_RemoteConfigStatuses_FAILED
+			status.ErrorMessage = ack.ErrorMessage
+		}
+		s.remoteStatus = status
+		s.pendingHash = ""
+		msg.RemoteConfigStatus = status
+	}
+	return msg
+}
+
+// fromServer maps a ServerToAgent message onto Envelopes for the receive
+// loop. report is set when the server expects a status report that no
+// Envelope will trigger.
+func (s *opampSession) fromServer(msg *opamppb.ServerToAgent) (envs []*controlpb.Envelope, report bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if uid := msg.GetAgentIdentification().GetNewInstanceUid(); len(uid) > 0 {
+		nodeLogger(s.nodeID).Info("OpAMP server assigned instance UID", "instance_uid", fmt.Sprintf("%x", uid))
+		s.instanceUID = uid
+		report = true
+	}
+	if msg.Flags&uint64(opamppb.ServerToAgentFlags_ServerToAgentFlags_ReportFullState) != 0 {
+		report = true
+	}
+
+	if rc := msg.GetRemoteConfig(); rc != nil {
+		switch body, err := s.pickConfigFile(rc.GetConfig()); {
+		case s.remoteStatus != nil && bytes.Equal(s.remoteStatus.LastRemoteConfigHash, rc.ConfigHash) &&
+			s.remoteStatus.Status != opamppb.RemoteConfigStatuses_RemoteConfigStatuses_UNSET:
+			// Already applied (or refused); servers resend it on reconnect
+		case err != nil:
+			nodeLogger(s.nodeID).Warn("OpAMP remote config refused", "error", err)
+			s.remoteStatus = &opamppb.RemoteConfigStatus{
+				LastRemoteConfigHash: rc.ConfigHash,
+				Status:               opamppb.RemoteConfigStatuses_RemoteConfigStatuses_FAILED,
+				ErrorMessage:         err.Error(),
+			}
+			report = true
+		default:
+			s.remoteStatus = &opamppb.RemoteConfigStatus{
+				LastRemoteConfigHash: rc.ConfigHash,
+				Status:               opamppb.RemoteConfigStatuses_RemoteConfigStatuses_APPLYING,
+			}
+			s.pendingHash = configHash(body)
+			envs = append(envs, &controlpb.Envelope{
+				Body: &controlpb.Envelope_ConfigPush{
+					ConfigPush: &controlpb.ConfigPush{
+						DeviceId:   s.nodeID,
+						ConfigData: body,
+						ConfigHash: s.pendingHash,
+					},
+				},
+			})
+		}
+	}
+
+	if cmd := msg.GetCommand(); cmd != nil && cmd.Type == opamppb.CommandType_CommandType_Restart {
+		envs = append(envs, &controlpb.Envelope{
+			Body: &controlpb.Envelope_Command{Command: &controlpb.Command{Type: ActionRestartCollector}},
+		})
+	}
+
+	if cm := msg.GetCustomMessage(); cm != nil && cm.Capability == opampCapability && cm.Type == "Command" {
+		cmd := &controlpb.Command{}
+		if err := protojson.Unmarshal(cm.Data, cmd); err != nil {
+			nodeLogger(s.nodeID).Warn("Ignoring malformed OpAMP command", "error", err)
+		} else {
+			envs = append(envs, &controlpb.Envelope{Body: &controlpb.Envelope_Command{Command: cmd}})
+		}
+	}
+	return envs, report
+}
+
+// pickConfigFile selects the config body from a remote config map: the
+// unnamed entry, the entry named like --config-path, or the only entry.
+func (s *opampSession) pickConfigFile(m *opamppb.AgentConfigMap) ([]byte, error) {
+	files := m.GetConfigMap()
+	for _, name := range []string{"", s.configName} {
+		if f, ok := files[name]; ok {
+			return f.GetBody(), nil
+		}
+	}
+	if len(files) == 1 {
+		for _, f := range files {
+			return f.GetBody(), nil
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("remote config has %d files %q, expected one or one named %q", len(files), names, s.configName)
+}
+
+// agentDescription maps the device identity onto OpAMP attributes using the
+// OpenTelemetry semantic conventions.
+func agentDescription(id *controlpb.EdgeIdentity) *opamppb.AgentDescription {
+	serviceName := id.AgentType
+	if serviceName == "" {
+		serviceName = "opamp-device-agent"
+	}
+	desc := &opamppb.AgentDescription{
+		IdentifyingAttributes: []*opamppb.KeyValue{
+			stringAttr("service.name", serviceName),
+			stringAttr("service.instance.id", id.NodeId),
+			stringAttr("service.version", id.Version),
+		},
+	}
+	if osType, arch, ok := strings.Cut(id.Platform, "/"); ok {
+		desc.NonIdentifyingAttributes = append(desc.NonIdentifyingAttributes,
+			stringAttr("os.type", osType),
+			stringAttr("host.arch", arch),
+		)
+	}
+	if id.Hostname != "" {
+		desc.NonIdentifyingAttributes = append(desc.NonIdentifyingAttributes, stringAttr("host.name", id.Hostname))
+	}
+	if id.OsRelease != "" {
+		desc.NonIdentifyingAttributes = append(desc.NonIdentifyingAttributes, stringAttr("os.description", id.OsRelease))
+	}
+	if len(id.Capabilities) > 0 {
+		desc.NonIdentifyingAttributes = append(desc.NonIdentifyingAttributes,
+			stringAttr("agent.capabilities", strings.Join(id.Capabilities, ",")))
+	}
+	keys := make([]string, 0, len(id.Labels))
+	for k := range id.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		desc.NonIdentifyingAttributes = append(desc.NonIdentifyingAttributes, stringAttr(k, id.Labels[k]))
+	}
+	return desc
+}
+
+func stringAttr(key, value string) *opamppb.KeyValue {
+	return &opamppb.KeyValue{
+		Key:   key,
+		Value: &opamppb.AnyValue{Value: &opamppb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+// opampTransport speaks OpAMP to the server while presenting the agent with
+// the same Envelope stream as the Control service.
+type opampTransport struct {
+	stateNotifier
+	cfg     OpAMPConfig
+	tls     TLSConfig
+	session *opampSession
+}
+
+func newOpAMPTransport(nodeID, configPath, instanceUID string, cfg OpAMPConfig, tls TLSConfig) *opampTransport {
+	return &opampTransport{cfg: cfg, tls: tls, session: newOpAMPSession(nodeID, configPath, instanceUID)}
+}
+
+// opampStream is one connection to the OpAMP server.
+type opampStream struct {
+	streamState
+	session *opampSession
+	// write sends one message; over HTTP it also delivers the response
+	write func(*opamppb.AgentToServer) error
+	// closeTransport releases the connection once the stream fails
+	closeTransport func()
+	cancel         context.CancelFunc
+	onFail         func()
+
+	writeMu sync.Mutex
+	recv    chan *controlpb.Envelope
+	report  chan struct{}
+}
+
+// Connect connects to the OpAMP server over WebSocket or HTTP, depending on
+// the URL scheme.
+func (t *opampTransport) Connect(ctx context.Context) (Stream, error) {
+	t.notify(StateConnecting)
+	s, err := t.connect(ctx)
+	if err != nil {
+		t.notify(StateTransientFailure)
+		return nil, err
+	}
+	t.notify(StateReady)
+	return s, nil
+}
+
+func (t *opampTransport) connect(ctx context.Context) (*opampStream, error) {
+	url := t.cfg.ServerURL
+	secure := strings.HasPrefix(url, "wss://") || strings.HasPrefix(url, "https://")
+
+	var tlsCfg *tls.Config
+	if secure {
+		var err error
+		if tlsCfg, _, err = t.tls.clientConfig(); err != nil {
+			return nil, fmt.Errorf("failed to set up TLS: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &opampStream{
+		streamState: newStreamState(),
+		session:     t.session,
+		cancel:      cancel,
+		onFail:      func() { t.notify(StateTransientFailure) },
+		recv:        make(chan *controlpb.Envelope, 16),
+		report:      make(chan struct{}, 1),
+	}
+
+	var poll time.Duration
+	switch {
+	case strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://"):
+		dialer := websocket.Dialer{TLSClientConfig: tlsCfg, HandshakeTimeout: 10 * time.Second}
+		conn, _, err := dialer.DialContext(ctx, url, nil)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to connect to OpAMP server: %w", err)
+		}
+		s.write = func(msg *opamppb.AgentToServer) error {
+			data, err := proto.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			// Every WebSocket message starts with a varint header, currently 0
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			return conn.WriteMessage(websocket.BinaryMessage, append([]byte{0}, data...))
+		}
+		s.closeTransport = func() { conn.Close() }
+		go s.readWebSocket(conn)
+
+	case strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://"):
+		client := &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+		s.write = func(msg *opamppb.AgentToServer) error {
+			return s.postHTTP(ctx, client, url, msg)
+		}
+		s.closeTransport = client.CloseIdleConnections
+		poll = t.cfg.PollInterval
+
+	default:
+		cancel()
+		return nil, fmt.Errorf("unsupported OpAMP server URL %q (want ws://, wss://, http:// or https://)", url)
+	}
+
+	go s.run(ctx, poll)
+	return s, nil
+}
+
+// Close implements Transport. Each stream owns its connection, so there is
+// nothing left to release.
+func (t *opampTransport) Close() error {
+	t.notify(StateShutdown)
+	return nil
+}
+
+func (s *opampStream) Send(env *controlpb.Envelope) error {
+	msg, err := s.session.fromEnvelope(env)
+	if err != nil {
+		// Nothing the server could do with it; don't fail the stream
+		nodeLogger(s.session.nodeID).Debug("Not sending over OpAMP", "error", err)
+		return nil
+	}
+	return s.writeMsg(msg)
+}
+
+func (s *opampStream) Recv() <-chan *controlpb.Envelope { return s.recv }
+
+func (s *opampStream) Abort(err error) { s.failWith(err) }
+
+// Close tells the server the agent is going away, as the protocol asks.
+func (s *opampStream) Close() error {
+	select {
+	case <-s.done:
+	default:
+		s.writeMsg(&opamppb.AgentToServer{AgentDisconnect: &opamppb.AgentDisconnect{}})
+	}
+	if s.fail(errStreamClosed) {
+		s.cancel()
+		s.closeTransport()
+	}
+	return nil
+}
+
+func (s *opampStream) writeMsg(msg *opamppb.AgentToServer) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	select {
+	case <-s.done:
+		return s.err
+	default:
+	}
+	s.session.stamp(msg)
+	if err := s.write(msg); err != nil {
+		s.failWith(err)
+		return err
+	}
+	return nil
+}
+
+func (s *opampStream) failWith(err error) {
+	if s.fail(err) {
+		s.cancel()
+		s.closeTransport()
+		s.onFail()
+	}
+}
+
+// run sends status reports the server asked for and, when polling, the
+// periodic request that picks up pending server messages.
+func (s *opampStream) run(ctx context.Context, poll time.Duration) {
+	var tick <-chan time.Time
+	if poll > 0 {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		var msg *opamppb.AgentToServer
+		select {
+		case <-ctx.Done():
+			s.failWith(ctx.Err())
+			return
+		case <-s.done:
+			return
+		case <-tick:
+			msg = &opamppb.AgentToServer{}
+		case <-s.report:
+			msg = s.session.fullState()
+		}
+		if err := s.writeMsg(msg); err != nil {
+			return
+		}
+	}
+}
+
+// deliver hands a server message to the agent.
+func (s *opampStream) deliver(msg *opamppb.ServerToAgent) {
+	if e := msg.GetErrorResponse(); e != nil {
+		nodeLogger(s.session.nodeID).Warn("OpAMP server error", "type", e.Type.String(), "error", e.ErrorMessage)
+		if e.Type == opamppb.ServerErrorResponseType_ServerErrorResponseType_Unavailable {
+			s.failWith(fmt.Errorf("opamp server unavailable: %s", e.ErrorMessage))
+			return
+		}
+	}
+
+	envs, report := s.session.fromServer(msg)
+	for _, env := range envs {
+		select {
+		case s.recv <- env:
+		case <-s.done:
+			return
+		}
+	}
+	if report {
+		select {
+		case s.report <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *opampStream) readWebSocket(conn *websocket.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			s.failWith(err)
+			return
+		}
+		header, n := binary.Uvarint(data)
+		if n <= 0 || header != 0 {
+			s.failWith(fmt.Errorf("unexpected OpAMP message header %d", header))
+			return
+		}
+		msg := &opamppb.ServerToAgent{}
+		if err := proto.Unmarshal(data[n:], msg); err != nil {
+			s.failWith(fmt.Errorf("failed to decode ServerToAgent: %w", err))
+			return
+		}
+		s.deliver(msg)
+	}
+}
+
+func (s *opampStream) postHTTP(ctx context.Context, client *http.Client, url string, msg *opamppb.AgentToServer) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach OpAMP server: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOpAMPMessage))
+	if err != nil {
+		return fmt.Errorf("failed to read OpAMP response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpAMP server returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	reply := &opamppb.ServerToAgent{}
+	if err := proto.Unmarshal(body, reply); err != nil {
+		return fmt.Errorf("failed to decode ServerToAgent: %w", err)
+	}
+	s.deliver(reply)
+	return nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+	"local.dev/opamp-device-agent/api/opamppb"
+)
+
+// fakeOpAMPServer is an in-process OpAMP server stand-in. It speaks both
+// the WebSocket and the plain HTTP transport, records every AgentToServer
+// message and answers each one with whatever was queued via reply.
+type fakeOpAMPServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	received []*opamppb.AgentToServer
+	queued   []*opamppb.ServerToAgent
+	// push sends to the open WebSocket without waiting for the agent
+	push chan *opamppb.ServerToAgent
+}
+
+func newFakeOpAMPServer(t *testing.T) *fakeOpAMPServer {
+	t.Helper()
+	srv := &fakeOpAMPServer{push: make(chan *opamppb.ServerToAgent, 1)}
+	upgrader := websocket.Upgrader{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			srv.serveWebSocket(conn)
+			return
+		}
+
+		data, _ := io.ReadAll(r.Body)
+		msg := &opamppb.AgentToServer{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out, _ := proto.Marshal(srv.exchange(msg))
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (srv *fakeOpAMPServer) serveWebSocket(conn *websocket.Conn) {
+	defer conn.Close()
+	var writeMu sync.Mutex
+	write := func(msg *opamppb.ServerToAgent) {
+		data, _ := proto.Marshal(msg)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{0}, data...))
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case msg := <-srv.push:
+				write(msg)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if _, n := binary.Uvarint(data); n > 0 {
+			data = data[n:]
+		}
+		msg := &opamppb.AgentToServer{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return
+		}
+		write(srv.exchange(msg))
+	}
+}
+
+func (srv *fakeOpAMPServer) exchange(msg *opamppb.AgentToServer) *opamppb.ServerToAgent {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.received = append(srv.received, msg)
+	reply := &opamppb.ServerToAgent{InstanceUid: msg.InstanceUid}
+	if len(srv.queued) > 0 {
+		reply = srv.queued[0]
+		reply.InstanceUid = msg.InstanceUid
+		srv.queued = srv.queued[1:]
+	}
+	return reply
+}
+
+func (srv *fakeOpAMPServer) reply(msg *opamppb.ServerToAgent) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.queued = append(srv.queued, msg)
+}
+
+// find returns the first received message matching match.
+func (srv *fakeOpAMPServer) find(match func(*opamppb.AgentToServer) bool) *opamppb.AgentToServer {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, msg := range srv.received {
+		if match(msg) {
+			return msg
+		}
+	}
+	return nil
+}
+
+func remoteConfig(hash string, files map[string]string) *opamppb.ServerToAgent {
+	m := map[string]*opamppb.AgentConfigFile{}
+	for name, body := range files {
+		m[name] = &opamppb.AgentConfigFile{Body: []byte(body)}
+	}
+	return &opamppb.ServerToAgent{
+		RemoteConfig: &opamppb.AgentRemoteConfig{
+			Config:     &opamppb.AgentConfigMap{ConfigMap: m},
+			ConfigHash: []byte(hash),
+		},
+	}
+}
+
+func remoteStatusIs(hash string, status opamppb.RemoteConfigStatuses) func(*opamppb.AgentToServer) bool {
+	return func(msg *opamppb.AgentToServer) bool {
+		rs := msg.GetRemoteConfigStatus()
+		return rs != nil && string(rs.LastRemoteConfigHash) == hash && rs.Status == status
+	}
+}
+
+// TestOpAMPClient tests registration, remote config and custom commands over
+// both OpAMP transports
+func TestOpAMPClient(t *testing.T) {
+	for _, transport := range []string{"ws", "http"} {
+		t.Run(transport, func(t *testing.T) {
+			srv := newFakeOpAMPServer(t)
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, "fluent-bit.conf")
+			fb := newFakeFluentBit(t, configPath)
+			os.WriteFile(configPath, []byte("[OUTPUT]\n    Name null\n"), 0644)
+
+			url := transport + strings.TrimPrefix(srv.URL, "http")
+			a := NewDeviceAgent("unused", "device-1", "fluentbit", configPath, fb.URL+"/api/v2/reload", Options{
+				TLS:                TLSConfig{Insecure: true},
+				ReloadTimeout:      time.Second,
+				ReloadPollInterval: 10 * time.Millisecond,
+				MonitorInterval:    time.Hour,
+				OpAMP:              OpAMPConfig{ServerURL: url, PollInterval: 20 * time.Millisecond},
+			})
+
+			pushed := "[OUTPUT]\n    Name stdout\n"
+			srv.reply(remoteConfig("rc-1", map[string]string{"": pushed}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := a.Start(ctx); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer a.Stop()
+
+			waitFor(t, func() bool {
+				return srv.find(remoteStatusIs("rc-1", opamppb.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED)) != nil
+			})
+			if got, _ := os.ReadFile(configPath); string(got) != pushed {
+				t.Errorf("live config = %q, want %q", got, pushed)
+			}
+
+			first := srv.find(func(*opamppb.AgentToServer) bool { return true })
+			// The persisted instance UID, not one derived from the node ID
+			if len(first.InstanceUid) != 16 || formatUUID(first.InstanceUid) != a.instanceUID || first.SequenceNum != 1 {
+				t.Errorf("first message: uid %x, seq %d, want uid %s", first.InstanceUid, first.SequenceNum, a.instanceUID)
+			}
+			if first.Capabilities&uint64(opamppb.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig) == 0 {
+				t.Errorf("capabilities %#x do not include AcceptsRemoteConfig", first.Capabilities)
+			}
+			attrs := map[string]string{}
+			for _, kv := range first.GetAgentDescription().GetIdentifyingAttributes() {
+				attrs[kv.Key] = kv.Value.GetStringValue()
+			}
+			if attrs["service.instance.id"] != "device-1" || attrs["service.name"] != "fluentbit" {
+				t.Errorf("identifying attributes = %v", attrs)
+			}
+
+			effective := srv.find(func(msg *opamppb.AgentToServer) bool {
+				f := msg.GetEffectiveConfig().GetConfigMap().GetConfigMap()[""]
+				return f != nil && string(f.Body) == pushed
+			})
+			if effective == nil {
+				t.Error("applied config was never reported as effective")
+			}
+
+			// Commands without an OpAMP equivalent travel as custom messages
+			data, _ := protojson.Marshal(&controlpb.Command{Type: "FetchStatus", CorrelationId: "c-1"})
+			cmd := &opamppb.ServerToAgent{CustomMessage: &opamppb.CustomMessage{Capability: opampCapability, Type: "Command", Data: data}}
+			if transport == "ws" {
+				srv.push <- cmd
+			} else {
+				srv.reply(cmd)
+			}
+			waitFor(t, func() bool {
+				return srv.find(func(msg *opamppb.AgentToServer) bool {
+					cm := msg.GetCustomMessage()
+					return cm != nil && cm.Type == "Event" && bytes.Contains(cm.Data, []byte("StatusReport")) && bytes.Contains(cm.Data, []byte("c-1"))
+				}) != nil
+			})
+		})
+	}
+}
+
+// TestOpAMPSessionRemoteConfig tests remote config selection and status tracking
+func TestOpAMPSessionRemoteConfig(t *testing.T) {
+	s := newOpAMPSession("device-1", "/config/fluent-bit.conf", "")
+
+	envs, report := s.fromServer(remoteConfig("multi", map[string]string{"a.conf": "a", "b.conf": "b"}))
+	if len(envs) != 0 || !report || s.remoteStatus.Status != opamppb.RemoteConfigStatuses_RemoteConfigStatuses_FAILED {
+		t.Errorf("ambiguous config map: got %d envelopes, report=%v, status %v", len(envs), report, s.remoteStatus.Status)
+	}
+
+	envs, _ = s.fromServer(remoteConfig("named", map[string]string{"fluent-bit.conf": "main", "parsers.conf": "p"}))
+	if len(envs) != 1 || string(envs[0].GetConfigPush().ConfigData) != "main" {
+		t.Fatalf("named config: got %v", envs)
+	}
+
+	// A heartbeat while the push is applying leaves it pending
+	s.fromConfigAck(&controlpb.ConfigAck{Success: true, ConfigHash: configHash([]byte("old"))})
+	if s.remoteStatus.Status != opamppb.RemoteConfigStatuses_RemoteConfigStatuses_APPLYING {
+		t.Errorf("heartbeat settled the remote config: %v", s.remoteStatus.Status)
+	}
+	msg := s.fromConfigAck(&controlpb.ConfigAck{Success: true, ConfigHash: configHash([]byte("main")), EffectiveConfig: []byte("main")})
+	if msg.RemoteConfigStatus.GetStatus() != opamppb.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED || string(msg.RemoteConfigStatus.LastRemoteConfigHash) != "named" {
+		t.Errorf("ack did not apply the remote config: %v", msg.RemoteConfigStatus)
+	}
+
+	// Resending the applied config after a reconnect does not re-apply it
+	if envs, _ := s.fromServer(remoteConfig("named", map[string]string{"fluent-bit.conf": "main"})); len(envs) != 0 {
+		t.Errorf("applied config pushed again: %v", envs)
+	}
+}
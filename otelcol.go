@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// otelcolDriver manages an OpenTelemetry Collector directly, without a local
+// supervisor: configs are checked with "otelcol validate" and written over
+// the live YAML file. The collector picks them up on SIGHUP when it is the
+// agent's child or the process in PIDFile, and otherwise through its own
+// config watcher. Readiness comes from the health_check extension.
+type otelcolDriver struct {
+	nodeID       string
+	pidFile      string
+	healthURL    string
+	readyTimeout time.Duration
+	pollInterval time.Duration
+	validator    ConfigValidator
+	client       *http.Client
+	file         managedFile
+	child        *processSupervisor
+}
+
+func newOtelcolDriver(cfg DriverConfig) Driver {
+	binary := cfg.OtelcolBinary
+	if binary == "" {
+		binary = "otelcol"
+	}
+	d := &otelcolDriver{
+		nodeID:       cfg.NodeID,
+		pidFile:      cfg.PIDFile,
+		healthURL:    cfg.HealthEndpoint,
+		readyTimeout: cfg.ReloadTimeout,
+		pollInterval: cfg.ReloadPollInterval,
+		validator: &commandValidator{
+			argv:       []string{binary, "validate", "--config={config}"},
+			configPath: cfg.ConfigPath,
+			timeout:    cfg.ValidateTimeout,
+		},
+		client: &http.Client{Timeout: 2 * time.Second},
+		file:   managedFile{nodeID: cfg.NodeID, path: cfg.ConfigPath, lastGoodPath: cfg.LastGoodPath},
+		child:  cfg.Child,
+	}
+	if d.healthURL == "" {
+		d.healthURL = "http://localhost:13133/"
+	}
+	return d
+}
+
+func (d *otelcolDriver) Capabilities() []string {
+	caps := []string{
+		CapabilityApplyConfig,
+		CapabilityEffectiveConfig,
+		CapabilityHealth,
+		CapabilityReload,
+		CapabilityRollback,
+		CapabilityValidate,
+		CapabilityDriftDetection,
+	}
+	if d.canSignal() {
+		caps = append(caps, CapabilityRestart)
+	}
+	return caps
+}
+
+// errNoCollectorProcess is returned by Restart when there is no collector
+// process to restart.
+var errNoCollectorProcess = errors.New("no collector process to restart (run it with --exec or set --pid-file)")
+
+func (d *otelcolDriver) canSignal() bool {
+	return d.child != nil || d.pidFile != ""
+}
+
+// Apply validates config, writes it and reloads the collector, restoring the
+// last-known-good config if the collector does not come back ready on it.
+func (d *otelcolDriver) Apply(ctx context.Context, config []byte) (ApplyResult, error) {
+	// A config that cannot be loaded never touches the live file
+	if err := d.validator.Validate(ctx, config); err != nil {
+		return ApplyResult{}, err
+	}
+	// Taken before the write: a config watcher may reload right away
+	since := d.upSince(ctx)
+	rolledBack, err := d.file.apply(config, func() error {
+		var err error
+		since, err = d.reload(ctx, since)
+		return err
+	})
+	return ApplyResult{RolledBack: rolledBack}, err
+}
+
+func (d *otelcolDriver) EffectiveConfig(ctx context.Context) ([]byte, error) {
+	return d.file.read()
+}
+
+func (d *otelcolDriver) Health(ctx context.Context) DriverHealth {
+	health, err := d.ready(ctx)
+	if err != nil {
+		return DriverHealth{Status: err.Error()}
+	}
+	return DriverHealth{Healthy: true, Status: health.Status}
+}
+
+// Reload has the collector load the live config again and waits for it to
+// come back ready. With no process to signal the file is rewritten for the
+// collector's config watcher.
+func (d *otelcolDriver) Reload(ctx context.Context) error {
+	since := d.upSince(ctx)
+	if !d.canSignal() {
+		config, err := d.file.read()
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(d.file.path, config, 0644); err != nil {
+			return fmt.Errorf("failed to rewrite config: %w", err)
+		}
+	}
+	_, err := d.reload(ctx, since)
+	return err
+}
+
+// reload sends SIGHUP to the collector, if there is a process to signal, and
+// waits for it to come back ready after since. It returns the start time to
+// wait past on the next reload.
+func (d *otelcolDriver) reload(ctx context.Context, since string) (string, error) {
+	switch {
+	case d.child != nil:
+		nodeLogger(d.nodeID).Info("Signalling collector", "signal", syscall.SIGHUP)
+		if err := d.child.Signal(syscall.SIGHUP); err != nil {
+			return since, err
+		}
+	case d.pidFile != "":
+		proc, err := findProcess(d.pidFile)
+		if err != nil {
+			return since, err
+		}
+		nodeLogger(d.nodeID).Info("Signalling collector", "signal", syscall.SIGHUP, "pid", proc.Pid)
+		if err := proc.Signal(syscall.SIGHUP); err != nil {
+			return since, fmt.Errorf("failed to signal collector process %d: %w", proc.Pid, err)
+		}
+	default:
+		nodeLogger(d.nodeID).Info("Waiting for the collector's config watcher")
+	}
+	return d.waitReady(ctx, since)
+}
+
+// Restart restarts the child, or reloads a collector the agent did not start.
+func (d *otelcolDriver) Restart(ctx context.Context) error {
+	switch {
+	case d.child != nil:
+	case d.pidFile != "":
+		return d.Reload(ctx)
+	default:
+		return errNoCollectorProcess
+	}
+	since := d.upSince(ctx)
+	if err := d.child.Restart(ctx); err != nil {
+		return err
+	}
+	_, err := d.waitReady(ctx, since)
+	return err
+}
+
+// upSince is the start time the health_check extension reports, or "" while
+// the collector is not ready.
+func (d *otelcolDriver) upSince(ctx context.Context) string {
+	health, err := d.ready(ctx)
+	if err != nil {
+		return ""
+	}
+	return health.UpSince
+}
+
+// waitReady polls the health_check extension until the collector is ready
+// on a new start time, or ready again after being seen down. Until then the
+// old pipelines are still the ones answering. It returns the start time to
+// wait past on the next reload: "" once the collector has been seen down.
+// A health_check that reports no start time is trusted on its first ready.
+func (d *otelcolDriver) waitReady(ctx context.Context, since string) (string, error) {
+	deadline := time.Now().Add(d.readyTimeout)
+	down := since == ""
+	for {
+		select {
+		case <-ctx.Done():
+			return since, ctx.Err()
+		case <-time.After(d.pollInterval):
+		}
+		health, err := d.ready(ctx)
+		switch {
+		case err != nil:
+			down, since = true, ""
+		case down || health.UpSince != since:
+			nodeLogger(d.nodeID).Info("Collector ready", "status", health.Status, "up_since", health.UpSince)
+			return health.UpSince, nil
+		default:
+			err = fmt.Errorf("still running the previous config (up since %s)", since)
+		}
+		if time.Now().After(deadline) {
+			return since, fmt.Errorf("collector not ready within %s: %v", d.readyTimeout, err)
+		}
+	}
+}
+
+func (d *otelcolDriver) Metrics(ctx context.Context) (map[string]float64, error) {
+	return nil, errNotSupported
+}
+
+// otelcolHealth is the health_check extension's answer. UpSince changes
+// whenever the collector restarts its pipelines, including on a reload.
+type otelcolHealth struct {
+	Status  string `json:"status"`
+	UpSince string `json:"upSince"`
+}
+
+// ready queries the health_check extension, which answers 200 once every
+// pipeline is running and 503 while the collector starts or reloads.
+func (d *otelcolDriver) ready(ctx context.Context) (otelcolHealth, error) {
+	var health otelcolHealth
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.healthURL, nil)
+	if err != nil {
+		return health, fmt.Errorf("failed to build health request: %w", err)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return health, fmt.Errorf("health_check unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	json.Unmarshal(body, &health)
+	if resp.StatusCode != http.StatusOK {
+		return health, fmt.Errorf("collector not ready: HTTP %d %s", resp.StatusCode, health.Status)
+	}
+	if health.Status == "" {
+		health.Status = "ready"
+	}
+	return health, nil
+}
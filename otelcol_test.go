@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestOtelcolDriver returns an otelcol driver backed by a fake collector:
+// "otelcol validate" fails for configs containing BROKEN, and the
+// health_check endpoint reports unavailable while the loaded config contains
+// UNHEALTHY. The fake reloads whenever the config file is replaced, moving
+// upSince on, but ignores configs containing STALE.
+func newTestOtelcolDriver(t *testing.T, pidFile string) *otelcolDriver {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake collector is a shell script")
+	}
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	binary := filepath.Join(dir, "otelcol")
+	script := "#!/bin/sh\n" +
+		"cfg=${2#--config=}\n" +
+		"if grep -q BROKEN \"$cfg\"; then echo \"Error: invalid configuration: line 2: unknown receiver\" >&2; exit 1; fi\n"
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var seen time.Time
+	var loaded string
+	upSince := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fi, err := os.Stat(configPath); err == nil && !fi.ModTime().Equal(seen) {
+			seen = fi.ModTime()
+			if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "STALE") {
+				loaded = string(data)
+				upSince = upSince.Add(time.Second)
+			}
+		}
+		if strings.Contains(loaded, "UNHEALTHY") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"status":"Server not available"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"Server available","upSince":%q}`, upSince.Format(time.RFC3339))
+	}))
+	t.Cleanup(health.Close)
+
+	return newOtelcolDriver(DriverConfig{
+		NodeID:             "device-1",
+		ConfigPath:         configPath,
+		LastGoodPath:       filepath.Join(dir, "state", "config.yaml.last-good"),
+		PIDFile:            pidFile,
+		OtelcolBinary:      binary,
+		ValidateTimeout:    5 * time.Second,
+		HealthEndpoint:     health.URL,
+		ReloadTimeout:      200 * time.Millisecond,
+		ReloadPollInterval: 10 * time.Millisecond,
+	}).(*otelcolDriver)
+}
+
+// selfPIDFile writes a pid file naming the test process and catches the
+// SIGHUPs that reloads send it.
+func selfPIDFile(t *testing.T) (string, <-chan os.Signal) {
+	t.Helper()
+	pidFile := filepath.Join(t.TempDir(), "otelcol.pid")
+	os.WriteFile(pidFile, []byte(fmt.Sprint(os.Getpid())), 0644)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	t.Cleanup(func() { signal.Stop(hup) })
+	return pidFile, hup
+}
+
+// TestOtelcolDriverApply tests validation, readiness and rollback
+func TestOtelcolDriverApply(t *testing.T) {
+	pidFile, _ := selfPIDFile(t)
+	d := newTestOtelcolDriver(t, pidFile)
+	ctx := context.Background()
+	good := []byte("receivers:\n  otlp: {}\n")
+
+	if _, err := d.Apply(ctx, good); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got, _ := d.EffectiveConfig(ctx); string(got) != string(good) {
+		t.Errorf("effective config = %q, want %q", got, good)
+	}
+	if h := d.Health(ctx); !h.Healthy || h.Status != "Server available" {
+		t.Errorf("health = %+v", h)
+	}
+
+	// Rejected by otelcol validate: the live file is never touched
+	_, err := d.Apply(ctx, []byte("receivers:\n  BROKEN: {}\n"))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Problems[0].Line != 2 {
+		t.Errorf("invalid config: got %v, want a validation error on line 2", err)
+	}
+	if got, _ := d.EffectiveConfig(ctx); string(got) != string(good) {
+		t.Errorf("invalid config was written: %q", got)
+	}
+
+	// Valid, but the collector never becomes ready on it
+	result, err := d.Apply(ctx, []byte("receivers:\n  UNHEALTHY: {}\n"))
+	if err == nil || !result.RolledBack || !strings.Contains(err.Error(), "not ready") {
+		t.Errorf("unhealthy config: rolled back %v, err %v", result.RolledBack, err)
+	}
+	if got, _ := d.EffectiveConfig(ctx); string(got) != string(good) {
+		t.Errorf("live config after rollback = %q, want %q", got, good)
+	}
+}
+
+// TestOtelcolDriverSignalsCollector tests that a reload sends SIGHUP to the collector in the pid file
+func TestOtelcolDriverSignalsCollector(t *testing.T) {
+	pidFile, hup := selfPIDFile(t)
+	d := newTestOtelcolDriver(t, pidFile)
+
+	if _, err := d.Apply(context.Background(), []byte("receivers: {}\n")); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	select {
+	case <-hup:
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector was not sent SIGHUP")
+	}
+}
+
+// TestOtelcolDriverConfigWatcher tests that without a child or pid file
+// configs are only acked once the collector's config watcher has reloaded
+func TestOtelcolDriverConfigWatcher(t *testing.T) {
+	d := newTestOtelcolDriver(t, "")
+	ctx := context.Background()
+	good := []byte("receivers: {}\n")
+
+	if _, err := d.Apply(ctx, good); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := d.Reload(ctx); err != nil {
+		t.Errorf("Reload: %v", err)
+	}
+
+	// Ready all along, but on the old config: not applied
+	result, err := d.Apply(ctx, []byte("receivers:\n  STALE: {}\n"))
+	if err == nil || !result.RolledBack || !strings.Contains(err.Error(), "still running the previous config") {
+		t.Errorf("ignored config: rolled back %v, err %v", result.RolledBack, err)
+	}
+	if got, _ := d.EffectiveConfig(ctx); string(got) != string(good) {
+		t.Errorf("live config after rollback = %q, want %q", got, good)
+	}
+
+	if err := d.Restart(ctx); !errors.Is(err, errNoCollectorProcess) || hasCapability(d, CapabilityRestart) {
+		t.Errorf("Restart = %v, want %v and no restart capability", err, errNoCollectorProcess)
+	}
+	for _, c := range []string{CapabilityReload, CapabilityRollback} {
+		if !hasCapability(d, c) {
+			t.Errorf("capability %s not advertised", c)
+		}
+	}
+}
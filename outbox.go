@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+const outboxSuffix = ".pb"
+
+type outboxEntry struct {
+	seq uint64
+	key string
+}
+
+// outbox persists envelopes that could not be delivered to the supervisor so
+// they survive both stream outages and agent restarts. Each envelope is one
+// file named by its sequence number, which gives replay order.
+type outbox struct {
+	nodeID string
+	dir    string
+	max    int
+
+	mu      sync.Mutex
+	entries []outboxEntry
+	nextSeq uint64
+	dropped atomic.Uint64
+}
+
+// openOutbox loads any envelopes left over from a previous run.
+func openOutbox(nodeID, dir string, max int) (*outbox, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox dir: %w", err)
+	}
+	o := &outbox{nodeID: nodeID, dir: dir, max: max, nextSeq: 1}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox dir: %w", err)
+	}
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, outboxSuffix) {
+			// Leftover temp file from an interrupted write
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, outboxSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		env, err := o.load(seq)
+		if err != nil {
+			os.Remove(o.path(seq))
+			continue
+		}
+		o.entries = append(o.entries, outboxEntry{seq: seq, key: dedupKey(env)})
+		if seq >= o.nextSeq {
+			o.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(o.entries, func(i, j int) bool { return o.entries[i].seq < o.entries[j].seq })
+	return o, nil
+}
+
+// dedupKey identifies envelopes that supersede each other: acks for the same
+// push reporting the same config hash and events answering the same
+// correlation id. Acks for different pushes are all kept, since a rejected
+// push reports the hash of the config that was already running.
+func dedupKey(env *controlpb.Envelope) string {
+	switch body := env.Body.(type) {
+	case *controlpb.Envelope_ConfigAck:
+		if h := body.ConfigAck.GetConfigHash(); h != "" {
+			if id := body.ConfigAck.GetCorrelationId(); id != "" {
+				return "ack/" + h + "/" + id
+			}
+			return "ack/" + h
+		}
+	case *controlpb.Envelope_Event:
+		if id := body.Event.GetCorrelationId(); id != "" {
+			return "event/" + body.Event.GetType() + "/" + id
+		}
+	}
+	return ""
+}
+
+func (o *outbox) path(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", seq, outboxSuffix))
+}
+
+func (o *outbox) load(seq uint64) (*controlpb.Envelope, error) {
+	data, err := os.ReadFile(o.path(seq))
+	if err != nil {
+		return nil, err
+	}
+	env := &controlpb.Envelope{}
+	if err := proto.Unmarshal(data, env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// add persists an envelope at the tail. An older entry with the same dedup
+// key is replaced, and the oldest entries are dropped once the cap is hit.
+func (o *outbox) add(env *controlpb.Envelope) error {
+	data, err := proto.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	seq := o.nextSeq
+	o.nextSeq++
+	tmp := o.path(seq) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+	if err := os.Rename(tmp, o.path(seq)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to commit outbox entry: %w", err)
+	}
+
+	key := dedupKey(env)
+	if key != "" {
+		for i, e := range o.entries {
+			if e.key == key {
+				os.Remove(o.path(e.seq))
+				o.entries = append(o.entries[:i], o.entries[i+1:]...)
+				break
+			}
+		}
+	}
+	o.entries = append(o.entries, outboxEntry{seq: seq, key: key})
+
+	for o.max > 0 && len(o.entries) > o.max {
+		os.Remove(o.path(o.entries[0].seq))
+		o.entries = o.entries[1:]
+		dropped := o.dropped.Add(1)
+		nodeLogger(o.nodeID).Warn("Outbox full, dropped oldest message", "max", o.max, "dropped_total", dropped)
+	}
+	return nil
+}
+
+// replay sends the persisted envelopes in order, removing each one once it
+// has been handed to send. It stops at the first send error.
+func (o *outbox) replay(send func(*controlpb.Envelope) error) (int, error) {
+	o.mu.Lock()
+	pending := append([]outboxEntry(nil), o.entries...)
+	o.mu.Unlock()
+
+	sent := 0
+	for _, e := range pending {
+		env, err := o.load(e.seq)
+		if err != nil {
+			// Superseded or dropped since the snapshot was taken
+			continue
+		}
+		if err := send(env); err != nil {
+			return sent, err
+		}
+		o.remove(e.seq)
+		sent++
+	}
+	return sent, nil
+}
+
+func (o *outbox) remove(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, e := range o.entries {
+		if e.seq == seq {
+			o.entries = append(o.entries[:i], o.entries[i+1:]...)
+			break
+		}
+	}
+	os.Remove(o.path(seq))
+}
+
+// Len returns the number of envelopes waiting for delivery.
+func (o *outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// Dropped returns how many envelopes were discarded because the outbox was full.
+func (o *outbox) Dropped() uint64 {
+	return o.dropped.Load()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+func eventWithCorrelation(typ, id string) *controlpb.Envelope {
+	return &controlpb.Envelope{Body: &controlpb.Envelope_Event{Event: &controlpb.Event{Type: typ, CorrelationId: id}}}
+}
+
+func replayAll(t *testing.T, o *outbox) []*controlpb.Envelope {
+	t.Helper()
+	var got []*controlpb.Envelope
+	if _, err := o.replay(func(env *controlpb.Envelope) error {
+		got = append(got, env)
+		return nil
+	}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return got
+}
+
+// TestOutboxPersistsAcrossReopen tests ordering and durability across restarts
+func TestOutboxPersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	o, err := openOutbox("device-1", dir, 10)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, h := range []string{"a", "b", "c"} {
+		if err := o.add(ackEnvelope(h)); err != nil {
+			t.Fatalf("add %s: %v", h, err)
+		}
+	}
+
+	reopened, err := openOutbox("device-1", dir, 10)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	got := replayAll(t, reopened)
+	if len(got) != 3 {
+		t.Fatalf("replayed %d envelopes, want 3", len(got))
+	}
+	for i, h := range []string{"a", "b", "c"} {
+		if got[i].GetConfigAck().GetConfigHash() != h {
+			t.Errorf("entry %d = %q, want %q", i, got[i].GetConfigAck().GetConfigHash(), h)
+		}
+	}
+	if reopened.Len() != 0 {
+		t.Errorf("outbox has %d entries after replay, want 0", reopened.Len())
+	}
+}
+
+// TestOutboxDeduplicates tests that a newer ack or event replaces the older one
+func TestOutboxDeduplicates(t *testing.T) {
+	o, err := openOutbox("device-1", t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	o.add(ackEnvelope("h1"))
+	o.add(eventWithCorrelation("StatusReport", "c1"))
+	o.add(ackEnvelope("h2"))
+	o.add(ackEnvelope("h1"))
+	o.add(eventWithCorrelation("StatusReport", "c1"))
+	o.add(eventWithCorrelation("RebootAcknowledged", "c1"))
+
+	got := replayAll(t, o)
+	want := []string{"ack/h2", "ack/h1", "event/StatusReport/c1", "event/RebootAcknowledged/c1"}
+	if len(got) != len(want) {
+		t.Fatalf("replayed %d envelopes, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if key := dedupKey(got[i]); key != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, key, want[i])
+		}
+	}
+}
+
+// TestOutboxKeepsAcksForDifferentPushes tests that a rejection reporting the
+// running config's hash does not replace the ack of the push that applied it
+func TestOutboxKeepsAcksForDifferentPushes(t *testing.T) {
+	o, err := openOutbox("device-1", t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ack := func(hash, correlationID string, success bool) *controlpb.Envelope {
+		return &controlpb.Envelope{Body: &controlpb.Envelope_ConfigAck{ConfigAck: &controlpb.ConfigAck{
+			ConfigHash: hash, CorrelationId: correlationID, Success: success,
+		}}}
+	}
+	o.add(ack("h1", "h1", true))
+	o.add(ack("h1", "h2", false))
+	o.add(ack("h1", "h2", false))
+
+	got := replayAll(t, o)
+	if len(got) != 2 {
+		t.Fatalf("replayed %d acks, want 2", len(got))
+	}
+	if first := got[0].GetConfigAck(); !first.GetSuccess() || first.GetCorrelationId() != "h1" {
+		t.Errorf("success ack for h1 was lost, got %v", first)
+	}
+	if second := got[1].GetConfigAck(); second.GetSuccess() || second.GetCorrelationId() != "h2" {
+		t.Errorf("second ack = %v, want the rejection of h2", second)
+	}
+}
+
+// TestOutboxDropsOldest tests the size cap and dropped counter
+func TestOutboxDropsOldest(t *testing.T) {
+	o, err := openOutbox("device-1", t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, h := range []string{"a", "b", "c", "d"} {
+		o.add(ackEnvelope(h))
+	}
+	if o.Dropped() != 2 {
+		t.Errorf("dropped = %d, want 2", o.Dropped())
+	}
+	got := replayAll(t, o)
+	if len(got) != 2 || got[0].GetConfigAck().GetConfigHash() != "c" {
+		t.Errorf("got %v, want [c d]", got)
+	}
+}
+
+// TestOutboxReplayStopsOnError tests that unsent entries survive a failed replay
+func TestOutboxReplayStopsOnError(t *testing.T) {
+	o, err := openOutbox("device-1", t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	o.add(ackEnvelope("a"))
+	o.add(ackEnvelope("b"))
+
+	calls := 0
+	sent, err := o.replay(func(*controlpb.Envelope) error {
+		calls++
+		if calls == 2 {
+			return errors.New("stream broken")
+		}
+		return nil
+	})
+	if err == nil || sent != 1 {
+		t.Errorf("replay: sent=%d err=%v, want 1 and an error", sent, err)
+	}
+	if o.Len() != 1 {
+		t.Errorf("outbox has %d entries, want 1", o.Len())
+	}
+}
+
+// TestSenderReplaysOutboxAfterRegister tests the disconnected path end to end:
+// durable messages produced with no stream are replayed once the registration
+// is answered, with typed bodies if the server negotiated them
+func TestSenderReplaysOutboxAfterRegister(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	o, err := openOutbox("device-1", t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	s := newSender("device-1", 4, o)
+	var typed atomic.Bool
+	s.typedEvents = typed.Load
+	go s.run(ctx)
+
+	status := eventWithCorrelation("StatusReport", "c1")
+	status.GetEvent().Body = &controlpb.Event_Status{Status: &controlpb.StatusReport{DeviceId: "device-1"}}
+	s.enqueue(ackEnvelope("h1"), PriorityHigh, true)
+	s.enqueue(status, PriorityNormal, true)
+	s.enqueue(eventEnvelope(0), PriorityNormal, false)
+	waitFor(t, func() bool { return o.Len() == 2 })
+
+	stream := newFakeStream()
+	greeting := &controlpb.Envelope{Body: &controlpb.Envelope_Register{Register: &controlpb.EdgeIdentity{NodeId: "device-1"}}}
+	if err := s.attach(ctx, stream, greeting); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(stream.Sent()); n != 1 {
+		t.Fatalf("sent %d envelopes before the registration was answered, want only the greeting", n)
+	}
+
+	typed.Store(true)
+	s.replayOutbox()
+	waitFor(t, func() bool { return len(stream.Sent()) == 3 })
+	sent := stream.Sent()
+	if sent[0].GetRegister() == nil || sent[1].GetConfigAck().GetConfigHash() != "h1" || sent[2].GetEvent().GetCorrelationId() != "c1" {
+		t.Errorf("unexpected send order: %v", sent)
+	}
+	if sent[2].GetEvent().GetStatus().GetDeviceId() != "device-1" {
+		t.Errorf("replayed event lost its typed body: %v", sent[2])
+	}
+	if o.Len() != 0 {
+		t.Errorf("outbox has %d entries after replay, want 0", o.Len())
+	}
+}
@@ -413,6 +413,7 @@ func (p *Provisioner) createDeviceAgentDeployment(ctx context.Context, deviceNam
 								"--agent-type=fluentbit",
 								"--config-path=/shared-config/fluent-bit.conf",
 								"--reload-endpoint=http://fluentbit-" + deviceName + "." + EdgeNamespace + ".svc.cluster.local:2020/api/v2/reload",
+								"--insecure", // POC supervisor serves plaintext gRPC
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{Name: "shared-config", MountPath: "/shared-config"},
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// childLogLines is how many lines of collector output are kept
+	childLogLines = 1000
+	// childStableAfter is how long a child must run before a crash no longer
+	// counts towards the crash-loop backoff
+	childStableAfter = 30 * time.Second
+	// childStopGrace is how long a child gets to exit after SIGTERM
+	childStopGrace = 10 * time.Second
+	// childExitHistory is how many exits are kept for FetchStatus
+	childExitHistory = 10
+	// maxChildLine splits runaway output that never ends a line
+	maxChildLine = 64 << 10
+)
+
+// childBackoff paces restarts of a crashing child.
+var childBackoff = BackoffPolicy{Initial: time.Second, Max: time.Minute, Multiplier: 2, Jitter: 0.2}
+
+// childExit records how a child process ended.
+type childExit struct {
+	PID  int    `json:"pid"`
+	Code int    `json:"exit_code"`
+	Err  string `json:"error,omitempty"`
+	// Restart is set when the agent stopped the child to restart it
+	Restart bool      `json:"restart"`
+	At      time.Time `json:"at"`
+	Uptime  string    `json:"uptime"`
+}
+
+// childStatus is reported by FetchStatus.
+type childStatus struct {
+	Command   string      `json:"command"`
+	PID       int         `json:"pid"`
+	Running   bool        `json:"running"`
+	StartedAt *time.Time  `json:"started_at,omitempty"`
+	Restarts  int         `json:"restarts"`
+	Exits     []childExit `json:"exits"`
+}
+
+// processSupervisor runs the collector as a child process (--exec), keeps
+// its output in a ring buffer and restarts it when it exits, backing off
+// while it keeps crashing.
+type processSupervisor struct {
+	nodeID string
+	argv   []string
+	output *lineRing
+	// backoff paces restarts; tests shorten it
+	backoff BackoffPolicy
+	done    chan struct{}
+	// kick cuts a crash-loop backoff short
+	kick chan struct{}
+
+	mu  sync.Mutex
+	cmd *exec.Cmd
+	// started is closed when the next child starts, exited when the current one exits
+	started   chan struct{}
+	exited    chan struct{}
+	startedAt time.Time
+	stop      context.CancelFunc
+	restart   bool
+	restarts  int
+	exits     []childExit
+}
+
+func newProcessSupervisor(nodeID string, argv []string) *processSupervisor {
+	return &processSupervisor{
+		nodeID:  nodeID,
+		argv:    argv,
+		output:  newLineRing(childLogLines),
+		backoff: childBackoff,
+		done:    make(chan struct{}),
+		kick:    make(chan struct{}, 1),
+		started: make(chan struct{}),
+	}
+}
+
+// run keeps the child running until ctx is done, then stops it.
+func (p *processSupervisor) run(ctx context.Context) {
+	defer close(p.done)
+	crashes := 0
+	for {
+		began := time.Now()
+		exit, err := p.runOnce(ctx)
+		if err != nil {
+			nodeLogger(p.nodeID).Error("Failed to start collector", "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.mu.Lock()
+		p.restarts++
+		p.mu.Unlock()
+		if exit.Restart {
+			crashes = 0
+			continue
+		}
+		if time.Since(began) >= childStableAfter {
+			crashes = 0
+		}
+		delay := p.backoff.Delay(crashes, nil)
+		crashes++
+		nodeLogger(p.nodeID).Warn("Collector exited, restarting", "exit_code", exit.Code, "delay", delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		case <-p.kick:
+		}
+	}
+}
+
+// runOnce starts the child and waits for it to exit.
+func (p *processSupervisor) runOnce(ctx context.Context) (childExit, error) {
+	cctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	cmd := exec.CommandContext(cctx, p.argv[0], p.argv[1:]...)
+	cmd.Stdout = p.output
+	cmd.Stderr = p.output
+	// Ask nicely first; WaitDelay kills the child if it ignores SIGTERM
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = childStopGrace
+	if err := cmd.Start(); err != nil {
+		p.recordExit(childExit{PID: 0, Code: -1, Err: err.Error(), At: time.Now(), Uptime: "0s"})
+		return childExit{Code: -1}, err
+	}
+
+	p.mu.Lock()
+	p.cmd, p.stop, p.restart = cmd, stop, false
+	p.startedAt = time.Now()
+	p.exited = make(chan struct{})
+	close(p.started)
+	p.mu.Unlock()
+	nodeLogger(p.nodeID).Info("Started collector", "pid", cmd.Process.Pid, "command", strings.Join(p.argv, " "))
+
+	err := cmd.Wait()
+
+	p.mu.Lock()
+	exit := childExit{
+		PID:     cmd.Process.Pid,
+		Code:    cmd.ProcessState.ExitCode(),
+		Restart: p.restart,
+		At:      time.Now(),
+		Uptime:  time.Since(p.startedAt).Round(time.Millisecond).String(),
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		exit.Err = err.Error()
+	} else if err != nil {
+		exit.Err = cmd.ProcessState.String()
+	}
+	p.cmd = nil
+	p.started = make(chan struct{})
+	exited := p.exited
+	p.mu.Unlock()
+	p.recordExit(exit)
+	close(exited)
+	return exit, nil
+}
+
+func (p *processSupervisor) recordExit(exit childExit) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.exits = append(p.exits, exit)
+	if len(p.exits) > childExitHistory {
+		p.exits = p.exits[len(p.exits)-childExitHistory:]
+	}
+}
+
+// Restart stops the child, or ends the crash-loop backoff if it is not
+// running, and waits until a new child has started.
+func (p *processSupervisor) Restart(ctx context.Context) error {
+	p.mu.Lock()
+	if p.cmd != nil {
+		pid, exited := p.cmd.Process.Pid, p.exited
+		p.restart = true
+		p.stop()
+		p.mu.Unlock()
+		nodeLogger(p.nodeID).Info("Restarting collector", "pid", pid)
+		select {
+		case <-exited:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	} else {
+		p.mu.Unlock()
+		nodeLogger(p.nodeID).Info("Starting collector now instead of waiting out the backoff")
+		select {
+		case p.kick <- struct{}{}:
+		default:
+		}
+	}
+
+	p.mu.Lock()
+	started := p.started
+	p.mu.Unlock()
+	select {
+	case <-started:
+		return nil
+	case <-p.done:
+		return errors.New("collector supervision stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Signal sends sig to the running child.
+func (p *processSupervisor) Signal(sig os.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return errors.New("collector is not running")
+	}
+	if err := p.cmd.Process.Signal(sig); err != nil {
+		return fmt.Errorf("failed to signal collector pid %d: %w", p.cmd.Process.Pid, err)
+	}
+	return nil
+}
+
+// Running reports whether a child is currently running.
+func (p *processSupervisor) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cmd != nil
+}
+
+// Done is closed once supervision has stopped and the child has exited.
+func (p *processSupervisor) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *processSupervisor) status() childStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := childStatus{
+		Command:  strings.Join(p.argv, " "),
+		Restarts: p.restarts,
+		Exits:    append([]childExit{}, p.exits...),
+	}
+	if p.cmd != nil {
+		startedAt := p.startedAt
+		st.PID, st.Running, st.StartedAt = p.cmd.Process.Pid, true, &startedAt
+	}
+	return st
+}
+
+// lineRing keeps the last lines written to it, with the time each line
+// was completed. Partial lines are held until their newline arrives.
+type lineRing struct {
+	mu      sync.Mutex
+	lines   []ringLine
+	next    int
+	full    bool
+	partial []byte
+}
+
+// ringLine is one line kept by a lineRing.
+type ringLine struct {
+	At   time.Time
+	Text string
+}
+
+func newLineRing(size int) *lineRing {
+	return &lineRing{lines: make([]ringLine, size)}
+}
+
+func (r *lineRing) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	data := append(r.partial, b...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.addSplit(now, data[:i])
+		data = data[i+1:]
+	}
+	// Runaway output is kept in maxChildLine pieces, as readLogFile does
+	for len(data) >= maxChildLine {
+		r.add(now, string(data[:maxChildLine]))
+		data = data[maxChildLine:]
+	}
+	r.partial = append([]byte(nil), data...)
+	return len(b), nil
+}
+
+// addSplit adds a complete line, in maxChildLine pieces if it is longer.
+func (r *lineRing) addSplit(at time.Time, line []byte) {
+	for len(line) > maxChildLine {
+		r.add(at, string(line[:maxChildLine]))
+		line = line[maxChildLine:]
+	}
+	r.add(at, string(line))
+}
+
+func (r *lineRing) add(at time.Time, line string) {
+	r.lines[r.next] = ringLine{At: at, Text: line}
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns every line held, oldest first.
+func (r *lineRing) Lines() []ringLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []ringLine
+	if r.full {
+		all = append(all, r.lines[r.next:]...)
+	}
+	return append(all, r.lines[:r.next]...)
+}
+
+// Tail returns up to n of the most recent lines, oldest first (n <= 0 = all).
+func (r *lineRing) Tail(n int) []string {
+	all := r.Lines()
+	if n > 0 && len(all) > n {
+		all = all[len(all)-n:]
+	}
+	lines := make([]string, len(all))
+	for i, l := range all {
+		lines[i] = l.Text
+	}
+	return lines
+}
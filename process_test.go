@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+func newTestProcessSupervisor(t *testing.T, script string) *processSupervisor {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the test child is a shell script")
+	}
+	p := newProcessSupervisor("device-1", []string{"/bin/sh", "-c", script})
+	p.backoff = BackoffPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 1}
+	return p
+}
+
+// TestProcessSupervisorRestartsCrashes tests crash-loop restarts, exit codes and output capture
+func TestProcessSupervisorRestartsCrashes(t *testing.T) {
+	p := newTestProcessSupervisor(t, "echo starting; echo failed >&2; exit 3")
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.run(ctx)
+
+	waitFor(t, func() bool { return p.status().Restarts >= 2 })
+	cancel()
+	<-p.Done()
+
+	st := p.status()
+	for _, exit := range st.Exits {
+		if exit.Code != 3 || exit.Restart || exit.PID == 0 {
+			t.Errorf("unexpected exit %+v", exit)
+		}
+	}
+	if tail := p.output.Tail(2); !reflect.DeepEqual(tail, []string{"starting", "failed"}) {
+		t.Errorf("output tail = %q", tail)
+	}
+}
+
+// TestProcessSupervisorRestart tests an agent-initiated restart and shutdown
+func TestProcessSupervisorRestart(t *testing.T) {
+	p := newTestProcessSupervisor(t, "exec sleep 30")
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.run(ctx)
+
+	waitFor(t, func() bool { return p.status().Running })
+	first := p.status().PID
+	if err := p.Restart(ctx); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	st := p.status()
+	if !st.Running || st.PID == first || st.Restarts != 1 {
+		t.Errorf("after restart: %+v, first pid %d", st, first)
+	}
+	if len(st.Exits) != 1 || !st.Exits[0].Restart {
+		t.Errorf("exits = %+v, want one restart", st.Exits)
+	}
+
+	cancel()
+	select {
+	case <-p.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("child not stopped on shutdown")
+	}
+	if p.status().Running {
+		t.Error("child still reported running")
+	}
+}
+
+// TestLineRing tests line splitting and wraparound
+func TestLineRing(t *testing.T) {
+	r := newLineRing(3)
+	r.Write([]byte("one\ntw"))
+	r.Write([]byte("o\nthree\nfour\nfi"))
+	if got := r.Tail(0); !reflect.DeepEqual(got, []string{"two", "three", "four"}) {
+		t.Errorf("Tail(0) = %q", got)
+	}
+	if got := r.Tail(1); !reflect.DeepEqual(got, []string{"four"}) {
+		t.Errorf("Tail(1) = %q", got)
+	}
+
+	// Runaway lines are split into maxChildLine pieces, whether or not they end
+	r = newLineRing(8)
+	long := strings.Repeat("x", 2*maxChildLine+5)
+	r.Write([]byte(long[:maxChildLine+3]))
+	r.Write([]byte(long[maxChildLine+3:] + "\n" + long + "\nend\n"))
+	var sizes []int
+	for _, line := range r.Tail(0) {
+		sizes = append(sizes, len(line))
+	}
+	want := []int{maxChildLine, maxChildLine, 5, maxChildLine, maxChildLine, 5, 3}
+	if !reflect.DeepEqual(sizes, want) {
+		t.Errorf("line sizes = %v, want %v", sizes, want)
+	}
+}
+
+// TestFileDriverRestartsChild tests that a file driver without a reload method restarts the collector
+func TestFileDriverRestartsChild(t *testing.T) {
+	p := newTestProcessSupervisor(t, "exec sleep 30")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		<-p.Done()
+	}()
+	go p.run(ctx)
+	waitFor(t, func() bool { return p.status().Running })
+
+	dir := t.TempDir()
+	d := newFileDriver(DriverConfig{
+		NodeID:       "device-1",
+		ConfigPath:   filepath.Join(dir, "agent.conf"),
+		LastGoodPath: filepath.Join(dir, "agent.conf.last-good"),
+		Child:        p,
+	})
+	if _, err := d.Apply(ctx, []byte("config")); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if st := p.status(); st.Restarts != 1 || !st.Running {
+		t.Errorf("status after apply = %+v, want one restart", st)
+	}
+	if h := d.Health(ctx); !h.Healthy || !strings.Contains(h.Status, "running") {
+		t.Errorf("health = %+v", h)
+	}
+}
+
+// TestFetchStatusReportsChild tests that FetchStatus includes the collector process
+func TestFetchStatusReportsChild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test child is a shell script")
+	}
+	a := NewDeviceAgent("unused", "device-1", "file", filepath.Join(t.TempDir(), "agent.conf"), "", Options{
+		Exec: []string{"/bin/sh", "-c", "exec sleep 30"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		<-a.child.Done()
+	}()
+	go a.child.run(ctx)
+	waitFor(t, func() bool { return a.child.status().Running })
+
+	a.handleCommand(ctx, &controlpb.Command{Type: "FetchStatus", CorrelationId: "c-1"})
+	var status statusReport
+	decodeEvent(t, nextEvent(t, a, "StatusReport"), &status)
+	if p := status.Collector.Process; p == nil || !p.Running || p.PID != a.child.status().PID {
+		t.Errorf("reported process %+v", p)
+	}
+}
+
+// TestChildStartsWhileSupervisorUnreachable tests that the collector runs
+// before the first connect succeeds and the agent keeps retrying
+func TestChildStartsWhileSupervisorUnreachable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test child is a shell script")
+	}
+	mt := newMemoryTransport()
+	mt.refuseNext(1 << 30)
+	a := NewDeviceAgent("unused", "device-1", "file", filepath.Join(t.TempDir(), "agent.conf"), "", Options{
+		Transport:       mt,
+		Backoff:         BackoffPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 1},
+		MonitorInterval: time.Hour,
+		Exec:            []string{"/bin/sh", "-c", "exec sleep 30"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start with the supervisor down: %v", err)
+	}
+	defer a.Stop()
+
+	waitFor(t, func() bool { return a.child.status().Running })
+	waitFor(t, func() bool { return mt.connectAttempts() >= 3 })
+
+	mt.refuseNext(0)
+	stream := mt.accept(t)
+	if reg := stream.next(t).GetRegister(); reg.GetNodeId() != "device-1" {
+		t.Errorf("first message is not a registration: %v", reg)
+	}
+}
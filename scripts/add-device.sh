@@ -138,6 +138,7 @@ spec:
         - "--agent-type=fluentbit"
         - "--config-path=/shared-config/fluent-bit.conf"
         - "--reload-endpoint=http://fluentbit-$DEVICE_ID.opamp-edge.svc.cluster.local:2020/api/v2/reload"
+        - "--insecure"
         volumeMounts:
         - name: shared-config
           mountPath: /shared-config
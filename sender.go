@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// Priority selects the outbound queue an envelope is placed on.
+type Priority int
+
+const (
+	// PriorityNormal is used for periodic events and status reports
+	PriorityNormal Priority = iota
+	// PriorityHigh is used for ConfigAcks, which are always sent first
+	PriorityHigh
+)
+
+var errQueueFull = errors.New("outbound queue full")
+
+// outbound is a queued envelope. Durable envelopes are persisted to the
+// outbox when they cannot be delivered; the rest are dropped.
+type outbound struct {
+	env     *controlpb.Envelope
+	durable bool
+}
+
+// streamHandoff replaces the stream owned by the sender. The greeting
+// envelopes are written on the new stream before anything queued.
+type streamHandoff struct {
+	stream   Stream
+	greeting []*controlpb.Envelope
+	done     chan error
+}
+
+// sender serializes all writes to the Control stream. gRPC forbids
+// concurrent Send calls on one stream, so only the run goroutine ever
+// touches the stream; everyone else enqueues.
+type sender struct {
+	nodeID string
+	high   chan outbound
+	normal chan outbound
+	swap   chan streamHandoff
+	// replayNow asks run to replay the outbox on the current stream
+	replayNow chan struct{}
+	// outbox is optional; without it messages stay queued in memory while
+	// no stream is attached
+	outbox *outbox
+	// typedEvents reports whether the current server takes typed event
+	// bodies. It is asked when an event is written, not when it is queued,
+	// since queued and parked events outlive the stream they were made on.
+	// Nil sends every body.
+	typedEvents func() bool
+}
+
+func newSender(nodeID string, capacity int, ob *outbox) *sender {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &sender{
+		nodeID:    nodeID,
+		high:      make(chan outbound, capacity),
+		normal:    make(chan outbound, capacity),
+		swap:      make(chan streamHandoff),
+		replayNow: make(chan struct{}, 1),
+		outbox:    ob,
+	}
+}
+
+// enqueue queues an envelope for sending without blocking. A durable
+// envelope that does not fit in the queue goes straight to the outbox.
+func (s *sender) enqueue(env *controlpb.Envelope, prio Priority, durable bool) error {
+	q := s.normal
+	if prio == PriorityHigh {
+		q = s.high
+	}
+	select {
+	case q <- outbound{env: env, durable: durable}:
+		return nil
+	default:
+		if durable && s.outbox != nil {
+			return s.outbox.add(env)
+		}
+		return errQueueFull
+	}
+}
+
+// attach hands a new stream to the writer, closing the previous one, and
+// sends the greeting on it. It returns once the greeting has been written,
+// so callers know the old stream is no longer in use. A nil stream detaches.
+func (s *sender) attach(ctx context.Context, stream Stream, greeting ...*controlpb.Envelope) error {
+	h := streamHandoff{stream: stream, greeting: greeting, done: make(chan error, 1)}
+	select {
+	case s.swap <- h:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-h.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replayOutbox asks the writer to replay the outbox on the current stream.
+// The agent calls it once the server has answered the registration, so the
+// replayed events are written for the protocol it negotiated.
+func (s *sender) replayOutbox() {
+	select {
+	case s.replayNow <- struct{}{}:
+	default:
+	}
+}
+
+// run is the single writer goroutine. While no stream is attached, durable
+// messages are moved to the outbox and replayed once the next stream has
+// been handed over and replayOutbox is called.
+func (s *sender) run(ctx context.Context) {
+	var stream Stream
+	defer func() {
+		if stream != nil {
+			stream.Close()
+		}
+	}()
+
+	for {
+		if stream == nil {
+			if s.outbox == nil {
+				select {
+				case <-ctx.Done():
+					return
+				case h := <-s.swap:
+					stream = s.handoff(stream, h)
+				}
+				continue
+			}
+			select {
+			case msg := <-s.high:
+				s.park(msg)
+				continue
+			default:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case h := <-s.swap:
+				stream = s.handoff(stream, h)
+			case msg := <-s.high:
+				s.park(msg)
+			case msg := <-s.normal:
+				s.park(msg)
+			}
+			continue
+		}
+
+		var msg outbound
+		select {
+		case msg = <-s.high:
+		default:
+			select {
+			case <-ctx.Done():
+				return
+			case h := <-s.swap:
+				stream = s.handoff(stream, h)
+				continue
+			case <-s.replayNow:
+				stream = s.replay(stream)
+				continue
+			case msg = <-s.high:
+			case msg = <-s.normal:
+			}
+		}
+
+		if err := s.send(stream, msg.env); err != nil {
+			// The receive loop sees the same failure and reconnects
+			nodeLogger(s.nodeID).Warn("Send failed, waiting for new stream", "error", err)
+			s.park(msg)
+			stream.Close()
+			stream = nil
+		}
+	}
+}
+
+// park keeps an undeliverable durable message in the outbox.
+func (s *sender) park(msg outbound) {
+	if !msg.durable || s.outbox == nil {
+		return
+	}
+	if err := s.outbox.add(msg.env); err != nil {
+		nodeLogger(s.nodeID).Error("Failed to persist message to outbox", "error", err)
+	}
+}
+
+func (s *sender) handoff(old Stream, h streamHandoff) Stream {
+	if old != nil {
+		old.Close()
+	}
+	if h.stream == nil {
+		h.done <- nil
+		return nil
+	}
+	// A replay requested for the previous stream would run before the
+	// new server answered the registration
+	select {
+	case <-s.replayNow:
+	default:
+	}
+	for _, env := range h.greeting {
+		if err := h.stream.Send(env); err != nil {
+			h.stream.Close()
+			h.done <- err
+			return nil
+		}
+	}
+	h.done <- nil
+	return h.stream
+}
+
+// replay writes the outbox to stream, closing it if a write fails.
+func (s *sender) replay(stream Stream) Stream {
+	if s.outbox == nil {
+		return stream
+	}
+	n, err := s.outbox.replay(func(env *controlpb.Envelope) error { return s.send(stream, env) })
+	if n > 0 {
+		nodeLogger(s.nodeID).Info("Replayed messages from outbox", "count", n)
+	}
+	if err != nil {
+		nodeLogger(s.nodeID).Warn("Outbox replay interrupted", "error", err)
+		stream.Close()
+		return nil
+	}
+	return stream
+}
+
+// send writes env, leaving out a typed event body the server did not
+// negotiate. The JSON payload always goes.
+func (s *sender) send(stream Stream, env *controlpb.Envelope) error {
+	if event := env.GetEvent(); event.GetBody() != nil && s.typedEvents != nil && !s.typedEvents() {
+		// Stripped on a copy: env is parked as is if the write fails
+		event = proto.Clone(event).(*controlpb.Event)
+		event.Body = nil
+		env = &controlpb.Envelope{Body: &controlpb.Envelope_Event{Event: event}}
+	}
+	return stream.Send(env)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// fakeStream records sent envelopes and flags concurrent Send calls
+type fakeStream struct {
+	streamState
+
+	mu       sync.Mutex
+	sent     []*controlpb.Envelope
+	inflight int32
+	overlap  atomic.Bool
+	closed   atomic.Bool
+	recv     chan *controlpb.Envelope
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{streamState: newStreamState(), recv: make(chan *controlpb.Envelope)}
+}
+
+func (f *fakeStream) Send(env *controlpb.Envelope) error {
+	if atomic.AddInt32(&f.inflight, 1) > 1 {
+		f.overlap.Store(true)
+	}
+	defer atomic.AddInt32(&f.inflight, -1)
+	if f.closed.Load() {
+		f.overlap.Store(true) // Send after Close is just as invalid
+	}
+	// Widen the window in which a concurrent Send would be detected
+	time.Sleep(10 * time.Microsecond)
+
+	f.mu.Lock()
+	f.sent = append(f.sent, env)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeStream) Recv() <-chan *controlpb.Envelope { return f.recv }
+
+func (f *fakeStream) Close() error {
+	f.closed.Store(true)
+	f.fail(errStreamClosed)
+	return nil
+}
+
+func (f *fakeStream) Sent() []*controlpb.Envelope {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*controlpb.Envelope(nil), f.sent...)
+}
+
+func eventEnvelope(i int) *controlpb.Envelope {
+	return &controlpb.Envelope{Body: &controlpb.Envelope_Event{Event: &controlpb.Event{Type: fmt.Sprintf("e%d", i)}}}
+}
+
+func ackEnvelope(hash string) *controlpb.Envelope {
+	return &controlpb.Envelope{Body: &controlpb.Envelope_ConfigAck{ConfigAck: &controlpb.ConfigAck{ConfigHash: hash}}}
+}
+
+// TestSenderPrioritizesAcks tests that queued ConfigAcks go out before events
+func TestSenderPrioritizesAcks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := newSender("device-1", 16, nil)
+	for i := 0; i < 3; i++ {
+		if err := s.enqueue(eventEnvelope(i), PriorityNormal, false); err != nil {
+			t.Fatalf("enqueue event: %v", err)
+		}
+	}
+	if err := s.enqueue(ackEnvelope("h1"), PriorityHigh, false); err != nil {
+		t.Fatalf("enqueue ack: %v", err)
+	}
+	go s.run(ctx)
+
+	stream := newFakeStream()
+	greeting := &controlpb.Envelope{Body: &controlpb.Envelope_Register{Register: &controlpb.EdgeIdentity{NodeId: "device-1"}}}
+	if err := s.attach(ctx, stream, greeting); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(stream.Sent()) == 5 })
+	sent := stream.Sent()
+	if sent[0].GetRegister() == nil {
+		t.Errorf("first message is not the registration: %v", sent[0])
+	}
+	if sent[1].GetConfigAck().GetConfigHash() != "h1" {
+		t.Errorf("second message is not the queued ack: %v", sent[1])
+	}
+}
+
+// TestSenderQueueBounded tests that enqueue fails instead of blocking when full
+func TestSenderQueueBounded(t *testing.T) {
+	s := newSender("device-1", 2, nil)
+	for i := 0; i < 2; i++ {
+		if err := s.enqueue(eventEnvelope(i), PriorityNormal, false); err != nil {
+			t.Fatalf("enqueue %d: %v", i, err)
+		}
+	}
+	if err := s.enqueue(eventEnvelope(2), PriorityNormal, false); err != errQueueFull {
+		t.Errorf("got %v, want errQueueFull", err)
+	}
+	// The ack queue has its own capacity
+	if err := s.enqueue(ackEnvelope("h"), PriorityHigh, false); err != nil {
+		t.Errorf("ack enqueue with full event queue: %v", err)
+	}
+}
+
+// TestSenderConcurrentWrites hammers the agent with concurrent acks, events,
+// monitor ticks and stream handoffs. Run with -race.
+func TestSenderConcurrentWrites(t *testing.T) {
+	fb := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"uptime_sec":1}`))
+	}))
+	defer fb.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "fluent-bit.conf")
+	if err := os.WriteFile(configPath, []byte("[SERVICE]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewDeviceAgent("unused", "device-1", "fluentbit", configPath, fb.URL+"/api/v2/reload", Options{
+		SendQueueSize:   1024,
+		MonitorInterval: time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.out.run(ctx)
+	go a.runtimeMonitorLoop(ctx)
+
+	streams := []*fakeStream{newFakeStream()}
+	if err := a.out.attach(ctx, streams[0], a.registerEnvelope()); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if i%2 == 0 {
+					a.sendConfigAck(ctx, &controlpb.ConfigAck{DeviceId: "device-1", ConfigHash: fmt.Sprintf("%d-%d", g, i), Success: true})
+				} else {
+					a.sendEvent(ctx, "Test", "payload", fmt.Sprintf("%d-%d", g, i))
+				}
+			}
+		}(g)
+	}
+
+	// Replace the stream a few times while writers are active
+	for i := 0; i < 5; i++ {
+		next := newFakeStream()
+		if err := a.out.attach(ctx, next, a.registerEnvelope()); err != nil {
+			t.Fatalf("handoff %d: %v", i, err)
+		}
+		streams = append(streams, next)
+		time.Sleep(2 * time.Millisecond)
+	}
+	wg.Wait()
+
+	// 400 writes plus one registration per stream, plus any monitor acks
+	want := 400 + len(streams)
+	total := func() int {
+		n := 0
+		for _, st := range streams {
+			n += len(st.Sent())
+		}
+		return n
+	}
+	waitFor(t, func() bool { return total() >= want })
+
+	last := streams[len(streams)-1]
+	for i, st := range streams {
+		if st.overlap.Load() {
+			t.Errorf("stream %d saw concurrent or post-close Send", i)
+		}
+		if i < len(streams)-1 && !st.closed.Load() {
+			t.Errorf("stream %d was not closed on handoff", i)
+		}
+		if sent := st.Sent(); len(sent) == 0 || sent[0].GetRegister() == nil {
+			t.Errorf("stream %d did not start with registration", i)
+		}
+	}
+	if last.closed.Load() {
+		t.Error("active stream was closed")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
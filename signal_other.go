@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "syscall"
+
+// reloadSignals are the signal names accepted by --reload-signal.
+var reloadSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// statusSchemaVersion is bumped whenever a field of statusReport changes
+// meaning or is removed; adding fields does not bump it.
+const statusSchemaVersion = 1
+
+// statusReport is the document sent in reply to FetchStatus.
+type statusReport struct {
+	SchemaVersion int              `json:"schema_version"`
+	DeviceID      string           `json:"device_id"`
+	Status        string           `json:"status"`
+	Timestamp     int64            `json:"timestamp"`
+	Agent         agentStatus      `json:"agent"`
+	Connection    connectionStatus `json:"connection"`
+	Config        configStatus     `json:"config"`
+	Collector     collectorStatus  `json:"collector"`
+	Host          hostInfo         `json:"host"`
+}
+
+type agentStatus struct {
+	buildInfo
+	InstanceUID   string            `json:"instance_uid"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds int64             `json:"uptime_seconds"`
+}
+
+type connectionStatus struct {
+	Connected        bool       `json:"connected"`
+	Connects         int        `json:"connects"`
+	Disconnects      int        `json:"disconnects"`
+	FailedConnects   int        `json:"failed_connects"`
+	LastConnected    *time.Time `json:"last_connected,omitempty"`
+	LastDisconnected *time.Time `json:"last_disconnected,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	// ProtocolVersion and HeartbeatIntervalSeconds are the negotiated settings
+	ProtocolVersion          uint32  `json:"protocol_version,omitempty"`
+	HeartbeatIntervalSeconds float64 `json:"heartbeat_interval_seconds,omitempty"`
+	// Queued counts messages waiting in memory, Outbox those persisted to disk
+	Queued        int    `json:"queued"`
+	Outbox        int    `json:"outbox"`
+	OutboxDropped uint64 `json:"outbox_dropped"`
+}
+
+type configStatus struct {
+	// Hash is the effective config hash last reported to the server
+	Hash      string       `json:"hash,omitempty"`
+	LastApply *applyStatus `json:"last_apply,omitempty"`
+}
+
+// applyStatus is the outcome of the most recent config push.
+type applyStatus struct {
+	Hash       string    `json:"hash"`
+	Outcome    string    `json:"outcome"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+type collectorStatus struct {
+	Type         string       `json:"type"`
+	Capabilities []string     `json:"capabilities"`
+	Health       DriverHealth `json:"health"`
+	Process      *childStatus `json:"process,omitempty"`
+}
+
+// applyBuckets are the upper bounds, in seconds, of the apply latency histogram.
+var applyBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// agentStats tracks the connection and config history that FetchStatus and
+// /metrics report.
+type agentStats struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	conn      connectionStatus
+	transport TransportState
+	lastApply *applyStatus
+	// pushes counts config pushes by outcome
+	pushes map[string]int
+	// applyCounts[i] counts applies that took at most applyBuckets[i]
+	applyCounts []int
+	applyCount  int
+	applySum    float64
+}
+
+func newAgentStats() *agentStats {
+	return &agentStats{
+		startedAt:   time.Now(),
+		pushes:      map[string]int{},
+		applyCounts: make([]int, len(applyBuckets)),
+	}
+}
+
+// streamUp records a stream that was opened and registered.
+func (s *agentStats) streamUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.conn.Connected = true
+	s.conn.Connects++
+	s.conn.LastConnected = &now
+}
+
+// streamDown records the loss of the registered stream.
+func (s *agentStats) streamDown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.conn.Connected = false
+	s.conn.Disconnects++
+	s.conn.LastDisconnected = &now
+	if err != nil {
+		s.conn.LastError = err.Error()
+	}
+}
+
+// connectFailed records a connect or register attempt that failed.
+func (s *agentStats) connectFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.FailedConnects++
+	s.conn.LastError = err.Error()
+}
+
+// transportChanged records the transport's latest state.
+func (s *agentStats) transportChanged(state TransportState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transport = state
+}
+
+// configApplied records the outcome of a config push and how long it took.
+func (s *agentStats) configApplied(apply applyStatus, took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	apply.DurationMs = took.Milliseconds()
+	s.lastApply = &apply
+	s.pushes[apply.Outcome]++
+
+	seconds := took.Seconds()
+	s.applyCount++
+	s.applySum += seconds
+	for i, le := range applyBuckets {
+		if seconds <= le {
+			s.applyCounts[i]++
+		}
+	}
+}
+
+func (s *agentStats) connection() connectionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn
+}
+
+func (s *agentStats) lastApplied() *applyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastApply == nil {
+		return nil
+	}
+	apply := *s.lastApply
+	return &apply
+}
+
+// buildStatus assembles the FetchStatus document.
+func (a *DeviceAgent) buildStatus(ctx context.Context) statusReport {
+	now := time.Now()
+	conn := a.stats.connection()
+	conn.Queued = len(a.out.high) + len(a.out.normal)
+	if conn.Connected {
+		conn.ProtocolVersion = a.negotiated.protocol()
+		conn.HeartbeatIntervalSeconds = a.negotiated.heartbeatInterval().Seconds()
+	}
+	if a.outbox != nil {
+		conn.Outbox = a.outbox.Len()
+		conn.OutboxDropped = a.outbox.Dropped()
+	}
+
+	report := statusReport{
+		SchemaVersion: statusSchemaVersion,
+		DeviceID:      a.nodeID,
+		Status:        "online",
+		Timestamp:     now.Unix(),
+		Agent: agentStatus{
+			buildInfo:     readBuildInfo(),
+			InstanceUID:   a.instanceUID,
+			Labels:        a.opts.Labels,
+			StartedAt:     a.stats.startedAt,
+			UptimeSeconds: int64(now.Sub(a.stats.startedAt).Seconds()),
+		},
+		Connection: conn,
+		Config: configStatus{
+			Hash:      a.reportedConfigHash(),
+			LastApply: a.stats.lastApplied(),
+		},
+		Collector: collectorStatus{
+			Type:         a.agentType,
+			Capabilities: a.driver.Capabilities(),
+			Health:       a.driver.Health(ctx),
+		},
+		Host: readHostInfo(filepath.Dir(a.configPath)),
+	}
+	if a.child != nil {
+		process := a.child.status()
+		report.Collector.Process = &process
+	}
+	return report
+}
+
+// proto is the typed form of the report.
+func (r statusReport) proto() *controlpb.StatusReport {
+	p := &controlpb.StatusReport{
+		SchemaVersion:     statusSchemaVersion,
+		DeviceId:          r.DeviceID,
+		Status:            r.Status,
+		TimestampUnixNano: r.Timestamp * int64(time.Second),
+		Agent: &controlpb.AgentStatus{
+			Version:           r.Agent.Version,
+			GoVersion:         r.Agent.GoVersion,
+			Revision:          r.Agent.Revision,
+			InstanceUid:       r.Agent.InstanceUID,
+			Labels:            r.Agent.Labels,
+			StartedAtUnixNano: unixNano(r.Agent.StartedAt),
+			UptimeSeconds:     r.Agent.UptimeSeconds,
+		},
+		Connection: &controlpb.ConnectionStatus{
+			Connected:                r.Connection.Connected,
+			Connects:                 int32(r.Connection.Connects),
+			Disconnects:              int32(r.Connection.Disconnects),
+			FailedConnects:           int32(r.Connection.FailedConnects),
+			LastError:                r.Connection.LastError,
+			Queued:                   int32(r.Connection.Queued),
+			Outbox:                   int32(r.Connection.Outbox),
+			OutboxDropped:            r.Connection.OutboxDropped,
+			ProtocolVersion:          r.Connection.ProtocolVersion,
+			HeartbeatIntervalSeconds: r.Connection.HeartbeatIntervalSeconds,
+		},
+		Config: &controlpb.ConfigStatus{Hash: r.Config.Hash},
+		Collector: &controlpb.CollectorStatus{
+			Type:          r.Collector.Type,
+			Capabilities:  r.Collector.Capabilities,
+			Healthy:       r.Collector.Health.Healthy,
+			HealthStatus:  r.Collector.Health.Status,
+			UptimeSeconds: r.Collector.Health.UptimeSeconds,
+		},
+		Host: &controlpb.HostStatus{
+			Hostname:      r.Host.Hostname,
+			Os:            r.Host.OS,
+			Arch:          r.Host.Arch,
+			OsRelease:     r.Host.OSRelease,
+			Kernel:        r.Host.Kernel,
+			UptimeSeconds: r.Host.UptimeSeconds,
+			Cpus:          int32(r.Host.CPUs),
+			LoadAverage:   r.Host.LoadAverage,
+		},
+	}
+	if apply := r.Config.LastApply; apply != nil {
+		p.Config.LastApplyHash = apply.Hash
+		p.Config.LastApplyOutcome = apply.Outcome
+		p.Config.LastApplySuccess = apply.Success
+		p.Config.LastApplyError = apply.Error
+		p.Config.LastApplyAtUnixNano = unixNano(apply.At)
+		p.Config.LastApplyDurationMs = apply.DurationMs
+	}
+	if process := r.Collector.Process; process != nil {
+		p.Collector.Process = &controlpb.ProcessStatus{
+			Command:  process.Command,
+			Pid:      int32(process.PID),
+			Running:  process.Running,
+			Restarts: int32(process.Restarts),
+		}
+	}
+	if mem := r.Host.Memory; mem != nil {
+		p.Host.MemoryTotalBytes = mem.TotalBytes
+		p.Host.MemoryAvailableBytes = mem.AvailableBytes
+	}
+	if disk := r.Host.Disk; disk != nil {
+		p.Host.DiskTotalBytes = disk.TotalBytes
+		p.Host.DiskFreeBytes = disk.FreeBytes
+	}
+	return p
+}
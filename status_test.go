@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// TestParseMeminfo tests reading total and available memory
+func TestParseMeminfo(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *memInfo
+	}{
+		{
+			name: "linux",
+			data: "MemTotal:        8040516 kB\nMemFree:          512000 kB\nMemAvailable:    4020258 kB\n",
+			want: &memInfo{TotalBytes: 8040516 * 1024, AvailableBytes: 4020258 * 1024},
+		},
+		{name: "empty", data: "", want: nil},
+		{name: "garbage", data: "MemTotal: lots\n", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMeminfo([]byte(tt.data)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMeminfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseLoadAvg tests reading the load averages
+func TestParseLoadAvg(t *testing.T) {
+	tests := []struct {
+		data string
+		want []float64
+	}{
+		{"0.52 0.58 0.59 2/1024 12345\n", []float64{0.52, 0.58, 0.59}},
+		{"0.52 0.58\n", nil},
+		{"a b c\n", nil},
+	}
+	for _, tt := range tests {
+		if got := parseLoadAvg([]byte(tt.data)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLoadAvg(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+// TestFetchStatus tests the status document sent in reply to FetchStatus
+func TestFetchStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		healthCode  int
+		wantHealthy bool
+		wantStatus  string
+	}{
+		{name: "health check off", healthCode: 0, wantHealthy: true, wantStatus: "running for 100s"},
+		{name: "health check ok", healthCode: http.StatusOK, wantHealthy: true, wantStatus: "running for 100s"},
+		{name: "health check failing", healthCode: http.StatusInternalServerError, wantHealthy: false, wantStatus: "health check failing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, fb := newFluentBitAgent(t)
+			fb.healthCode = tt.healthCode
+			ctx := context.Background()
+
+			config := []byte("[OUTPUT]\n    Name stdout\n")
+			a.handleConfigPush(ctx, &controlpb.ConfigPush{DeviceId: "device-1", ConfigData: config, ConfigHash: configHash(config)}, "c-0")
+			drainAcks(a)
+
+			a.handleCommand(ctx, &controlpb.Command{Type: "FetchStatus", CorrelationId: "c-1"})
+			var status statusReport
+			decodeEvent(t, nextEvent(t, a, "StatusReport"), &status)
+
+			if status.SchemaVersion != statusSchemaVersion || status.DeviceID != "device-1" {
+				t.Errorf("schema %d, device %q", status.SchemaVersion, status.DeviceID)
+			}
+			if status.Agent.Version == "" || status.Agent.GoVersion == "" || status.Agent.StartedAt.IsZero() {
+				t.Errorf("agent = %+v", status.Agent)
+			}
+			apply := status.Config.LastApply
+			if apply == nil || !apply.Success || apply.Outcome != HistoryApplied || apply.Hash != configHash(config) {
+				t.Errorf("last apply = %+v", apply)
+			}
+			h := status.Collector.Health
+			if h.Healthy != tt.wantHealthy || !strings.Contains(h.Status, tt.wantStatus) || h.UptimeSeconds != 100 {
+				t.Errorf("collector health = %+v", h)
+			}
+			if status.Collector.Type != "fluentbit" || len(status.Collector.Capabilities) == 0 {
+				t.Errorf("collector = %+v", status.Collector)
+			}
+			if status.Host.OS == "" || status.Host.CPUs == 0 {
+				t.Errorf("host = %+v", status.Host)
+			}
+		})
+	}
+}
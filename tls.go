@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TLSConfig holds the transport security settings for the Control stream.
+// mTLS is the default; Insecure must be set explicitly to fall back to plaintext.
+type TLSConfig struct {
+	CAFile         string
+	CertFile       string
+	KeyFile        string
+	ServerName     string
+	Insecure       bool
+	ReloadInterval time.Duration
+}
+
+// transportCredentials builds the gRPC credentials for the supervisor connection.
+// The returned reloader is nil when running without TLS.
+func (c TLSConfig) transportCredentials() (credentials.TransportCredentials, *certReloader, error) {
+	if c.Insecure {
+		return insecure.NewCredentials(), nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, nil, errors.New("client certificate and key are required for mTLS (set --tls-cert/--tls-key or use --insecure)")
+	}
+	tlsCfg, reloader, err := c.clientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	return credentials.NewTLS(tlsCfg), reloader, nil
+}
+
+// clientConfig builds the TLS client configuration shared by all transports.
+// Without a certificate and key no client certificate is presented and the
+// returned reloader is nil.
+func (c TLSConfig) clientConfig() (*tls.Config, *certReloader, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+
+	var reloader *certReloader
+	if c.CertFile != "" || c.KeyFile != "" {
+		var err error
+		reloader, err = newCertReloader(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsCfg.GetClientCertificate = reloader.GetClientCertificate
+	}
+
+	// Without a CA bundle the system roots are used to verify the supervisor
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates found in CA bundle %s", c.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return tlsCfg, reloader, nil
+}
+
+// certReloader serves the client certificate for TLS handshakes and picks up
+// rotated cert/key files from disk. Established streams keep their session;
+// the new certificate is presented on the next handshake.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload re-reads the key pair if either file changed since the last load.
+// It reports whether a new certificate was loaded.
+func (r *certReloader) reload() (bool, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat client cert: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat client key: %w", err)
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load client key pair: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	r.mu.Unlock()
+	return true, nil
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate. A failed
+// reload (e.g. a half-written file during rotation) keeps the previous cert.
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if _, err := r.reload(); err != nil {
+		slog.Warn("Client certificate reload failed, using previous certificate", "error", err)
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// watch periodically checks the key pair so rotations are logged and
+// validated ahead of the next handshake.
+func (r *certReloader) watch(ctx context.Context, nodeID string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.reload()
+			if err != nil {
+				nodeLogger(nodeID).Warn("Client certificate reload failed", "error", err)
+			} else if changed {
+				nodeLogger(nodeID).Info("Client certificate rotated", "path", r.certFile)
+			}
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// testCA is a throwaway certificate authority for handshake tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA cert: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf certificate and returns its PEM-encoded cert and key
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// recordingServer captures the client certificate CN of every registration
+type recordingServer struct {
+	controlpb.UnimplementedControlServiceServer
+	registered chan string
+}
+
+func (s *recordingServer) Control(stream controlpb.ControlService_ControlServer) error {
+	env, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if env.GetRegister() == nil {
+		return nil
+	}
+	cn := ""
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			cn = info.State.PeerCertificates[0].Subject.CommonName
+		}
+	}
+	s.registered <- cn
+	<-stream.Context().Done()
+	return nil
+}
+
+// startTLSServer runs an in-process supervisor that requires client certs from ca
+func startTLSServer(t *testing.T, serverCA, clientCA *testCA) (string, *recordingServer) {
+	t.Helper()
+	certPEM, keyPEM := serverCA.issue(t, "supervisor.test", 2, x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load server cert: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCA.cert)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	rec := &recordingServer{registered: make(chan string, 4)}
+	controlpb.RegisterControlServiceServer(srv, rec)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String(), rec
+}
+
+// newTLSAgent writes the client credentials to disk and returns an agent using them
+func newTLSAgent(t *testing.T, addr string, serverCA, clientCA *testCA, cn string) (*DeviceAgent, TLSConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	certPEM, keyPEM := clientCA.issue(t, cn, 3, x509.ExtKeyUsageClientAuth)
+	cfg := TLSConfig{
+		CAFile:     filepath.Join(dir, "ca.pem"),
+		CertFile:   filepath.Join(dir, "client.pem"),
+		KeyFile:    filepath.Join(dir, "client-key.pem"),
+		ServerName: "supervisor.test",
+	}
+	writeFile(t, cfg.CAFile, serverCA.pem)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	return NewDeviceAgent(addr, cn, "fluentbit", filepath.Join(dir, "fluent-bit.conf"), "", Options{TLS: cfg}), cfg
+}
+
+// registerOnce connects to the supervisor and sends a single registration
+func registerOnce(t *testing.T, a *DeviceAgent) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	defer a.transport.Close()
+
+	stream, err := a.transport.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&controlpb.Envelope{Body: &controlpb.Envelope_Register{
+		Register: &controlpb.EdgeIdentity{NodeId: a.nodeID},
+	}}); err != nil {
+		return err
+	}
+	// The server never replies; the stream ends once the handshake is rejected or the test times out
+	select {
+	case <-stream.Done():
+		return stream.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func waitRegistered(rec *recordingServer, timeout time.Duration) (string, bool) {
+	select {
+	case cn := <-rec.registered:
+		return cn, true
+	case <-time.After(timeout):
+		return "", false
+	}
+}
+
+// TestMTLSHandshakeAccepted tests that a device with a trusted client cert registers
+func TestMTLSHandshakeAccepted(t *testing.T) {
+	ca := newTestCA(t, "test-ca")
+	addr, rec := startTLSServer(t, ca, ca)
+	agent, _ := newTLSAgent(t, addr, ca, ca, "device-1")
+
+	go registerOnce(t, agent)
+
+	cn, ok := waitRegistered(rec, 5*time.Second)
+	if !ok {
+		t.Fatal("supervisor did not receive registration")
+	}
+	if cn != "device-1" {
+		t.Errorf("got client CN %q, want %q", cn, "device-1")
+	}
+}
+
+// TestMTLSHandshakeRejected tests handshakes that must fail in either direction
+func TestMTLSHandshakeRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		serverCA string // CA trusted by the agent for the server cert
+		clientCA string // CA that issues the agent's client cert
+	}{
+		{"untrusted client certificate", "trusted", "rogue"},
+		{"untrusted server certificate", "rogue", "trusted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cas := map[string]*testCA{
+				"trusted": newTestCA(t, "trusted-ca"),
+				"rogue":   newTestCA(t, "rogue-ca"),
+			}
+			addr, rec := startTLSServer(t, cas["trusted"], cas["trusted"])
+			agent, _ := newTLSAgent(t, addr, cas[tt.serverCA], cas[tt.clientCA], "device-1")
+
+			if err := registerOnce(t, agent); err == nil {
+				t.Error("expected handshake error but got none")
+			}
+			if cn, ok := waitRegistered(rec, 200*time.Millisecond); ok {
+				t.Errorf("supervisor accepted registration from %q", cn)
+			}
+		})
+	}
+}
+
+// TestTLSConfigRequiresClientCert tests that mTLS is the default
+func TestTLSConfigRequiresClientCert(t *testing.T) {
+	if _, _, err := (TLSConfig{}).transportCredentials(); err == nil {
+		t.Error("expected error without client cert")
+	}
+	if _, reloader, err := (TLSConfig{Insecure: true}).transportCredentials(); err != nil || reloader != nil {
+		t.Errorf("insecure mode: got reloader=%v err=%v", reloader, err)
+	}
+}
+
+// TestCertReloaderRotation tests that a rotated cert is served on the next handshake
+func TestCertReloaderRotation(t *testing.T) {
+	ca := newTestCA(t, "test-ca")
+	addr, rec := startTLSServer(t, ca, ca)
+	agent, cfg := newTLSAgent(t, addr, ca, ca, "device-1")
+
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		t.Fatalf("failed to create reloader: %v", err)
+	}
+
+	certPEM, keyPEM := ca.issue(t, "device-1-rotated", 4, x509.ExtKeyUsageClientAuth)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	// Ensure the mtime moves even on filesystems with coarse timestamps
+	future := time.Now().Add(time.Second)
+	os.Chtimes(cfg.CertFile, future, future)
+	os.Chtimes(cfg.KeyFile, future, future)
+
+	changed, err := reloader.reload()
+	if err != nil || !changed {
+		t.Fatalf("reload: changed=%v err=%v", changed, err)
+	}
+	cert, _ := reloader.GetClientCertificate(nil)
+	leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+	if leaf.Subject.CommonName != "device-1-rotated" {
+		t.Errorf("got CN %q after rotation", leaf.Subject.CommonName)
+	}
+
+	// A new connection from the agent presents the rotated certificate
+	go registerOnce(t, agent)
+	cn, ok := waitRegistered(rec, 5*time.Second)
+	if !ok || cn != "device-1-rotated" {
+		t.Errorf("got CN %q (registered=%v), want rotated cert", cn, ok)
+	}
+}
+
+// TestRedialReusesCredentials tests that a redial keeps the credentials and
+// their certificate watcher instead of starting another one
+func TestRedialReusesCredentials(t *testing.T) {
+	ca := newTestCA(t, "test-ca")
+	_, cfg := newTLSAgent(t, "127.0.0.1:1", ca, ca, "device-1")
+	cfg.ReloadInterval = time.Hour
+	tr := newGRPCTransport("127.0.0.1:1", "device-1", cfg, KeepaliveConfig{}, 1)
+	defer tr.Close()
+
+	ctx := context.Background()
+	if _, err := tr.clientFor(ctx); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	creds, conn := tr.creds, tr.conn
+	if tr.stopCerts == nil {
+		t.Fatal("certificate watcher not started")
+	}
+
+	tr.streamFailed()
+	if _, err := tr.clientFor(ctx); err != nil {
+		t.Fatalf("redial: %v", err)
+	}
+	if tr.conn == conn {
+		t.Error("connection was not replaced")
+	}
+	if tr.creds != creds {
+		t.Error("redial set up new credentials")
+	}
+
+	tr.Close()
+	if tr.stopCerts != nil {
+		t.Error("Close left the certificate watcher running")
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// Transport connects the agent to its server. Each Connect opens a fresh
+// Stream; after a stream fails the agent backs off and calls Connect again.
+type Transport interface {
+	Connect(ctx context.Context) (Stream, error)
+	// States reports stream state changes; slow readers miss transitions
+	States() <-chan TransportState
+	// Close releases the transport and shuts down any open stream
+	Close() error
+}
+
+// Stream is one session with the server. Send is only ever called from the
+// sender goroutine; Recv delivers server messages until Done is closed.
+type Stream interface {
+	Send(*controlpb.Envelope) error
+	Recv() <-chan *controlpb.Envelope
+	// Done is closed when the stream fails or is closed; Err says why
+	Done() <-chan struct{}
+	Err() error
+	Close() error
+	// Abort fails the stream with err. Unlike Close it may be called from
+	// any goroutine, e.g. when the server has gone silent.
+	Abort(err error)
+}
+
+// TransportState is the state of the agent's stream to the server. The
+// names follow gRPC's connectivity states.
+type TransportState int
+
+const (
+	StateIdle TransportState = iota
+	StateConnecting
+	StateReady
+	StateTransientFailure
+	StateShutdown
+)
+
+func (s TransportState) String() string {
+	switch s {
+	case StateIdle:
+		return "IDLE"
+	case StateConnecting:
+		return "CONNECTING"
+	case StateReady:
+		return "READY"
+	case StateTransientFailure:
+		return "TRANSIENT_FAILURE"
+	case StateShutdown:
+		return "SHUTDOWN"
+	}
+	return fmt.Sprintf("TransportState(%d)", int(s))
+}
+
+// errStreamClosed is the Err of a stream closed by the agent.
+var errStreamClosed = errors.New("stream closed")
+
+// stateNotifier implements Transport.States.
+type stateNotifier struct {
+	once   sync.Once
+	states chan TransportState
+}
+
+func (n *stateNotifier) init() {
+	n.once.Do(func() { n.states = make(chan TransportState, 16) })
+}
+
+func (n *stateNotifier) States() <-chan TransportState {
+	n.init()
+	return n.states
+}
+
+func (n *stateNotifier) notify(s TransportState) {
+	n.init()
+	select {
+	case n.states <- s:
+	default:
+	}
+}
+
+// streamState implements Stream.Done and Stream.Err.
+type streamState struct {
+	done chan struct{}
+	once sync.Once
+	err  error
+}
+
+func newStreamState() streamState {
+	return streamState{done: make(chan struct{})}
+}
+
+func (s *streamState) Done() <-chan struct{} { return s.done }
+
+// Err is only meaningful once Done is closed.
+func (s *streamState) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Abort is the default for streams with nothing to release.
+func (s *streamState) Abort(err error) { s.fail(err) }
+
+// fail marks the stream failed and reports whether this call did so.
+func (s *streamState) fail(err error) bool {
+	first := false
+	s.once.Do(func() {
+		s.err = err
+		close(s.done)
+		first = true
+	})
+	return first
+}
+
+// KeepaliveConfig sets the gRPC keepalive pings, which notice a dead
+// connection even while the stream has nothing to send.
+type KeepaliveConfig struct {
+	// Time is how long the connection may be idle before a ping (0 = never).
+	// Servers close connections that ping more often than they allow (5m by default).
+	Time time.Duration
+	// Timeout is how long to wait for a ping's ack before closing the connection
+	Timeout time.Duration
+	// PermitWithoutStream also pings while no stream is open
+	PermitWithoutStream bool
+}
+
+// grpcTransport speaks the Control service's bidirectional stream.
+type grpcTransport struct {
+	stateNotifier
+	addr      string
+	nodeID    string
+	tls       TLSConfig
+	keepalive KeepaliveConfig
+	// redialAfter is the number of consecutive stream failures after which
+	// the connection itself is replaced
+	redialAfter int
+
+	mu       sync.Mutex
+	conn     *grpc.ClientConn
+	client   controlpb.ControlServiceClient
+	failures int
+	// creds outlive redials; stopCerts ends their certificate watcher
+	creds     credentials.TransportCredentials
+	stopCerts context.CancelFunc
+}
+
+func newGRPCTransport(addr, nodeID string, tls TLSConfig, keepalive KeepaliveConfig, redialAfter int) *grpcTransport {
+	return &grpcTransport{addr: addr, nodeID: nodeID, tls: tls, keepalive: keepalive, redialAfter: redialAfter}
+}
+
+func (t *grpcTransport) Connect(ctx context.Context) (Stream, error) {
+	t.notify(StateConnecting)
+	client, err := t.clientFor(ctx)
+	if err != nil {
+		t.notify(StateTransientFailure)
+		return nil, err
+	}
+
+	// The stream gets its own context so Close can end it from both sides
+	sctx, cancel := context.WithCancel(ctx)
+	stream, err := client.Control(sctx)
+	if err != nil {
+		cancel()
+		t.streamFailed()
+		return nil, err
+	}
+
+	t.notify(StateReady)
+
+	s := &grpcStream{
+		streamState: newStreamState(),
+		stream:      stream,
+		cancel:      cancel,
+		recv:        make(chan *controlpb.Envelope),
+		onFail:      t.streamFailed,
+		onHealthy:   t.streamHealthy,
+	}
+	go s.pump()
+	return s, nil
+}
+
+// clientFor returns the Control client, dialing on first use and again
+// after repeated stream failures.
+func (t *grpcTransport) clientFor(ctx context.Context) (controlpb.ControlServiceClient, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// A stream that keeps failing usually means the connection itself is
+	// stale (e.g. the supervisor pod was replaced), so redial from scratch
+	if t.conn != nil && t.redialAfter > 0 && t.failures >= t.redialAfter {
+		nodeLogger(t.nodeID).Warn("Consecutive stream failures, redialing connection", "failures", t.failures)
+		t.conn.Close()
+		t.conn = nil
+	}
+	if t.conn == nil {
+		conn, err := t.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		t.conn = conn
+		t.client = controlpb.NewControlServiceClient(conn)
+		t.failures = 0
+	}
+	return t.client, nil
+}
+
+// streamHealthy resets the failure count once the server has answered on a
+// stream; opening one proves little, since a stale connection fails in Recv.
+func (t *grpcTransport) streamHealthy() {
+	t.mu.Lock()
+	t.failures = 0
+	t.mu.Unlock()
+}
+
+func (t *grpcTransport) streamFailed() {
+	t.mu.Lock()
+	t.failures++
+	t.mu.Unlock()
+	t.notify(StateTransientFailure)
+}
+
+// transportCredentials returns the credentials, setting them up and
+// starting the client certificate watcher on first use. Callers hold t.mu.
+func (t *grpcTransport) transportCredentials(ctx context.Context) (credentials.TransportCredentials, error) {
+	if t.creds != nil {
+		return t.creds, nil
+	}
+	creds, reloader, err := t.tls.transportCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up transport credentials: %w", err)
+	}
+	if reloader == nil {
+		nodeLogger(t.nodeID).Warn("TLS disabled, connecting to supervisor in plaintext")
+	} else {
+		var wctx context.Context
+		wctx, t.stopCerts = context.WithCancel(ctx)
+		go reloader.watch(wctx, t.nodeID, t.tls.ReloadInterval)
+	}
+	t.creds = creds
+	return creds, nil
+}
+
+// dial creates the gRPC connection to the supervisor. Callers hold t.mu.
+func (t *grpcTransport) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	creds, err := t.transportCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if t.keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                t.keepalive.Time,
+			Timeout:             t.keepalive.Timeout,
+			PermitWithoutStream: t.keepalive.PermitWithoutStream,
+		}))
+	}
+	conn, err := grpc.NewClient(t.addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	go t.watchConnState(ctx, conn)
+	return conn, nil
+}
+
+// watchConnState logs connectivity transitions until the connection is closed.
+func (t *grpcTransport) watchConnState(ctx context.Context, conn *grpc.ClientConn) {
+	state := conn.GetState()
+	for {
+		nodeLogger(t.nodeID).Debug("Connection state changed", "state", state.String())
+		if state == connectivity.Shutdown {
+			return
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = conn.GetState()
+	}
+}
+
+func (t *grpcTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.notify(StateShutdown)
+	if t.stopCerts != nil {
+		t.stopCerts()
+		t.stopCerts = nil
+	}
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// grpcStream adapts the blocking Control stream to Stream.
+type grpcStream struct {
+	streamState
+	stream controlpb.ControlService_ControlClient
+	cancel context.CancelFunc
+	recv   chan *controlpb.Envelope
+	onFail func()
+	// onHealthy is called when the first message arrives
+	onHealthy func()
+}
+
+func (s *grpcStream) pump() {
+	for first := true; ; first = false {
+		env, err := s.stream.Recv()
+		if err != nil {
+			s.failWith(err)
+			return
+		}
+		if first {
+			s.onHealthy()
+		}
+		select {
+		case s.recv <- env:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *grpcStream) failWith(err error) {
+	if s.fail(err) {
+		s.cancel()
+		s.onFail()
+	}
+}
+
+func (s *grpcStream) Send(env *controlpb.Envelope) error {
+	if err := s.stream.Send(env); err != nil {
+		s.failWith(err)
+		return err
+	}
+	return nil
+}
+
+func (s *grpcStream) Recv() <-chan *controlpb.Envelope { return s.recv }
+
+// Abort cancels the stream, which also unblocks a Send stuck on a dead connection.
+func (s *grpcStream) Abort(err error) { s.failWith(err) }
+
+func (s *grpcStream) Close() error {
+	err := s.stream.CloseSend()
+	if s.fail(errStreamClosed) {
+		s.cancel()
+	}
+	return err
+}
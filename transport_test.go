@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"local.dev/opamp-device-agent/api/controlpb"
+)
+
+// memoryTransport is an in-process Transport. Every Connect hands the
+// server side of the new stream to the test through accepted.
+type memoryTransport struct {
+	stateNotifier
+	accepted chan *memoryStream
+
+	mu sync.Mutex
+	// refuse is the number of Connects still to fail; attempts counts them all
+	refuse   int
+	attempts int
+}
+
+func newMemoryTransport() *memoryTransport {
+	return &memoryTransport{accepted: make(chan *memoryStream, 4)}
+}
+
+// errRefused is returned by a memoryTransport told to refuse connections.
+var errRefused = errors.New("connection refused")
+
+func (t *memoryTransport) Connect(ctx context.Context) (Stream, error) {
+	t.mu.Lock()
+	t.attempts++
+	if t.refuse > 0 {
+		t.refuse--
+		t.mu.Unlock()
+		t.notify(StateTransientFailure)
+		return nil, errRefused
+	}
+	t.mu.Unlock()
+
+	s := &memoryStream{
+		streamState: newStreamState(),
+		toServer:    make(chan *controlpb.Envelope, 64),
+		toAgent:     make(chan *controlpb.Envelope),
+	}
+	t.notify(StateReady)
+	t.accepted <- s
+	return s, nil
+}
+
+func (t *memoryTransport) Close() error {
+	t.notify(StateShutdown)
+	return nil
+}
+
+// refuseNext makes the next n Connects fail.
+func (t *memoryTransport) refuseNext(n int) {
+	t.mu.Lock()
+	t.refuse = n
+	t.mu.Unlock()
+}
+
+func (t *memoryTransport) connectAttempts() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.attempts
+}
+
+// accept returns the stream opened by the agent's next Connect.
+func (t *memoryTransport) accept(tb testing.TB) *memoryStream {
+	tb.Helper()
+	select {
+	case s := <-t.accepted:
+		return s
+	case <-time.After(5 * time.Second):
+		tb.Fatal("agent did not connect")
+		return nil
+	}
+}
+
+type memoryStream struct {
+	streamState
+	toServer chan *controlpb.Envelope
+	toAgent  chan *controlpb.Envelope
+}
+
+func (s *memoryStream) Send(env *controlpb.Envelope) error {
+	select {
+	case s.toServer <- env:
+		return nil
+	case <-s.done:
+		return s.err
+	}
+}
+
+func (s *memoryStream) Recv() <-chan *controlpb.Envelope { return s.toAgent }
+
+func (s *memoryStream) Close() error {
+	s.fail(errStreamClosed)
+	return nil
+}
+
+// next returns the next envelope the agent sent.
+func (s *memoryStream) next(tb testing.TB) *controlpb.Envelope {
+	tb.Helper()
+	select {
+	case env := <-s.toServer:
+		return env
+	case <-time.After(5 * time.Second):
+		tb.Fatal("agent sent nothing")
+		return nil
+	}
+}
+
+// push delivers env to the agent as if the server had sent it.
+func (s *memoryStream) push(tb testing.TB, env *controlpb.Envelope) {
+	tb.Helper()
+	select {
+	case s.toAgent <- env:
+	case <-time.After(5 * time.Second):
+		tb.Fatal("agent did not receive")
+	}
+}
+
+// TestAgentOverMemoryTransport tests registration, commands and reconnection
+// against the in-memory transport
+func TestAgentOverMemoryTransport(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "fluent-bit.conf")
+	os.WriteFile(configPath, []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+	mt := newMemoryTransport()
+	a := NewDeviceAgent("unused", "device-1", "fluentbit", configPath, "", Options{
+		Transport:       mt,
+		Backoff:         BackoffPolicy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Multiplier: 1},
+		MonitorInterval: time.Hour,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer a.Stop()
+
+	stream := mt.accept(t)
+	if reg := stream.next(t).GetRegister(); reg.GetNodeId() != "device-1" {
+		t.Fatalf("first message is not a registration: %v", reg)
+	}
+	if ack := stream.next(t).GetConfigAck(); ack.GetConfigHash() == "" {
+		t.Fatalf("expected the initial effective config, got %v", ack)
+	}
+
+	stream.push(t, &controlpb.Envelope{Body: &controlpb.Envelope_Command{
+		Command: &controlpb.Command{Type: "FetchStatus", CorrelationId: "c-1"},
+	}})
+	ev := stream.next(t).GetEvent()
+	if ev.GetType() != "StatusReport" || ev.GetCorrelationId() != "c-1" {
+		t.Errorf("got event %v, want StatusReport for c-1", ev)
+	}
+
+	// A dropped stream is replaced and the agent registers again
+	stream.fail(errors.New("connection reset"))
+	stream = mt.accept(t)
+	if reg := stream.next(t).GetRegister(); reg.GetNodeId() != "device-1" {
+		t.Errorf("first message after reconnect is not a registration: %v", reg)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigValidator checks a pushed config before it is written over the live
+// one. Implementations return a *ValidationError for bad configs.
+type ConfigValidator interface {
+	Validate(ctx context.Context, config []byte) error
+}
+
+// ValidationProblem is a single finding; Line is 0 when it is not tied to a line.
+type ValidationProblem struct {
+	Line    int
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return p.Message
+}
+
+// ValidationError lists everything wrong with a rejected config.
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.String()
+	}
+	return "config validation failed: " + strings.Join(msgs, "; ")
+}
+
+// newConfigValidator builds a validator from the --validate flag: "" disables
+// validation, "builtin" uses the classic Fluent Bit format parser, and
+// anything else is run as a command with {config} replaced by the path of a
+// temp file holding the config, e.g. "fluent-bit --dry-run -c {config}" or
+// "otelcol validate --config={config}".
+func newConfigValidator(spec, configPath string, timeout time.Duration) (ConfigValidator, error) {
+	switch spec {
+	case "":
+		return nil, nil
+	case "builtin":
+		return classicValidator{}, nil
+	}
+
+	argv := strings.Fields(spec)
+	if !strings.Contains(spec, "{config}") {
+		return nil, fmt.Errorf("validator command %q has no {config} placeholder", spec)
+	}
+	return &commandValidator{argv: argv, configPath: configPath, timeout: timeout}, nil
+}
+
+// commandValidator runs an external binary against a temp copy of the config.
+type commandValidator struct {
+	argv       []string
+	configPath string
+	timeout    time.Duration
+}
+
+var lineRef = regexp.MustCompile(`(?i)\bline[: ]+(\d+)`)
+
+func (v *commandValidator) Validate(ctx context.Context, config []byte) error {
+	// Validate next to the live config so relative @INCLUDEs resolve the same way.
+	// The drift watcher only looks at the config's own name, and the leading
+	// dot keeps the copy out of include globs such as *.conf
+	dir := filepath.Dir(v.configPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".validate-*"+filepath.Ext(v.configPath))
+	if err != nil {
+		return fmt.Errorf("failed to create temp config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(config); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp config: %w", err)
+	}
+	tmp.Close()
+
+	args := make([]string, len(v.argv))
+	for i, arg := range v.argv {
+		args[i] = strings.ReplaceAll(arg, "{config}", tmp.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// The validator itself could not run; don't blame the config
+		return fmt.Errorf("failed to run validator %s: %w", args[0], err)
+	}
+
+	verr := &ValidationError{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		text := strings.TrimSpace(strings.ReplaceAll(scanner.Text(), tmp.Name(), "config"))
+		if text == "" {
+			continue
+		}
+		p := ValidationProblem{Message: text}
+		if m := lineRef.FindStringSubmatch(text); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+		}
+		verr.Problems = append(verr.Problems, p)
+	}
+	if len(verr.Problems) == 0 {
+		verr.Problems = append(verr.Problems, ValidationProblem{Message: fmt.Sprintf("%s exited with %d", args[0], exitErr.ExitCode())})
+	}
+	return verr
+}
+
+// classicSections are the section names accepted by Fluent Bit's classic format.
+var classicSections = map[string]bool{
+	"SERVICE":          true,
+	"INPUT":            true,
+	"FILTER":           true,
+	"OUTPUT":           true,
+	"PARSER":           true,
+	"MULTILINE_PARSER": true,
+	"PLUGINS":          true,
+	"UPSTREAM":         true,
+	"NODE":             true,
+	"CUSTOM":           true,
+	"STREAM_TASK":      true,
+}
+
+// classicValidator is a built-in syntactic check of Fluent Bit's classic
+// [SECTION] / key-value format. It catches typos, not semantic errors.
+type classicValidator struct{}
+
+func (classicValidator) Validate(_ context.Context, config []byte) error {
+	verr := &ValidationError{}
+	add := func(line int, format string, args ...interface{}) {
+		verr.Problems = append(verr.Problems, ValidationProblem{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	section, sectionLine := "", 0
+	hasName := false
+	closeSection := func() {
+		switch section {
+		case "INPUT", "FILTER", "OUTPUT", "CUSTOM":
+			if !hasName {
+				add(sectionLine, "[%s] section has no Name", section)
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(config))
+	n := 0
+	for scanner.Scan() {
+		n++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "["):
+			closeSection()
+			sectionLine, hasName = n, false
+			if !strings.HasSuffix(line, "]") {
+				add(n, "unterminated section header %q", line)
+				// Keep parsing as if it were closed to avoid cascading errors
+				section = strings.ToUpper(strings.TrimSpace(line[1:]))
+				continue
+			}
+			section = strings.ToUpper(strings.TrimSpace(line[1 : len(line)-1]))
+			if !classicSections[section] {
+				add(n, "unknown section [%s]", section)
+			}
+
+		case strings.HasPrefix(line, "@"):
+			directive := strings.ToUpper(strings.Fields(line)[0])
+			if directive != "@INCLUDE" && directive != "@SET" {
+				add(n, "unknown directive %s", directive)
+			} else if len(strings.Fields(line)) < 2 {
+				add(n, "%s requires an argument", directive)
+			}
+
+		default:
+			if section == "" {
+				add(n, "entry %q outside of any section", line)
+				continue
+			}
+			if raw == line {
+				add(n, "entry %q must be indented under [%s]", line, section)
+			}
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				add(n, "key %q has no value", fields[0])
+				continue
+			}
+			if strings.EqualFold(fields[0], "Name") {
+				hasName = true
+			}
+		}
+	}
+	closeSection()
+
+	if len(verr.Problems) > 0 {
+		return verr
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestClassicValidator tests the built-in classic format parser
+func TestClassicValidator(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantLines []int
+	}{
+		{
+			name: "valid config",
+			config: `# comment
+@SET level=info
+[SERVICE]
+    Flush 1
+
+[INPUT]
+    Name dummy
+
+[OUTPUT]
+    Name  stdout
+    Match *
+`,
+		},
+		{
+			name:      "unknown section",
+			config:    "[INPUTS]\n    Name dummy\n",
+			wantLines: []int{1},
+		},
+		{
+			name:      "unterminated header",
+			config:    "[OUTPUT\n    Name stdout\n",
+			wantLines: []int{1},
+		},
+		{
+			name:      "key without value",
+			config:    "[OUTPUT]\n    Name stdout\n    Match\n",
+			wantLines: []int{3},
+		},
+		{
+			name:      "missing plugin name",
+			config:    "[SERVICE]\n    Flush 1\n[OUTPUT]\n    Match *\n",
+			wantLines: []int{3},
+		},
+		{
+			name:      "entry outside section and unindented entry",
+			config:    "Flush 1\n[OUTPUT]\nName stdout\n",
+			wantLines: []int{1, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classicValidator{}.Validate(context.Background(), []byte(tt.config))
+			if len(tt.wantLines) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want *ValidationError", err)
+			}
+			if len(verr.Problems) != len(tt.wantLines) {
+				t.Fatalf("got %d problems (%v), want %d", len(verr.Problems), verr, len(tt.wantLines))
+			}
+			for i, line := range tt.wantLines {
+				if verr.Problems[i].Line != line {
+					t.Errorf("problem %d on line %d, want line %d", i, verr.Problems[i].Line, line)
+				}
+			}
+		})
+	}
+}
+
+// TestCommandValidator tests running an external validator binary
+func TestCommandValidator(t *testing.T) {
+	dir, bin := t.TempDir(), t.TempDir()
+	script := filepath.Join(bin, "validate.sh")
+	// Like Fluent Bit, resolves @INCLUDE against the config file's directory
+	os.WriteFile(script, []byte(`#!/bin/sh
+echo "$1" > "$(dirname "$0")/validated"
+grep '^@INCLUDE' "$1" | while read -r _ inc; do
+  if [ ! -f "$(dirname "$1")/$inc" ]; then
+    echo "[error] cannot include $inc at line 1"
+    exit 1
+  fi
+done || exit 1
+if grep -q BAD "$1"; then
+  echo "[error] unexpected token at line 2 in $1"
+  exit 1
+fi
+`), 0755)
+	os.WriteFile(filepath.Join(dir, "outputs.conf"), []byte("[OUTPUT]\n    Name stdout\n"), 0644)
+
+	configPath := filepath.Join(dir, "fluent-bit.conf")
+	v, err := newConfigValidator(script+" {config}", configPath, 5*time.Second)
+	if err != nil {
+		t.Fatalf("newConfigValidator: %v", err)
+	}
+
+	// A relative include resolves because the copy sits next to the live config
+	if err := v.Validate(context.Background(), []byte("@INCLUDE outputs.conf\n")); err != nil {
+		t.Errorf("config with a relative @INCLUDE rejected: %v", err)
+	}
+	validated, _ := os.ReadFile(filepath.Join(bin, "validated"))
+	copyPath := strings.TrimSpace(string(validated))
+	if filepath.Dir(copyPath) != dir || filepath.Base(copyPath) == filepath.Base(configPath) || !strings.HasPrefix(filepath.Base(copyPath), ".") {
+		t.Errorf("validated %q, want a hidden copy next to the config", copyPath)
+	}
+	if err := v.Validate(context.Background(), []byte("@INCLUDE missing.conf\n")); err == nil {
+		t.Error("config including a missing file accepted")
+	}
+
+	err = v.Validate(context.Background(), []byte("[OUTPUT]\n    BAD\n"))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want *ValidationError", err)
+	}
+	if verr.Problems[0].Line != 2 {
+		t.Errorf("got line %d, want 2 (%v)", verr.Problems[0].Line, verr)
+	}
+
+	// Temp files are cleaned up next to the config
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("got %d files in config dir, want only the include", len(entries))
+	}
+}
+
+// TestNewConfigValidatorRequiresPlaceholder tests flag parsing
+func TestNewConfigValidatorRequiresPlaceholder(t *testing.T) {
+	if _, err := newConfigValidator("fluent-bit --dry-run", "/tmp/fluent-bit.conf", time.Second); err == nil {
+		t.Error("expected error for command without {config}")
+	}
+	if v, err := newConfigValidator("", "/tmp/fluent-bit.conf", time.Second); v != nil || err != nil {
+		t.Errorf("empty spec: got %v, %v", v, err)
+	}
+}
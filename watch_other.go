@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// watchFile is only implemented on Linux; elsewhere drift is caught by the
+// runtime monitor's periodic check.
+func watchFile(ctx context.Context, path string) (<-chan struct{}, error) {
+	return nil, errors.New("file watching is not supported on this platform")
+}